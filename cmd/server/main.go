@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"warehouse_oa/internal/initialize"
 	"warehouse_oa/internal/service"
 )
@@ -13,12 +20,46 @@ func main() {
 	if err := initialize.InitDb(); err != nil {
 		logrus.Panicf("init db err:%s", err.Error())
 	}
+	if err := initialize.InitStorage(); err != nil {
+		logrus.Panicf("init storage err:%s", err.Error())
+	}
+	if err := service.InitFinishedBom(); err != nil {
+		logrus.Panicf("init finished bom err:%s", err.Error())
+	}
+	if err := service.InitProductionVariance(); err != nil {
+		logrus.Panicf("init production variance err:%s", err.Error())
+	}
+
+	// 收到退出信号时停止定时任务和服务
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := service.InitJobs(); err != nil {
+		logrus.Panicf("init jobs err:%s", err.Error())
+	}
+	jobDone := make(chan struct{})
+	go func() {
+		service.StartJobs(ctx)
+		close(jobDone)
+	}()
 
-	go service.Ticker()
 	router := initialize.InitRouters()
-	err := router.Run(":8090")
-	if err != nil {
-		logrus.Fatalln("Failed to start router", err.Error())
-		return
-	} // 监听并在 0.0.0.0:8080 上启动服务
+	srv := &http.Server{
+		Addr:    ":8090",
+		Handler: router,
+	}
+	go func() {
+		// 监听并在 0.0.0.0:8090 上启动服务
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatalln("Failed to start router", err.Error())
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logrus.Errorln("Failed to shutdown router", err.Error())
+	}
+	<-jobDone
 }
@@ -21,7 +21,27 @@ type JWTConfig struct {
 	SigningKey string `json:"signing_key"`
 }
 
+// StorageConfig 文件存储配置 Type 为 local 或 s3
+type StorageConfig struct {
+	Type      string   `json:"type"`
+	LocalDir  string   `json:"local_dir"`  // 本地存储目录
+	PublicUrl string   `json:"public_url"` // 文件访问地址前缀
+	SignKey   string   `json:"sign_key"`   // 本地存储签名地址的密钥
+	UrlExpire int      `json:"url_expire"` // 签名地址有效期(秒)
+	S3        S3Config `json:"s3"`
+}
+
+// S3Config S3 兼容存储配置 使用路径方式访问存储桶 兼容 MinIO
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // 如 http://127.0.0.1:9000
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
 type ServerConfigInfo struct {
-	JWTInfo   JWTConfig   `json:"jwt"`
-	MysqlInfo MysqlConfig `json:"mysql"`
+	JWTInfo     JWTConfig     `json:"jwt"`
+	MysqlInfo   MysqlConfig   `json:"mysql"`
+	StorageInfo StorageConfig `json:"storage"`
 }
@@ -0,0 +1,84 @@
+package ecomm
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type BillReconcileHandler struct{}
+
+var br BillReconcileHandler
+
+func InitBillReconcileRouter(router *gin.RouterGroup) {
+	billReconcileRouter := router.Group("reconcile")
+
+	billReconcileRouter.GET("list", br.list)
+	billReconcileRouter.GET("summary", br.summary)
+	billReconcileRouter.GET("compensation", br.compensation)
+	billReconcileRouter.POST("run", br.run)
+}
+
+// list 对账结果列表 type 1 已匹配 2 账单无快递 3 快递无账单 4 数量不符
+func (*BillReconcileHandler) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	reconcileType := utils.DefaultQueryInt(c, "type", 0)
+	shopId := utils.DefaultQueryInt(c, "shopId", 0)
+	shopName := c.DefaultQuery("shopName", "")
+	orderNumber := c.DefaultQuery("orderNumber", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetBillReconcileList(reconcileType, shopId, shopName, orderNumber,
+		begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// summary 对账结果各类型数量
+func (*BillReconcileHandler) summary(c *gin.Context) {
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetBillReconcileSummary(begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// compensation 按店铺和周期汇总快递赔付金额
+func (*BillReconcileHandler) compensation(c *gin.Context) {
+	shopId := utils.DefaultQueryInt(c, "shopId", 0)
+	period := c.DefaultQuery("period", "month")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetBillCompensation(shopId, period, begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// run 立即对账 与定时任务 billReconcile 相同
+func (*BillReconcileHandler) run(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	data, err := service.RunJob("billReconcile", userId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
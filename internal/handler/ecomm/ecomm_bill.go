@@ -3,6 +3,8 @@ package ecomm
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
+	"strconv"
 	"warehouse_oa/internal/handler"
 	"warehouse_oa/internal/models"
 	"warehouse_oa/internal/service"
@@ -22,12 +24,21 @@ func InitECommBillRouter(router *gin.RouterGroup) {
 	eCommBillRouter.POST("update", e.update)
 	eCommBillRouter.POST("delete", e.delete)
 	eCommBillRouter.POST("upload", e.upload)
+	eCommBillRouter.GET("importError", e.importError)
+	eCommBillRouter.GET("review", e.review)
+	eCommBillRouter.POST("confirm", e.confirm)
+	eCommBillRouter.POST("linkShop", e.linkShop)
+	eCommBillRouter.GET("analytics", e.analytics)
 }
 
 func (*BillHandler) list(c *gin.Context) {
 	pn, pSize := utils.ParsePaginationParams(c)
 	eCommBill := &models.ECommBill{
-		Name: c.DefaultQuery("name", ""),
+		Name:   c.DefaultQuery("name", ""),
+		Status: utils.DefaultQueryInt(c, "status", -1),
+	}
+	if shopId := utils.DefaultQueryInt(c, "shopId", 0); shopId > 0 {
+		eCommBill.ShopId = &shopId
 	}
 	data, err := service.GetECommBillList(eCommBill, pn, pSize)
 	if err != nil {
@@ -110,11 +121,91 @@ func (*BillHandler) upload(c *gin.Context) {
 	}
 
 	username := c.GetString("userName")
-	err = service.UploadECommBill(file, username)
+	dryRun := c.DefaultPostForm("dryRun", "false") == "true"
+	shopId, _ := strconv.Atoi(c.DefaultPostForm("shopId", "0"))
+	profile := c.DefaultPostForm("profile", "")
+	data, err := service.UploadECommBill(file, username, shopId, profile, dryRun)
 	if err != nil {
 		c.String(http.StatusBadRequest, "文件读取失败: %v", err)
 		return
 	}
 
+	handler.Success(c, data)
+}
+
+// importError 下载导入错误明细
+func (*BillHandler) importError(c *gin.Context) {
+	name, reader, err := service.GetImportErrorFile(c.DefaultQuery("file", ""))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": "attachment; filename*=UTF-8''" + url.PathEscape(name),
+	})
+}
+
+// review 待匹配账单 按商品标题+销售规格汇总
+func (*BillHandler) review(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	title := c.DefaultQuery("title", "")
+
+	data, err := service.GetBillReviewList(title, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// confirm 确认账单出库 扣除产品库存
+func (*BillHandler) confirm(c *gin.Context) {
+	var request struct {
+		Ids        []int `json:"ids" binding:"required"`
+		LocationId int   `json:"locationId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.ConfirmECommBill(request.Ids, request.LocationId, c.GetString("userName"))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
 	handler.Success(c, nil)
 }
+
+// linkShop 未关联店铺的账单按客户名称关联店铺
+func (*BillHandler) linkShop(c *gin.Context) {
+	count, err := service.LinkECommBillShop()
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, count)
+}
+
+// analytics 电商销售统计 period 为 day week month
+func (*BillHandler) analytics(c *gin.Context) {
+	shopId := utils.DefaultQueryInt(c, "shopId", 0)
+	period := c.DefaultQuery("period", "week")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+	top := utils.DefaultQueryInt(c, "top", 10)
+
+	data, err := service.GetECommAnalytics(shopId, period, begTime, endTime, top)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
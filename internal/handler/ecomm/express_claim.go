@@ -0,0 +1,116 @@
+package ecomm
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type ExpressClaimHandler struct{}
+
+var ec ExpressClaimHandler
+
+func InitExpressClaimRouter(router *gin.RouterGroup) {
+	expressClaimRouter := router.Group("claim")
+
+	expressClaimRouter.GET("list", ec.list)
+	expressClaimRouter.GET("outstanding", ec.outstanding)
+	expressClaimRouter.POST("add", ec.add)
+	expressClaimRouter.POST("update", ec.update)
+	expressClaimRouter.POST("status", ec.status)
+}
+
+// list 快递赔付列表
+func (*ExpressClaimHandler) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	claim := &models.ExpressClaim{
+		FastBillId: utils.DefaultQueryInt(c, "fastBillId", 0),
+		Carrier:    c.DefaultQuery("carrier", ""),
+		Status:     utils.DefaultQueryInt(c, "status", 0),
+	}
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetExpressClaimList(claim, begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// outstanding 按快递公司汇总未结束的赔付
+func (*ExpressClaimHandler) outstanding(c *gin.Context) {
+	carrier := c.DefaultQuery("carrier", "")
+
+	data, err := service.GetExpressClaimOutstanding(carrier)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ExpressClaimHandler) add(c *gin.Context) {
+	claim := &models.ExpressClaim{}
+	if err := c.ShouldBindJSON(claim); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	claim.Operator = c.GetString("userName")
+	data, err := service.SaveExpressClaim(claim)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ExpressClaimHandler) update(c *gin.Context) {
+	claim := &models.ExpressClaim{}
+	if err := c.ShouldBindJSON(claim); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	claim.Operator = c.GetString("userName")
+	data, err := service.UpdateExpressClaim(claim)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// status 修改赔付状态 2 提交快递 3 审核通过 4 已赔付(需填写到账金额) 5 拒绝
+func (*ExpressClaimHandler) status(c *gin.Context) {
+	var request struct {
+		Id     int     `json:"id" binding:"required"`
+		Status int     `json:"status" binding:"required"`
+		Amount float64 `json:"amount"`
+		Remark string  `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	data, err := service.ChangeExpressClaimStatus(request.Id, request.Status,
+		request.Amount, request.Remark, c.GetString("userName"))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
@@ -3,6 +3,7 @@ package ecomm
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"warehouse_oa/internal/handler"
 	"warehouse_oa/internal/models"
 	"warehouse_oa/internal/service"
@@ -22,6 +23,7 @@ func InitFastBillRouter(router *gin.RouterGroup) {
 	fastBillRouter.POST("update", f.update)
 	fastBillRouter.POST("delete", f.delete)
 	fastBillRouter.POST("upload", f.upload)
+	fastBillRouter.GET("importError", f.importError)
 }
 
 func (*FastBillHandler) list(c *gin.Context) {
@@ -110,11 +112,27 @@ func (*FastBillHandler) upload(c *gin.Context) {
 	}
 
 	username := c.GetString("userName")
-	err = service.UploadFastBill(file, username)
+	dryRun := c.DefaultPostForm("dryRun", "false") == "true"
+	profile := c.DefaultPostForm("profile", "")
+	data, err := service.UploadFastBill(file, username, profile, dryRun)
 	if err != nil {
 		c.String(http.StatusBadRequest, "文件读取失败: %v", err)
 		return
 	}
 
-	handler.Success(c, nil)
+	handler.Success(c, data)
+}
+
+// importError 下载导入错误明细
+func (*FastBillHandler) importError(c *gin.Context) {
+	name, reader, err := service.GetImportErrorFile(c.DefaultQuery("file", ""))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": "attachment; filename*=UTF-8''" + url.PathEscape(name),
+	})
 }
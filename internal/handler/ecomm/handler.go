@@ -8,4 +8,8 @@ func InitECommerceRouter(router *gin.RouterGroup) {
 	InitECommBillRouter(eCommerceRouter)
 	InitECommCustomersRouter(eCommerceRouter)
 	InitFastBillRouter(eCommerceRouter)
+	InitImportProfileRouter(eCommerceRouter)
+	InitProductMappingRouter(eCommerceRouter)
+	InitBillReconcileRouter(eCommerceRouter)
+	InitExpressClaimRouter(eCommerceRouter)
 }
@@ -0,0 +1,88 @@
+package ecomm
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type ImportProfileHandler struct{}
+
+var ip ImportProfileHandler
+
+func InitImportProfileRouter(router *gin.RouterGroup) {
+	importProfileRouter := router.Group("importProfile")
+
+	importProfileRouter.GET("list", ip.list)
+	importProfileRouter.POST("add", ip.add)
+	importProfileRouter.POST("update", ip.update)
+	importProfileRouter.POST("delete", ip.delete)
+}
+
+// list 导入模板列表
+func (*ImportProfileHandler) list(c *gin.Context) {
+	billType := utils.DefaultQueryInt(c, "billType", 0)
+
+	data, err := service.GetImportProfileList(billType)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ImportProfileHandler) add(c *gin.Context) {
+	profile := &models.ImportProfile{}
+	if err := c.ShouldBindJSON(profile); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	profile.Operator = c.GetString("userName")
+	data, err := service.SaveImportProfile(profile)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ImportProfileHandler) update(c *gin.Context) {
+	profile := &models.ImportProfile{}
+	if err := c.ShouldBindJSON(profile); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	profile.Operator = c.GetString("userName")
+	data, err := service.UpdateImportProfile(profile)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ImportProfileHandler) delete(c *gin.Context) {
+	profile := &models.ImportProfile{}
+	if err := c.ShouldBindJSON(profile); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.DelImportProfile(profile.ID)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
@@ -0,0 +1,90 @@
+package ecomm
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type ProductMappingHandler struct{}
+
+var pm ProductMappingHandler
+
+func InitProductMappingRouter(router *gin.RouterGroup) {
+	productMappingRouter := router.Group("mapping")
+
+	productMappingRouter.GET("list", pm.list)
+	productMappingRouter.POST("add", pm.add)
+	productMappingRouter.POST("update", pm.update)
+	productMappingRouter.POST("delete", pm.delete)
+}
+
+// list 电商商品映射列表
+func (*ProductMappingHandler) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	title := c.DefaultQuery("title", "")
+	productId := utils.DefaultQueryInt(c, "productId", 0)
+
+	data, err := service.GetProductMappingList(title, productId, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ProductMappingHandler) add(c *gin.Context) {
+	mapping := &models.ECommProductMapping{}
+	if err := c.ShouldBindJSON(mapping); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	mapping.Operator = c.GetString("userName")
+	data, err := service.SaveProductMapping(mapping)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ProductMappingHandler) update(c *gin.Context) {
+	mapping := &models.ECommProductMapping{}
+	if err := c.ShouldBindJSON(mapping); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	mapping.Operator = c.GetString("userName")
+	data, err := service.UpdateProductMapping(mapping)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*ProductMappingHandler) delete(c *gin.Context) {
+	mapping := &models.ECommProductMapping{}
+	if err := c.ShouldBindJSON(mapping); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.DelProductMapping(mapping.ID)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
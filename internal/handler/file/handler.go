@@ -0,0 +1,63 @@
+package file
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+)
+
+type File struct{}
+
+var f File
+
+func InitFileRouter(router *gin.RouterGroup) {
+	fileRouter := router.Group("file")
+
+	fileRouter.GET("url", f.url)
+	fileRouter.POST("migrate", f.migrate)
+}
+
+// InitSignedFileRouter 本地存储签名地址下载 不需要登录
+func InitSignedFileRouter(router *gin.RouterGroup) {
+	router.GET("files/*key", f.download)
+}
+
+// url 获取文件签名地址
+func (*File) url(c *gin.Context) {
+	key := c.DefaultQuery("key", "")
+
+	data, err := service.GetFileUrl(key)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// migrate 将本地文件迁移到当前存储
+func (*File) migrate(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	data, err := service.MigrateStorage(c.Request.Context(), userId)
+	if err != nil {
+		handler.InternalServerErrorData(c, err, data)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// download 校验签名后返回本地文件
+func (*File) download(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	path, err := service.GetSignedLocalFile(key, c.Query("expires"), c.Query("sign"))
+	if err != nil {
+		c.String(http.StatusForbidden, err.Error())
+		return
+	}
+
+	c.File(path)
+}
@@ -0,0 +1,59 @@
+package finished
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Bom struct{}
+
+var b Bom
+
+func InitBomRouter(router *gin.RouterGroup) {
+	bomRouter := router.Group("bom")
+
+	bomRouter.GET("list", b.list)
+	bomRouter.GET("listById", b.listById)
+	bomRouter.GET("compare", b.compare)
+}
+
+// list 成品配方版本列表
+func (*Bom) list(c *gin.Context) {
+	finishedId := utils.DefaultQueryInt(c, "finishedId", 0)
+
+	data, err := service.GetBomList(finishedId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Bom) listById(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.GetBomById(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// compare 对比两个配方版本
+func (*Bom) compare(c *gin.Context) {
+	fromId := utils.DefaultQueryInt(c, "fromId", 0)
+	toId := utils.DefaultQueryInt(c, "toId", 0)
+
+	data, err := service.CompareBom(fromId, toId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
@@ -8,4 +8,5 @@ func InitFinishedAllRouter(router *gin.RouterGroup) {
 	InitFinishRouter(finishedRouter)
 	InitProductionRouter(finishedRouter)
 	InitFinishedStockRouter(finishedRouter)
+	InitBomRouter(finishedRouter)
 }
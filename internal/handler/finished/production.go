@@ -2,6 +2,7 @@ package finished
 
 import (
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"warehouse_oa/internal/handler"
 	"warehouse_oa/internal/models"
 	"warehouse_oa/internal/service"
@@ -19,10 +20,18 @@ func InitProductionRouter(router *gin.RouterGroup) {
 	productionRouter.GET("outList", p.outList)
 	productionRouter.GET("finishedSum", p.finishedSum)
 	productionRouter.GET("chart", p.chart)
+	productionRouter.GET("calendar", p.calendar)
+	productionRouter.GET("completionList", p.completionList)
+	productionRouter.GET("variance", p.variance)
+	productionRouter.GET("yield", p.yield)
+	productionRouter.GET("exportYield", p.exportYield)
 	productionRouter.POST("add", p.add)
 	//productionRouter.POST("update", p.update)
 	productionRouter.POST("void", p.void)
 	productionRouter.POST("finish", p.finish)
+	productionRouter.POST("complete", p.complete)
+	productionRouter.POST("close", p.close)
+	productionRouter.POST("schedule", p.schedule)
 }
 
 // list 成品报工列表
@@ -109,7 +118,8 @@ func (*Production) finish(c *gin.Context) {
 	}
 
 	production.Operator = c.GetString("userName")
-	err := service.FinishProduction(production.ID, production.ActualAmount, production.Operator)
+	err := service.FinishProduction(production.ID, production.ActualAmount,
+		production.LocationId, production.Usage, production.Operator)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -118,6 +128,71 @@ func (*Production) finish(c *gin.Context) {
 	handler.Success(c, nil)
 }
 
+// complete 部分完工 报工保持生产中
+func (*Production) complete(c *gin.Context) {
+	production := &models.FinishedProduction{}
+	if err := c.ShouldBindJSON(production); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	production.Operator = c.GetString("userName")
+	err := service.CompleteProduction(production.ID, production.ActualAmount,
+		production.LocationId, production.Usage, production.Operator)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
+
+// close 报工结案
+func (*Production) close(c *gin.Context) {
+	production := &models.FinishedProduction{}
+	if err := c.ShouldBindJSON(production); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	production.Operator = c.GetString("userName")
+	err := service.CloseProduction(production.ID, production.Operator)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
+
+// completionList 报工完工记录
+func (*Production) completionList(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.GetCompletionList(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// variance 报工配料差异
+func (*Production) variance(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.GetProductionVariance(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
 // outList 成品出入库接口
 func (*Production) outList(c *gin.Context) {
 	pn, pSize := utils.ParsePaginationParams(c)
@@ -127,6 +202,7 @@ func (*Production) outList(c *gin.Context) {
 		},
 		FinishedId: utils.DefaultQueryInt(c, "finishedId", 0),
 		Status:     utils.DefaultQueryInt(c, "status", -1),
+		LocationId: utils.DefaultQueryInt(c, "locationId", 0),
 	}
 	inOrOut := utils.DefaultQueryInt(c, "inOrOut", 0)
 	begTime := c.DefaultQuery("begTime", "")
@@ -147,10 +223,11 @@ func (*Production) outList(c *gin.Context) {
 func (*Production) finishedSum(c *gin.Context) {
 	id := utils.DefaultQueryInt(c, "id", 0)
 	status := utils.DefaultQueryInt(c, "status", -1)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
 
-	data, err := service.GetFinishedSum(id, status, begTime, endTime)
+	data, err := service.GetFinishedSum(id, status, locationId, begTime, endTime)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -179,3 +256,76 @@ func (*Production) chart(c *gin.Context) {
 
 	handler.Success(c, data)
 }
+
+// schedule 排产 设置计划时间和生产人员
+func (*Production) schedule(c *gin.Context) {
+	production := &models.FinishedProduction{}
+	if err := c.ShouldBindJSON(production); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	production.Operator = c.GetString("userName")
+	data, err := service.ScheduleProduction(production)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// calendar 排产日历
+func (*Production) calendar(c *gin.Context) {
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+	finishedId := utils.DefaultQueryInt(c, "finishedId", 0)
+	userId := utils.DefaultQueryInt(c, "userId", 0)
+
+	data, err := service.GetProductionCalendar(begTime, endTime, finishedId, userId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// yield 良品率及配料差异分析
+func (*Production) yield(c *gin.Context) {
+	finishedId := utils.DefaultQueryInt(c, "finishedId", 0)
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetYieldReport(finishedId, begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// exportYield 导出良品率及配料差异
+func (*Production) exportYield(c *gin.Context) {
+	finishedId := utils.DefaultQueryInt(c, "finishedId", 0)
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.ExportYieldReport(finishedId, begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="良品率分析.xlsx"`)
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// 将 Excel 文件写入到 HTTP 响应中
+	if err = data.Write(c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "文件生成失败")
+		return
+	}
+}
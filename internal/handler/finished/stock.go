@@ -3,6 +3,7 @@ package finished
 import (
 	"github.com/gin-gonic/gin"
 	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
 	"warehouse_oa/internal/service"
 	"warehouse_oa/utils"
 )
@@ -15,6 +16,8 @@ func InitFinishedStockRouter(router *gin.RouterGroup) {
 	stockRouter := router.Group("stock")
 
 	stockRouter.GET("list", s.list)
+	stockRouter.GET("adjustList", s.adjustList)
+	stockRouter.POST("adjust", s.adjust)
 }
 
 func (*stock) list(c *gin.Context) {
@@ -22,8 +25,46 @@ func (*stock) list(c *gin.Context) {
 	ids := c.DefaultQuery("id", "")
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetFinishedStockList(ids, begTime, endTime, pn, pSize)
+	data, err := service.GetFinishedStockList(ids, begTime, endTime, pn, pSize, locationId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// adjustList 成品库存调整记录
+func (*stock) adjustList(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	finishedId := utils.DefaultQueryInt(c, "finishedId", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetFinishedAdjustList(finishedId, locationId, begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// adjust 调整成品库存
+func (*stock) adjust(c *gin.Context) {
+	adjust := &models.FinishedAdjust{}
+	if err := c.ShouldBindJSON(adjust); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	adjust.Operator = c.GetString("userName")
+	userId := c.GetInt("userId")
+	data, err := service.AdjustFinishedStock(adjust, userId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
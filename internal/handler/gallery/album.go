@@ -0,0 +1,85 @@
+package gallery
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+)
+
+type GalleryAlbum struct{}
+
+var ga GalleryAlbum
+
+func InitGalleryAlbumRouter(router *gin.RouterGroup) {
+	albumRouter := router.Group("album")
+
+	albumRouter.GET("list", ga.list)
+	albumRouter.POST("add", ga.add)
+	albumRouter.POST("update", ga.update)
+	albumRouter.POST("delete", ga.delete)
+}
+
+// list 相册列表
+func (*GalleryAlbum) list(c *gin.Context) {
+	data, err := service.GetGalleryAlbumList()
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*GalleryAlbum) add(c *gin.Context) {
+	album := &models.GalleryAlbum{}
+	if err := c.ShouldBindJSON(album); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	album.Operator = c.GetString("userName")
+	data, err := service.SaveGalleryAlbum(album)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*GalleryAlbum) update(c *gin.Context) {
+	album := &models.GalleryAlbum{}
+	if err := c.ShouldBindJSON(album); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	album.Operator = c.GetString("userName")
+	data, err := service.UpdateGalleryAlbum(album)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*GalleryAlbum) delete(c *gin.Context) {
+	album := &models.GalleryAlbum{}
+	if err := c.ShouldBindJSON(album); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.DelGalleryAlbum(album.ID)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
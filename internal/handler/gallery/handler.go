@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
 	"warehouse_oa/internal/handler"
 	"warehouse_oa/internal/models"
 	"warehouse_oa/internal/service"
@@ -20,17 +21,30 @@ func InitGalleryRouter(router *gin.RouterGroup) {
 
 	galleryRouter.GET("list", g.list)
 	galleryRouter.GET("fields", g.fields)
+	galleryRouter.GET("refs", g.refs)
+	galleryRouter.GET("tags", g.tags)
 	galleryRouter.POST("update", g.update)
 	galleryRouter.POST("delete", g.delete)
 	galleryRouter.POST("uploads", g.uploads)
+
+	InitGalleryAlbumRouter(galleryRouter)
 }
 
 func (*Gallery) list(c *gin.Context) {
 	pn, pSize := utils.ParsePaginationParams(c)
 	gallery := &models.Gallery{
-		Name: c.DefaultQuery("name", ""),
+		BaseModel: models.BaseModel{
+			Operator: c.DefaultQuery("operator", ""),
+		},
+		Name:       c.DefaultQuery("name", ""),
+		AlbumId:    optionalId(utils.DefaultQueryInt(c, "albumId", 0)),
+		ProductId:  optionalId(utils.DefaultQueryInt(c, "productId", 0)),
+		CustomerId: optionalId(utils.DefaultQueryInt(c, "customerId", 0)),
 	}
-	data, err := service.GetGalleryList(gallery, pn, pSize)
+	tag := c.DefaultQuery("tag", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+	data, err := service.GetGalleryList(gallery, tag, begTime, endTime, pn, pSize)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -58,14 +72,17 @@ func (*Gallery) update(c *gin.Context) {
 }
 
 func (*Gallery) delete(c *gin.Context) {
-	gallery := &models.Gallery{}
-	if err := c.ShouldBindJSON(gallery); err != nil {
+	var request struct {
+		Id    int  `json:"id"`
+		Force bool `json:"force"` // 强制删除被引用的图片 仅管理员
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
 		// 如果解析失败，返回 400 错误和错误信息
 		handler.BadRequest(c, err.Error())
 		return
 	}
 
-	err := service.DelGallery(gallery.ID)
+	err := service.DelGallery(request.Id, request.Force, c.GetInt("userId"))
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -74,6 +91,27 @@ func (*Gallery) delete(c *gin.Context) {
 	handler.Success(c, nil)
 }
 
+func (*Gallery) refs(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+	data, err := service.GetGalleryRefList(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Gallery) tags(c *gin.Context) {
+	data, err := service.GetGalleryTagList()
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
 func (*Gallery) fields(c *gin.Context) {
 	field := c.DefaultQuery("field", "")
 	data, err := service.GetGalleryFieldList(field)
@@ -100,6 +138,10 @@ func (*Gallery) uploads(c *gin.Context) {
 		return
 	}
 	username := c.GetString("userName")
+	// 本次上传的图片使用相同的相册 产品 客户 标签
+	albumId, _ := strconv.Atoi(c.PostForm("albumId"))
+	productId, _ := strconv.Atoi(c.PostForm("productId"))
+	customerId, _ := strconv.Atoi(c.PostForm("customerId"))
 
 	m := map[string][]string{
 		"success": {},
@@ -107,27 +149,21 @@ func (*Gallery) uploads(c *gin.Context) {
 	}
 	for _, file := range files {
 		filename := file.Filename
-		dst, name, err := service.SaveCosImages(file)
+		gallery, err := service.SaveCosImages(file)
 		if err != nil {
 			m["error"] = append(m["error"], filename+err.Error())
 			handler.InternalServerErrorData(c, err, m)
 			return
 		}
 
-		if err = c.SaveUploadedFile(file, dst); err != nil {
-			m["error"] = append(m["error"], filename+err.Error())
-			handler.InternalServerErrorData(c, err, m)
-			return
-		}
-
-		logrus.Infof("%s_%s_%s", dst, filename, username)
-		err = service.SaveGallery(&models.Gallery{
-			BaseModel: models.BaseModel{
-				Operator: username,
-			},
-			Name: filename,
-			Url:  name,
-		})
+		logrus.Infof("%s_%s_%s", gallery.Url, filename, username)
+		gallery.Operator = username
+		gallery.Name = filename
+		gallery.AlbumId = optionalId(albumId)
+		gallery.ProductId = optionalId(productId)
+		gallery.CustomerId = optionalId(customerId)
+		gallery.TagList = form.Value["tags"]
+		err = service.SaveGallery(gallery)
 		if err != nil {
 			m["error"] = append(m["error"], filename+err.Error())
 			handler.InternalServerErrorData(c, err, m)
@@ -138,3 +174,12 @@ func (*Gallery) uploads(c *gin.Context) {
 
 	handler.Success(c, m)
 }
+
+// optionalId 可选的关联ID 小于等于0时不关联
+func optionalId(id int) *int {
+	if id <= 0 {
+		return nil
+	}
+
+	return &id
+}
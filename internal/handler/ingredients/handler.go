@@ -8,4 +8,6 @@ func InitIngredientRouter(router *gin.RouterGroup) {
 	InitInBoundRouter(ingredientRouter)
 	InitIngredientsRouter(ingredientRouter)
 	InitStockRouter(ingredientRouter)
+	InitPriceRouter(ingredientRouter)
+	InitInspectionRouter(ingredientRouter)
 }
@@ -37,9 +37,10 @@ func (*InBound) list(c *gin.Context) {
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
 	isPackage := utils.DefaultQueryInt(c, "isPackage", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
 	data, err := service.GetInBoundList(name, stockUnit, supplier,
-		begTime, endTime, pn, pSize, isPackage)
+		begTime, endTime, pn, pSize, isPackage, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -174,8 +175,9 @@ func (*InBound) outList(c *gin.Context) {
 	endTime := c.DefaultQuery("endTime", "")
 	inOrOut := utils.DefaultQueryInt(c, "inOrOut", 0)
 	isPackage := utils.DefaultQueryInt(c, "isPackage", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetConsumeList(ids, stockUnit, begTime, endTime, inOrOut, pn, pSize, isPackage)
+	data, err := service.GetConsumeList(ids, stockUnit, begTime, endTime, inOrOut, pn, pSize, isPackage, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -190,8 +192,9 @@ func (*InBound) ingredientSum(c *gin.Context) {
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
 	inOrOut := utils.DefaultQueryInt(c, "inOrOut", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetIngredientSum(ids, stockUnit, begTime, endTime, inOrOut)
+	data, err := service.GetIngredientSum(ids, stockUnit, begTime, endTime, inOrOut, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -0,0 +1,91 @@
+package ingredients
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Inspection struct{}
+
+var ins Inspection
+
+func InitInspectionRouter(router *gin.RouterGroup) {
+	inspectionRouter := router.Group("inspection")
+
+	inspectionRouter.GET("pendingList", ins.pendingList)
+	inspectionRouter.GET("list", ins.list)
+	inspectionRouter.GET("returnList", ins.returnList)
+	inspectionRouter.POST("inspect", ins.inspect)
+}
+
+// pendingList 待检验入库列表
+func (*Inspection) pendingList(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	name := c.DefaultQuery("name", "")
+	supplier := c.DefaultQuery("supplier", "")
+
+	data, err := service.GetPendingInspectionList(name, supplier, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// list 检验记录列表
+func (*Inspection) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	inBoundId := utils.DefaultQueryInt(c, "inBoundId", 0)
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetInspectionList(inBoundId, begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// returnList 退供应商列表
+func (*Inspection) returnList(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	supplier := c.DefaultQuery("supplier", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetReturnList(supplier, begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// inspect 录入检验结果
+func (*Inspection) inspect(c *gin.Context) {
+	inspection := &models.IngredientInspection{}
+	if err := c.ShouldBindJSON(inspection); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	inspection.Operator = c.GetString("userName")
+	if inspection.Inspector == "" {
+		inspection.Inspector = inspection.Operator
+	}
+	err := service.InspectInBound(inspection)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
@@ -22,8 +22,9 @@ func (*Stock) list(c *gin.Context) {
 	pn, pSize := utils.ParsePaginationParams(c)
 	name := c.DefaultQuery("name", "")
 	isPackage := utils.DefaultQueryInt(c, "isPackage", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetStockList(name, pn, pSize, isPackage)
+	data, err := service.GetStockList(name, pn, pSize, isPackage, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
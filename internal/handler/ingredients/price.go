@@ -0,0 +1,104 @@
+package ingredients
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Price struct{}
+
+var pr Price
+
+func InitPriceRouter(router *gin.RouterGroup) {
+	priceRouter := router.Group("price")
+
+	priceRouter.GET("history", pr.history)
+	priceRouter.GET("deviation", pr.deviation)
+	priceRouter.GET("exportHistory", pr.exportHistory)
+	priceRouter.GET("exportDeviation", pr.exportDeviation)
+}
+
+// history 配料采购价格走势和供应商对比
+func (*Price) history(c *gin.Context) {
+	ids := c.DefaultQuery("ids", "")
+	supplier := c.DefaultQuery("supplier", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetPriceHistory(ids, supplier, begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// deviation 配料采购价格异常
+func (*Price) deviation(c *gin.Context) {
+	ids := c.DefaultQuery("ids", "")
+	supplier := c.DefaultQuery("supplier", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+	window := utils.DefaultQueryInt(c, "window", 5)
+	threshold := utils.DefaultQueryFloat(c, "threshold", 20)
+
+	data, err := service.GetPriceDeviation(ids, supplier, begTime, endTime, window, threshold)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Price) exportHistory(c *gin.Context) {
+	ids := c.DefaultQuery("ids", "")
+	supplier := c.DefaultQuery("supplier", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.ExportPriceHistory(ids, supplier, begTime, endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="配料采购价格.xlsx"`)
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// 将 Excel 文件写入到 HTTP 响应中
+	if err = data.Write(c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "文件生成失败")
+		return
+	}
+}
+
+func (*Price) exportDeviation(c *gin.Context) {
+	ids := c.DefaultQuery("ids", "")
+	supplier := c.DefaultQuery("supplier", "")
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+	window := utils.DefaultQueryInt(c, "window", 5)
+	threshold := utils.DefaultQueryFloat(c, "threshold", 20)
+
+	data, err := service.ExportPriceDeviation(ids, supplier, begTime, endTime, window, threshold)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="配料价格异常.xlsx"`)
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// 将 Excel 文件写入到 HTTP 响应中
+	if err = data.Write(c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "文件生成失败")
+		return
+	}
+}
@@ -0,0 +1,70 @@
+package job
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Job struct{}
+
+var j Job
+
+func InitJobRouter(router *gin.RouterGroup) {
+	jobRouter := router.Group("job")
+
+	jobRouter.GET("list", j.list)
+	jobRouter.GET("runList", j.runList)
+	jobRouter.POST("run", j.run)
+}
+
+// list 定时任务列表
+func (*Job) list(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	data, err := service.GetJobList(userId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// runList 定时任务执行记录
+func (*Job) runList(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	name := c.DefaultQuery("name", "")
+	status := utils.DefaultQueryInt(c, "status", -1)
+	userId := c.GetInt("userId")
+
+	data, err := service.GetJobRunList(name, status, pn, pSize, userId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// run 手动执行定时任务
+func (*Job) run(c *gin.Context) {
+	req := &struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	userId := c.GetInt("userId")
+	data, err := service.RunJob(req.Name, userId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
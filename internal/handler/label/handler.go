@@ -0,0 +1,90 @@
+package label
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Label struct{}
+
+var l Label
+
+func InitLabelRouter(router *gin.RouterGroup) {
+	labelRouter := router.Group("label")
+
+	labelRouter.GET("info", l.info)
+	labelRouter.GET("png", l.png)
+	labelRouter.GET("pdf", l.pdf)
+
+	router.GET("scan/:code", l.scan)
+}
+
+// info 标签信息 type: IB配料入库批次 FS成品 PD产品 OP订单产品
+func (*Label) info(c *gin.Context) {
+	labelType := c.DefaultQuery("type", "")
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.GetLabel(labelType, id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// png 单个标签图片 format: code128 qr
+func (*Label) png(c *gin.Context) {
+	labelType := c.DefaultQuery("type", "")
+	id := utils.DefaultQueryInt(c, "id", 0)
+	format := c.DefaultQuery("format", "code128")
+
+	label, err := service.GetLabel(labelType, id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	data, err := service.GetLabelPng(label.Code, format)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// pdf 标签打印页 ids以;分隔
+func (*Label) pdf(c *gin.Context) {
+	labelType := c.DefaultQuery("type", "")
+	ids := c.DefaultQuery("ids", "")
+	format := c.DefaultQuery("format", "code128")
+	copies := utils.DefaultQueryInt(c, "copies", 1)
+
+	data, err := service.ExportLabelPdf(labelType, ids, format, copies)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", `attachment; filename="label.pdf"`)
+
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// scan 扫码查询对应数据及当前库存
+func (*Label) scan(c *gin.Context) {
+	code := c.Param("code")
+
+	data, err := service.ScanCode(code)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
@@ -22,6 +22,8 @@ func InitOrderRouter(router *gin.RouterGroup) {
 	orderRouter.GET("fields", o.fields)
 	orderRouter.GET("export", o.export)
 	orderRouter.GET("exportExecl", o.exportExecl)
+	orderRouter.GET("mrp", o.mrp)
+	orderRouter.GET("exportMrp", o.exportMrp)
 	orderRouter.POST("add", o.add)
 	orderRouter.POST("update", o.update)
 	orderRouter.POST("checkoutOrder", o.checkoutOrder)
@@ -140,6 +142,7 @@ func (*Order) outOfStock(c *gin.Context) {
 	type outStock struct {
 		OrderId        int `form:"orderId" json:"orderId" binding:"required"`
 		OrderProductId int `form:"orderProductId" json:"orderProductId" binding:"required"`
+		LocationId     int `form:"locationId" json:"locationId"`
 	}
 	var o outStock
 	if err := c.ShouldBindJSON(&o); err != nil {
@@ -151,7 +154,7 @@ func (*Order) outOfStock(c *gin.Context) {
 	userIdStr := c.GetString("userName")
 	userId, _ := strconv.Atoi(userIdStr)
 	operator := c.GetString("userName")
-	err := service.OutOfStock(o.OrderId, o.OrderProductId, userId, operator)
+	err := service.OutOfStock(o.OrderId, o.OrderProductId, o.LocationId, userId, operator)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -227,3 +230,36 @@ func (*Order) exportExecl(c *gin.Context) {
 		return
 	}
 }
+
+// mrp 待出库订单物料需求计算
+func (*Order) mrp(c *gin.Context) {
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetMrp(endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Order) exportMrp(c *gin.Context) {
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.ExportMrp(endTime)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="物料需求.xlsx"`)
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// 将 Excel 文件写入到 HTTP 响应中
+	if err = data.Write(c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "文件生成失败")
+		return
+	}
+}
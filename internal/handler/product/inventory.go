@@ -21,6 +21,8 @@ func InitInventoryRouter(router *gin.RouterGroup) {
 	inventoryRouter.GET("getAmount", i.getAmount)
 	inventoryRouter.POST("add", i.add)
 	inventoryRouter.POST("update", i.update)
+	inventoryRouter.POST("disassemble", i.disassemble)
+	inventoryRouter.GET("disassemblyList", i.disassemblyList)
 }
 
 func (*Inventory) list(c *gin.Context) {
@@ -31,6 +33,7 @@ func (*Inventory) list(c *gin.Context) {
 		},
 		ProductId:     utils.DefaultQueryInt(c, "productId", 0),
 		ProductIdList: c.DefaultQuery("productIdList", ""),
+		LocationId:    utils.DefaultQueryInt(c, "locationId", 0),
 	}
 	data, err := service.GetProductInventoryList(inventory, pn, pSize)
 	if err != nil {
@@ -48,8 +51,9 @@ func (*Inventory) outList(c *gin.Context) {
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
 	inOrOut := utils.DefaultQueryInt(c, "inOrOut", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetProductConsumeOutList(ids, stockUnit, begTime, endTime, inOrOut, pn, pSize)
+	data, err := service.GetProductConsumeOutList(ids, stockUnit, begTime, endTime, inOrOut, pn, pSize, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -64,8 +68,9 @@ func (*Inventory) inventorySum(c *gin.Context) {
 	begTime := c.DefaultQuery("begTime", "")
 	endTime := c.DefaultQuery("endTime", "")
 	inOrOut := utils.DefaultQueryInt(c, "inOrOut", 0)
+	locationId := utils.DefaultQueryInt(c, "locationId", 0)
 
-	data, err := service.GetInventorySum(ids, stockUnit, begTime, endTime, inOrOut)
+	data, err := service.GetInventorySum(ids, stockUnit, begTime, endTime, inOrOut, locationId)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -120,3 +125,37 @@ func (*Inventory) update(c *gin.Context) {
 
 	handler.Success(c, nil)
 }
+
+// disassemble 拆解产品库存批次
+func (*Inventory) disassemble(c *gin.Context) {
+	disassembly := &models.ProductDisassembly{}
+	if err := c.ShouldBindJSON(disassembly); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	disassembly.Operator = c.GetString("userName")
+	data, err := service.DisassembleProduct(disassembly)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+// disassemblyList 产品拆解记录
+func (*Inventory) disassemblyList(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	productId := utils.DefaultQueryInt(c, "productId", 0)
+	inventoryId := utils.DefaultQueryInt(c, "inventoryId", 0)
+
+	data, err := service.GetDisassemblyList(productId, inventoryId, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
@@ -17,6 +17,7 @@ func InitProductRouter(router *gin.RouterGroup) {
 
 	productRouter.GET("list", p.list)
 	productRouter.GET("fields", p.fields)
+	productRouter.GET("price", p.price)
 	productRouter.POST("add", p.add)
 	productRouter.POST("update", p.update)
 	productRouter.POST("delete", p.delete)
@@ -30,7 +31,8 @@ func (*Product) list(c *gin.Context) {
 		},
 		Name: c.DefaultQuery("name", ""),
 	}
-	data, err := service.GetProductList(product, pn, pSize)
+	customerId := utils.DefaultQueryInt(c, "customerId", 0)
+	data, err := service.GetProductList(product, customerId, pn, pSize)
 	if err != nil {
 		handler.InternalServerError(c, err)
 		return
@@ -103,3 +105,17 @@ func (*Product) fields(c *gin.Context) {
 
 	handler.Success(c, data)
 }
+
+// price 产品标准成本以及建议售价
+func (*Product) price(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+	customerId := utils.DefaultQueryInt(c, "customerId", 0)
+
+	data, err := service.GetProductPrice(id, customerId)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
@@ -0,0 +1,11 @@
+package warehouse
+
+import "github.com/gin-gonic/gin"
+
+func InitAllWarehouseRouter(router *gin.RouterGroup) {
+	warehouseRouter := router.Group("warehouse")
+
+	InitWarehouseRouter(warehouseRouter)
+	InitLocationRouter(warehouseRouter)
+	InitTransferRouter(warehouseRouter)
+}
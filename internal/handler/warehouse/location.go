@@ -0,0 +1,90 @@
+package warehouse
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Location struct{}
+
+var l Location
+
+func InitLocationRouter(router *gin.RouterGroup) {
+	locationRouter := router.Group("location")
+
+	locationRouter.GET("list", l.list)
+	locationRouter.POST("add", l.add)
+	locationRouter.POST("update", l.update)
+	locationRouter.POST("delete", l.delete)
+}
+
+func (*Location) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	location := &models.Location{
+		WarehouseId: utils.DefaultQueryInt(c, "warehouseId", 0),
+		Name:        c.DefaultQuery("name", ""),
+	}
+	data, err := service.GetLocationList(location, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Location) add(c *gin.Context) {
+	location := &models.Location{}
+	if err := c.ShouldBindJSON(location); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	location.Operator = c.GetString("userName")
+	data, err := service.SaveLocation(location)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Location) update(c *gin.Context) {
+	location := &models.Location{}
+	if err := c.ShouldBindJSON(location); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	location.Operator = c.GetString("userName")
+	data, err := service.UpdateLocation(location)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Location) delete(c *gin.Context) {
+	location := &models.Location{}
+	if err := c.ShouldBindJSON(location); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.DelLocation(location.ID)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
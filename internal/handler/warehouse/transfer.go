@@ -0,0 +1,169 @@
+package warehouse
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Transfer struct{}
+
+var t Transfer
+
+func InitTransferRouter(router *gin.RouterGroup) {
+	transferRouter := router.Group("transfer")
+
+	transferRouter.GET("list", t.list)
+	transferRouter.GET("listById", t.listById)
+	transferRouter.GET("export", t.export)
+	transferRouter.POST("add", t.add)
+	transferRouter.POST("update", t.update)
+	transferRouter.POST("void", t.void)
+	transferRouter.POST("ship", t.ship)
+	transferRouter.POST("receive", t.receive)
+}
+
+func (*Transfer) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	transfer := &models.StockTransfer{
+		TransferNumber: c.DefaultQuery("transferNumber", ""),
+		FromLocationId: utils.DefaultQueryInt(c, "fromLocationId", 0),
+		ToLocationId:   utils.DefaultQueryInt(c, "toLocationId", 0),
+		Status:         utils.DefaultQueryInt(c, "status", 0),
+	}
+	begTime := c.DefaultQuery("begTime", "")
+	endTime := c.DefaultQuery("endTime", "")
+
+	data, err := service.GetTransferList(transfer, begTime, endTime, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Transfer) listById(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.GetTransferById(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Transfer) add(c *gin.Context) {
+	transfer := &models.StockTransfer{}
+	if err := c.ShouldBindJSON(transfer); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	transfer.Operator = c.GetString("userName")
+	data, err := service.SaveTransfer(transfer)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Transfer) update(c *gin.Context) {
+	transfer := &models.StockTransfer{}
+	if err := c.ShouldBindJSON(transfer); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	transfer.Operator = c.GetString("userName")
+	data, err := service.UpdateTransfer(transfer)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Transfer) void(c *gin.Context) {
+	transfer := &models.StockTransfer{}
+	if err := c.ShouldBindJSON(transfer); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.VoidTransfer(transfer.ID, c.GetString("userName"))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
+
+// ship 调拨发货
+func (*Transfer) ship(c *gin.Context) {
+	transfer := &models.StockTransfer{}
+	if err := c.ShouldBindJSON(transfer); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.ShipTransfer(transfer.ID, c.GetString("userName"))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
+
+// receive 调拨收货
+func (*Transfer) receive(c *gin.Context) {
+	receive := &models.ReceiveTransfer{}
+	if err := c.ShouldBindJSON(receive); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.ReceiveTransfer(receive, c.GetString("userName"))
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
+
+// export 导出调拨单
+func (*Transfer) export(c *gin.Context) {
+	id := utils.DefaultQueryInt(c, "id", 0)
+
+	data, err := service.ExportTransfer(id)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="调拨单.xlsx"`)
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// 将 Excel 文件写入到 HTTP 响应中
+	if err = data.Write(c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "文件生成失败")
+		return
+	}
+}
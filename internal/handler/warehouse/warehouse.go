@@ -0,0 +1,89 @@
+package warehouse
+
+import (
+	"github.com/gin-gonic/gin"
+	"warehouse_oa/internal/handler"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/service"
+	"warehouse_oa/utils"
+)
+
+type Warehouse struct{}
+
+var w Warehouse
+
+func InitWarehouseRouter(router *gin.RouterGroup) {
+	warehouseRouter := router.Group("warehouse")
+
+	warehouseRouter.GET("list", w.list)
+	warehouseRouter.POST("add", w.add)
+	warehouseRouter.POST("update", w.update)
+	warehouseRouter.POST("delete", w.delete)
+}
+
+func (*Warehouse) list(c *gin.Context) {
+	pn, pSize := utils.ParsePaginationParams(c)
+	warehouse := &models.Warehouse{
+		Name: c.DefaultQuery("name", ""),
+	}
+	data, err := service.GetWarehouseList(warehouse, pn, pSize)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Warehouse) add(c *gin.Context) {
+	warehouse := &models.Warehouse{}
+	if err := c.ShouldBindJSON(warehouse); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	warehouse.Operator = c.GetString("userName")
+	data, err := service.SaveWarehouse(warehouse)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Warehouse) update(c *gin.Context) {
+	warehouse := &models.Warehouse{}
+	if err := c.ShouldBindJSON(warehouse); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	warehouse.Operator = c.GetString("userName")
+	data, err := service.UpdateWarehouse(warehouse)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, data)
+}
+
+func (*Warehouse) delete(c *gin.Context) {
+	warehouse := &models.Warehouse{}
+	if err := c.ShouldBindJSON(warehouse); err != nil {
+		// 如果解析失败，返回 400 错误和错误信息
+		handler.BadRequest(c, err.Error())
+		return
+	}
+
+	err := service.DelWarehouse(warehouse.ID)
+	if err != nil {
+		handler.InternalServerError(c, err)
+		return
+	}
+
+	handler.Success(c, nil)
+}
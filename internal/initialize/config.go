@@ -1,6 +1,10 @@
 package initialize
 
-import "warehouse_oa/internal/global"
+import (
+	"os"
+	"strconv"
+	"warehouse_oa/internal/global"
+)
 
 func InitConfig() error {
 	global.ServerConfig.MysqlInfo.Host = "8.138.155.131"
@@ -9,5 +13,28 @@ func InitConfig() error {
 	global.ServerConfig.MysqlInfo.Password = "ware123456"
 	global.ServerConfig.MysqlInfo.DbName = "warehouse_oa"
 
+	// 文件存储 默认本地磁盘 可通过环境变量切换为 S3 兼容存储
+	storageInfo := &global.ServerConfig.StorageInfo
+	storageInfo.Type = getEnv("STORAGE_TYPE", "local")
+	storageInfo.LocalDir = getEnv("STORAGE_LOCAL_DIR", "./cos")
+	// 访问地址前缀 未设置时生成 /images/... 相对地址
+	storageInfo.PublicUrl = getEnv("STORAGE_PUBLIC_URL", "")
+	storageInfo.SignKey = getEnv("STORAGE_SIGN_KEY", "")
+	storageInfo.UrlExpire, _ = strconv.Atoi(getEnv("STORAGE_URL_EXPIRE", "3600"))
+	storageInfo.S3.Endpoint = getEnv("S3_ENDPOINT", "")
+	storageInfo.S3.Region = getEnv("S3_REGION", "us-east-1")
+	storageInfo.S3.Bucket = getEnv("S3_BUCKET", "")
+	storageInfo.S3.AccessKey = getEnv("S3_ACCESS_KEY", "")
+	storageInfo.S3.SecretKey = getEnv("S3_SECRET_KEY", "")
+
 	return nil
 }
+
+// getEnv 获取环境变量 未设置时返回默认值
+func getEnv(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return def
+}
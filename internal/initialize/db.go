@@ -55,9 +55,15 @@ func InitDb() error {
 }
 
 func migration() {
+	// 订单编号+快递单号由普通索引改为唯一索引
+	dropIndexIfNotUnique(&models.ECommBill{}, "idx_order_tracking")
+	dropIndexIfNotUnique(&models.FastBill{}, "idx_order_tracking")
+
 	err := global.Db.Set("gorm:table_options", "charset=utf8mb4").AutoMigrate(
 		&models.Customer{},
 		&models.IngredientInBound{},
+		&models.IngredientInspection{},
+		&models.IngredientReturn{},
 		&models.IngredientStock{},
 		&models.Ingredients{},
 		&models.Order{},
@@ -66,24 +72,69 @@ func migration() {
 		&models.Finished{},
 		&models.FinishedStock{},
 		&models.FinishedMaterial{},
+		&models.FinishedSubMaterial{},
+		&models.FinishedBom{},
+		&models.FinishedBomMaterial{},
+		&models.FinishedBomSubMaterial{},
 		&models.Role{},
 		&models.User{},
 		&models.ECommBill{},
+		&models.ECommProductMapping{},
+		&models.BillReconcile{},
+		&models.ExpressClaim{},
 		&models.ECommCustomers{},
 		&models.FastBill{},
+		&models.ImportProfile{},
+		&models.ImportProfileColumn{},
 		&models.Gallery{},
+		&models.GalleryRef{},
+		&models.GalleryAlbum{},
 		&models.Product{},
 		&models.AddIngredient{},
 		&models.UseFinished{},
 		&models.IngredientConsume{},
 		&models.ProductContent{},
 		&models.FinishedProduction{},
+		&models.FinishedCompletion{},
+		&models.ProductionVariance{},
 		&models.FinishedConsume{},
+		&models.FinishedAdjust{},
 		&models.ProductInventory{},
 		&models.InventoryContent{},
 		&models.ProductConsume{},
+		&models.ProductDisassembly{},
+		&models.ProductDisassemblyLine{},
+		&models.Warehouse{},
+		&models.Location{},
+		&models.StockTransfer{},
+		&models.StockTransferLine{},
+		&models.StockTransferLot{},
+		&models.JobRun{},
 	)
 	if err != nil {
 		logrus.Error("migration err: ", err.Error())
 	}
 }
+
+// dropIndexIfNotUnique 删除同名的普通索引 AutoMigrate 按名称判断索引已存在 不会改为唯一索引
+func dropIndexIfNotUnique(model interface{}, name string) {
+	migrator := global.Db.Migrator()
+	if !migrator.HasTable(model) {
+		return
+	}
+	indexes, err := migrator.GetIndexes(model)
+	if err != nil {
+		logrus.Error("migration err: ", err.Error())
+		return
+	}
+	for _, index := range indexes {
+		if index.Name() != name {
+			continue
+		}
+		if unique, ok := index.Unique(); ok && !unique {
+			if err = migrator.DropIndex(model, name); err != nil {
+				logrus.Error("migration err: ", err.Error())
+			}
+		}
+	}
+}
@@ -2,22 +2,32 @@ package initialize
 
 import (
 	"github.com/gin-gonic/gin"
+	"path/filepath"
 	"warehouse_oa/internal/handler/customer"
 	"warehouse_oa/internal/handler/ecomm"
+	"warehouse_oa/internal/handler/file"
 	"warehouse_oa/internal/handler/finished"
 	"warehouse_oa/internal/handler/gallery"
 	"warehouse_oa/internal/handler/ingredients"
+	"warehouse_oa/internal/handler/job"
+	"warehouse_oa/internal/handler/label"
 	"warehouse_oa/internal/handler/order"
 	"warehouse_oa/internal/handler/product"
 	"warehouse_oa/internal/handler/user"
 	v1 "warehouse_oa/internal/handler/v1"
+	"warehouse_oa/internal/handler/warehouse"
 	"warehouse_oa/internal/middlewares"
+	"warehouse_oa/internal/storage"
 )
 
 func InitRouters() *gin.Engine {
 	Router := gin.Default()
-	Router.Static("/images", "./cos/images")
+	// 本地存储时图库图片通过静态路由访问
+	if local, ok := storage.Default.(*storage.Local); ok {
+		Router.Static("/images", filepath.Join(local.Dir(), "images"))
+	}
 	Router.Use(middlewares.Cors())
+	file.InitSignedFileRouter(&Router.RouterGroup)
 
 	apiGroup := Router.Group("/api/v1")
 	user.InitLoginRouter(apiGroup)
@@ -35,6 +45,10 @@ func InitRouters() *gin.Engine {
 		order.InitOrderRouter(group)
 		ecomm.InitECommerceRouter(group)
 		product.InitAllProductRouter(group)
+		warehouse.InitAllWarehouseRouter(group)
+		label.InitLabelRouter(group)
+		job.InitJobRouter(group)
+		file.InitFileRouter(group)
 		v1.InitV1Router(group)
 	}
 
@@ -0,0 +1,10 @@
+package initialize
+
+import (
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/storage"
+)
+
+func InitStorage() error {
+	return storage.Init(global.ServerConfig.StorageInfo)
+}
@@ -0,0 +1,45 @@
+package models
+
+// ImportResult 账单导入结果
+type ImportResult struct {
+	DryRun    bool             `json:"dryRun"`    // 预览 不写入数据
+	Total     int              `json:"total"`     // 数据行数
+	Valid     int              `json:"valid"`     // 校验通过行数
+	Invalid   int              `json:"invalid"`   // 校验失败行数
+	Duplicate int              `json:"duplicate"` // 重复行数
+	Inserted  int              `json:"inserted"`  // 写入行数
+	Errors    []ImportRowError `json:"errors"`
+	ErrorFile string           `json:"errorFile"` // 错误明细文件名 通过 importError 接口下载
+	Preview   interface{}      `json:"preview"`   // 预览时返回校验通过的数据
+}
+
+// ImportRowError 导入失败的数据行
+type ImportRowError struct {
+	Row     int               `json:"row"` // 文件中的行号
+	Message string            `json:"message"`
+	Data    map[string]string `json:"data"`
+}
+
+// ImportProfile 账单导入模板 定义表头别名 日期格式以及取值转换 内置模板不保存在数据库
+type ImportProfile struct {
+	BaseModel
+	Code        string                `gorm:"type:varchar(64);uniqueIndex" json:"code"`
+	Name        string                `gorm:"type:varchar(100);not null" json:"name"`
+	BillType    int                   `gorm:"type:int(2);not null" json:"billType"` // 1:电商账单 2:快递账单
+	DateFormats string                `gorm:"type:varchar(512)" json:"dateFormats"` // 日期格式 ;分隔
+	Columns     []ImportProfileColumn `gorm:"foreignKey:ProfileId;references:ID" json:"columns"`
+
+	Builtin        bool     `gorm:"-" json:"builtin"`
+	DateFormatList []string `gorm:"-" json:"dateFormatList"`
+}
+
+// ImportProfileColumn 导入模板列 Field 为标准列名
+type ImportProfileColumn struct {
+	ProfileId  int    `gorm:"primaryKey;index" json:"profileId"`
+	Field      string `gorm:"primaryKey;type:varchar(64)" json:"field"`
+	Aliases    string `gorm:"type:varchar(512)" json:"aliases"`    // 表头别名 ;分隔
+	Transforms string `gorm:"type:varchar(256)" json:"transforms"` // 取值转换 ;分隔
+
+	AliasList     []string `gorm:"-" json:"aliasList"`
+	TransformList []string `gorm:"-" json:"transformList"`
+}
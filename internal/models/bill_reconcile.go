@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// BillReconcile 电商账单与快递账单对账结果 按订单编号+快递单号汇总
+type BillReconcile struct {
+	BaseModel
+	OrderNumber    string    `gorm:"type:varchar(100);index:idx_order_tracking" json:"orderNumber"`
+	TrackingNumber string    `gorm:"type:varchar(100);index:idx_order_tracking" json:"trackingNumber"`
+	ShopId         *int      `gorm:"type:int(11);index" json:"shopId"`              // 电商账单店铺ID 没有电商账单或未关联店铺时为空
+	ShopName       string    `gorm:"type:varchar(100);index" json:"shopName"`       // 电商账单客户名称 没有电商账单时为空
+	BillAmount     int       `gorm:"type:int(11);default:0" json:"billAmount"`      // 电商账单数量
+	FastAmount     int       `gorm:"type:int(11);default:0" json:"fastAmount"`      // 快递账单数量
+	PayAmount      float64   `gorm:"type:decimal(10,2);default:0" json:"payAmount"` // 快递赔付金额
+	BillTime       time.Time `gorm:"type:Time;index" json:"billTime"`               // 发货时间 没有电商账单时为快递账单创建时间
+	Type           int       `gorm:"type:int(2);index" json:"type"`                 // 1 已匹配 2 账单无快递 3 快递无账单 4 数量不符
+}
+
+// BillCompensation 快递赔付汇总
+type BillCompensation struct {
+	ShopId    *int    `json:"shopId"`
+	ShopName  string  `json:"shopName"`
+	Period    string  `json:"period"`
+	Count     int     `json:"count"`
+	PayAmount float64 `json:"payAmount"`
+}
@@ -7,4 +7,6 @@ type Customer struct {
 	Phone    string `gorm:"type:varchar(256);not null" json:"phone"`
 	Email    string `gorm:"type:varchar(256);not null" json:"email"`
 	Salesman string `gorm:"type:varchar(256);not null" json:"salesman"` // 销售人员
+	// 客户目标毛利率(%) 为0时使用产品目标毛利率
+	MarginRate float64 `gorm:"type:decimal(6,2);default:0" json:"marginRate"`
 }
@@ -4,11 +4,49 @@ import "time"
 
 type ECommBill struct {
 	BaseModel
-	Name           string    `gorm:"type:varchar(100);not null" json:"name"`
-	OrderNumber    string    `gorm:"type:varchar(100);not null" json:"orderNumber"`
-	TrackingNumber string    `gorm:"type:varchar(100);not null" json:"trackingNumber"`
-	Title          string    `gorm:"type:varchar(100);not null" json:"title"`
-	Specification  string    `gorm:"type:varchar(100);not null" json:"specification"`
-	Amount         int       `gorm:"type:int(11);not null" json:"amount"`
-	DeliveryTime   time.Time `gorm:"type:Time;not null" json:"deliveryTime"`
+	Name           string          `gorm:"type:varchar(100);not null" json:"name"`
+	ShopId         *int            `gorm:"type:int(11);index" json:"shopId"` // 店铺ID
+	Shop           *ECommCustomers `gorm:"foreignKey:ShopId;" json:"shop"`
+	OrderNumber    string          `gorm:"type:varchar(100);not null;uniqueIndex:idx_order_tracking" json:"orderNumber"`
+	TrackingNumber string          `gorm:"type:varchar(100);not null;uniqueIndex:idx_order_tracking" json:"trackingNumber"`
+	Title          string          `gorm:"type:varchar(100);not null" json:"title"`
+	Specification  string          `gorm:"type:varchar(100);not null" json:"specification"`
+	Amount         int             `gorm:"type:int(11);not null" json:"amount"`
+	ReturnAmount   int             `gorm:"type:int(11);default:0" json:"returnAmount"` // 退货数量
+	DeliveryTime   time.Time       `gorm:"type:Time;not null" json:"deliveryTime"`
+	ProductId      *int            `gorm:"type:int(11);index" json:"productId"` // 匹配的产品ID
+	Product        *Product        `gorm:"foreignKey:ProductId;" json:"product"`
+	Quantity       int             `gorm:"type:int(11);default:0" json:"quantity"`     // 出库产品数量 (数量-退货数量)×映射件数
+	Status         int             `gorm:"type:int(11);default:0;index" json:"status"` // 0 待匹配 1 已匹配 2 已出库
+}
+
+// ECommProductMapping 电商商品映射 平台商品标题+销售规格对应产品
+type ECommProductMapping struct {
+	BaseModel
+	Title         string   `gorm:"uniqueIndex:idx_title_specification;type:varchar(100)" json:"title"`
+	Specification string   `gorm:"uniqueIndex:idx_title_specification;type:varchar(100)" json:"specification"`
+	ProductId     int      `gorm:"type:int(11);not null" json:"productId"`
+	Product       *Product `gorm:"foreignKey:ProductId;" json:"product"`
+	Quantity      int      `gorm:"type:int(11);default:1" json:"quantity"` // 每件对应产品数量
+}
+
+// ECommBillReview 待匹配账单 按商品标题+销售规格汇总
+type ECommBillReview struct {
+	Title         string `json:"title"`
+	Specification string `json:"specification"`
+	BillCount     int    `json:"billCount"`
+	Amount        int    `json:"amount"`
+}
+
+// ECommSalesStat 电商销售统计 按店铺 产品 周期汇总
+type ECommSalesStat struct {
+	ShopId      int     `json:"shopId"`
+	ShopName    string  `json:"shopName"`
+	ProductId   int     `json:"productId"`
+	ProductName string  `json:"productName"`
+	Period      string  `json:"period"`
+	Units       int     `json:"units"`       // 销售数量
+	Orders      int     `json:"orders"`      // 订单数
+	ReturnUnits int     `json:"returnUnits"` // 退货数量
+	ReturnRate  float64 `json:"returnRate"`  // 退货率(%)
 }
@@ -4,4 +4,6 @@ type ECommCustomers struct {
 	BaseModel
 	Name     string `gorm:"type:varchar(100);not null" json:"name"`
 	ShopName string `gorm:"type:varchar(100);not null" json:"shopName"`
+	// 账单导入模板编码 为空时使用默认模板
+	ImportProfile string `gorm:"type:varchar(64);default:''" json:"importProfile"`
 }
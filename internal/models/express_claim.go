@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ExpressClaim 快递赔付申请 1 已立案 2 已提交快递 3 已审核通过 4 已赔付 5 已拒绝
+type ExpressClaim struct {
+	BaseModel
+	FastBillId     int        `gorm:"type:int(11);index" json:"fastBillId"`
+	FastBill       *FastBill  `gorm:"foreignKey:FastBillId;" json:"fastBill"`
+	Carrier        string     `gorm:"type:varchar(100);not null;index" json:"carrier"` // 快递公司
+	Reason         string     `gorm:"type:varchar(256);not null" json:"reason"`
+	Status         int        `gorm:"type:int(2);default:1;index" json:"status"`
+	ExpectedAmount float64    `gorm:"type:decimal(10,2);default:0" json:"expectedAmount"` // 申请赔付金额
+	ReceivedAmount float64    `gorm:"type:decimal(10,2);default:0" json:"receivedAmount"` // 实际到账金额
+	OpenTime       time.Time  `gorm:"type:Time" json:"openTime"`
+	SubmitTime     *time.Time `gorm:"type:Time" json:"submitTime"`
+	ApproveTime    *time.Time `gorm:"type:Time" json:"approveTime"`
+	PayTime        *time.Time `gorm:"type:Time" json:"payTime"`
+	RejectTime     *time.Time `gorm:"type:Time" json:"rejectTime"`
+	Images         string     `gorm:"type:text" json:"images"` // 凭证图片 图库图片
+
+	ImageList []string `gorm:"-" json:"imageList"`
+}
+
+// ExpressClaimOutstanding 快递公司未结赔付汇总
+type ExpressClaimOutstanding struct {
+	Carrier        string     `json:"carrier"`
+	Count          int        `json:"count"`
+	ExpectedAmount float64    `json:"expectedAmount"`
+	ReceivedAmount float64    `json:"receivedAmount"`
+	OldestTime     *time.Time `json:"oldestTime"` // 最早立案时间
+}
@@ -2,11 +2,13 @@ package models
 
 type FastBill struct {
 	BaseModel
-	OrderNumber    string  `gorm:"type:varchar(100);not null" json:"orderNumber"`
-	TrackingNumber string  `gorm:"type:varchar(100);not null" json:"trackingNumber"`
+	OrderNumber    string  `gorm:"type:varchar(100);not null;uniqueIndex:idx_order_tracking" json:"orderNumber"`
+	TrackingNumber string  `gorm:"type:varchar(100);not null;uniqueIndex:idx_order_tracking" json:"trackingNumber"`
 	Title          string  `gorm:"type:varchar(100);not null" json:"title"`
 	Specification  string  `gorm:"type:varchar(100);not null" json:"specification"`
 	Amount         int     `gorm:"type:int(11);not null" json:"amount"`
 	Status         int     `gorm:"type:int(2);not null" json:"status"`
 	PayAmount      float64 `gorm:"type:decimal(10,2)" json:"payAmount"`
+	ClaimStatus    int     `gorm:"type:int(2);default:0" json:"claimStatus"`      // 最新快递赔付状态 0 无赔付
+	ClaimPaid      float64 `gorm:"type:decimal(10,2);default:0" json:"claimPaid"` // 快递赔付已赔付金额合计
 }
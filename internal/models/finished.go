@@ -4,8 +4,12 @@ import "time"
 
 type Finished struct {
 	BaseModel
-	Name     string             `gorm:"type:varchar(256);not null;unique" json:"name"`
-	Material []FinishedMaterial `gorm:"foreignKey:FinishedId;references:ID" json:"material"`
+	Name          string                `gorm:"type:varchar(256);not null;unique" json:"name"`
+	Material      []FinishedMaterial    `gorm:"foreignKey:FinishedId;references:ID" json:"material"`
+	SubMaterial   []FinishedSubMaterial `gorm:"foreignKey:FinishedId;references:ID" json:"subMaterial"` // 半成品用料
+	DailyCapacity int                   `gorm:"type:int(11);default:0" json:"dailyCapacity"`            // 每日产能 0表示不限制
+
+	EffectiveTime *time.Time `gorm:"-" json:"effectiveTime"` // 配方生效时间 默认立即生效
 }
 
 type FinishedMaterial struct {
@@ -16,6 +20,57 @@ type FinishedMaterial struct {
 	Quantity     float64      `gorm:"type:decimal(10,4);not null" json:"quantity"` // 用量
 }
 
+// FinishedSubMaterial 成品使用的半成品(其他成品)
+type FinishedSubMaterial struct {
+	FinishedId    int       `gorm:"primaryKey;index" json:"finishedId"`
+	SubFinishedId int       `gorm:"primaryKey;type:int(11)" json:"subFinishedId"`
+	SubFinished   *Finished `gorm:"foreignKey:SubFinishedId" json:"subFinished"`
+	Quantity      float64   `gorm:"type:decimal(10,4);not null" json:"quantity"` // 用量
+}
+
+// FinishedBom 成品配方版本
+type FinishedBom struct {
+	BaseModel
+	FinishedId    int                      `gorm:"type:int(11);uniqueIndex:idx_finished_version" json:"finishedId"`
+	Version       int                      `gorm:"type:int(11);uniqueIndex:idx_finished_version" json:"version"`
+	EffectiveTime time.Time                `gorm:"type:Time" json:"effectiveTime"`
+	Material      []FinishedBomMaterial    `gorm:"foreignKey:BomId;references:ID" json:"material"`
+	SubMaterial   []FinishedBomSubMaterial `gorm:"foreignKey:BomId;references:ID" json:"subMaterial"`
+
+	Cost float64 `gorm:"-" json:"cost"`
+}
+
+// FinishedBomMaterial 配方版本用料
+type FinishedBomMaterial struct {
+	BomId        int          `gorm:"primaryKey;index" json:"bomId"`
+	IngredientId int          `gorm:"primaryKey;type:int(11)" json:"ingredientId"`
+	Ingredient   *Ingredients `gorm:"foreignKey:IngredientId" json:"ingredient"`
+	StockUnit    int          `gorm:"type:int(2)" json:"stockUnit"`
+	Quantity     float64      `gorm:"type:decimal(10,4);not null" json:"quantity"` // 用量
+}
+
+// FinishedBomSubMaterial 配方版本半成品用料
+type FinishedBomSubMaterial struct {
+	BomId         int       `gorm:"primaryKey;index" json:"bomId"`
+	SubFinishedId int       `gorm:"primaryKey;type:int(11)" json:"subFinishedId"`
+	SubFinished   *Finished `gorm:"foreignKey:SubFinishedId" json:"subFinished"`
+	Quantity      float64   `gorm:"type:decimal(10,4);not null" json:"quantity"` // 用量
+}
+
+// BomCompareLine 配方版本对比明细
+type BomCompareLine struct {
+	IngredientId   int     `json:"ingredientId"`
+	IngredientName string  `json:"ingredientName"`
+	SubFinishedId  int     `json:"subFinishedId"` // 半成品ID 为0表示配料
+	StockUnit      int     `json:"stockUnit"`
+	UnitPrice      float64 `json:"unitPrice"` // 配料为最近入库单价 半成品为配方成本
+	FromQuantity   float64 `json:"fromQuantity"`
+	ToQuantity     float64 `json:"toQuantity"`
+	FromCost       float64 `json:"fromCost"`
+	ToCost         float64 `json:"toCost"`
+	Difference     float64 `json:"difference"`
+}
+
 type FinishedProduction struct {
 	BaseModel
 	FinishedId         int        `gorm:"type:int(11)" json:"finishedId"`
@@ -27,9 +82,16 @@ type FinishedProduction struct {
 	EstimatedTime      *time.Time `gorm:"type:Time" json:"estimatedTime"`
 	FinishTime         *time.Time `gorm:"type:Time" json:"finishTime"`
 	ProductIngredients string     `gorm:"type:Text;not null" json:"productIngredients"`
+	LocationId         int        `gorm:"type:int(11);default:0" json:"locationId"`   // 完工入库库位ID
+	BomId              int        `gorm:"type:int(11);default:0" json:"bomId"`        // 使用的配方版本ID
+	PlanStartTime      *time.Time `gorm:"type:Time" json:"planStartTime"`             // 计划开始时间
+	PlanEndTime        *time.Time `gorm:"type:Time" json:"planEndTime"`               // 计划结束时间
+	UserList           []User     `gorm:"many2many:production_user;" json:"userList"` // 生产人员
 
-	FinishHour int     `gorm:"-" json:"finishHour"`
-	Cost       float64 `gorm:"-" json:"cost"`
+	FinishHour int               `gorm:"-" json:"finishHour"`
+	Cost       float64           `gorm:"-" json:"cost"`
+	Warnings   []string          `gorm:"-" json:"warnings"` // 排产超负荷提示
+	Usage      []ProductionUsage `gorm:"-" json:"usage"`    // 完工时实际配料用量 为空时按配方标准用量
 }
 
 type FinishedStock struct {
@@ -37,6 +99,7 @@ type FinishedStock struct {
 	FinishedId int       `gorm:"type:int(11)" json:"finishedId"`
 	Finished   *Finished `gorm:"foreignKey:FinishedId;" json:"finished"`
 	Amount     float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	LocationId int       `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
 
 	ProductionId int `gorm:"-" json:"productionId"`
 }
@@ -51,8 +114,80 @@ type FinishedConsume struct {
 	Finished   *Finished `gorm:"foreignKey:FinishedId;" json:"finished"`
 	// 产品Id
 	ProductId int `gorm:"type:int(11);default:0" json:"productId"`
+	// 报工ID 生产消耗半成品时记录
+	ProductionId *int `gorm:"type:int(11)" json:"productionId"`
+	// 库存调整ID
+	AdjustId *int `gorm:"type:int(11)" json:"adjustId"`
+	// 产品库存批次ID 组装产品消耗成品以及拆解返还时记录
+	InventoryId *int `gorm:"type:int(11);index" json:"inventoryId"`
+	// 电商账单ID 账单出库时记录
+	BillId *int `gorm:"type:int(11);index" json:"billId"`
+	// 出入库类型 0=常规出入库 1=库存调整
+	ConsumeType int `gorm:"type:int(2);default:0;index" json:"consumeType"`
 
 	StockNum         float64 `gorm:"type:decimal(16,4)" json:"stockNum"`
 	OperationType    *bool   `gorm:"type:bool;default:true" json:"operationType"` // true 表示启用，false 表示禁用
 	OperationDetails string  `gorm:"type:varchar(256)" json:"operationDetails"`
+	LocationId       int     `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
+}
+
+// FinishedAdjust 成品库存调整记录 盘盈为正数 盘亏为负数
+type FinishedAdjust struct {
+	BaseModel
+	FinishedId   int       `gorm:"type:int(11);index" json:"finishedId"`
+	Finished     *Finished `gorm:"foreignKey:FinishedId;" json:"finished"`
+	LocationId   int       `gorm:"type:int(11);default:0" json:"locationId"` // 库位ID
+	AdjustNum    float64   `gorm:"type:decimal(10,2);not null" json:"adjustNum"`
+	BeforeAmount float64   `gorm:"type:decimal(10,2)" json:"beforeAmount"` // 调整前库存
+	AfterAmount  float64   `gorm:"type:decimal(10,2)" json:"afterAmount"`  // 调整后库存
+	Reason       string    `gorm:"type:varchar(256);not null" json:"reason"`
+	Images       string    `gorm:"type:text" json:"images"` // 附件图片
+
+	ImageList []string `gorm:"-" json:"imageList"`
+}
+
+// FinishedCompletion 报工完工记录 一个报工可多次完工
+type FinishedCompletion struct {
+	BaseModel
+	ProductionId int       `gorm:"type:int(11);index" json:"productionId"`
+	Amount       int       `gorm:"type:int(11);not null" json:"amount"`
+	LocationId   int       `gorm:"type:int(11);default:0" json:"locationId"` // 完工入库库位ID
+	CompleteTime time.Time `gorm:"type:Time" json:"completeTime"`
+}
+
+// ProductionUsage 完工时登记的配料实际用量
+type ProductionUsage struct {
+	IngredientId int     `json:"ingredientId"`
+	StockUnit    int     `json:"stockUnit"`
+	StockNum     float64 `json:"stockNum"`
+}
+
+// ProductionVariance 报工结案时的配料差异
+type ProductionVariance struct {
+	BaseModel
+	ProductionId int          `gorm:"type:int(11);index" json:"productionId"`
+	IngredientId int          `gorm:"type:int(11)" json:"ingredientId"`
+	Ingredient   *Ingredients `gorm:"foreignKey:IngredientId" json:"ingredient"`
+	StockUnit    int          `gorm:"type:int(2)" json:"stockUnit"`
+	StandardNum  float64      `gorm:"type:decimal(16,4)" json:"standardNum"`  // 配方标准用量
+	ActualNum    float64      `gorm:"type:decimal(16,4)" json:"actualNum"`    // 实际消耗
+	VarianceNum  float64      `gorm:"type:decimal(16,4)" json:"varianceNum"`  // 实际-标准
+	UnitPrice    float64      `gorm:"type:decimal(12,4)" json:"unitPrice"`    // 计算单价
+	VarianceCost float64      `gorm:"type:decimal(12,2)" json:"varianceCost"` // 差异金额
+}
+
+// ProductionCalendarDay 排产日历
+type ProductionCalendarDay struct {
+	Date        string                `json:"date"`
+	Productions []*FinishedProduction `json:"productions"`
+	Load        []*FinishedLoad       `json:"load"`
+}
+
+// FinishedLoad 成品当日计划产量
+type FinishedLoad struct {
+	FinishedId    int     `json:"finishedId"`
+	FinishedName  string  `json:"finishedName"`
+	Planned       float64 `json:"planned"`       // 当日计划产量
+	DailyCapacity int     `json:"dailyCapacity"` // 每日产能
+	Overbooked    bool    `json:"overbooked"`    // 是否超负荷
 }
@@ -2,6 +2,38 @@ package models
 
 type Gallery struct {
 	BaseModel
-	Name string `gorm:"type:varchar(256)" json:"name"`
-	Url  string `gorm:"type:varchar(500)" json:"url"`
+	Name     string `gorm:"type:varchar(256)" json:"name"`
+	Url      string `gorm:"type:varchar(500)" json:"url"`
+	Thumb    string `gorm:"type:varchar(500);default:''" json:"thumb"`  // 缩略图文件名
+	Medium   string `gorm:"type:varchar(500);default:''" json:"medium"` // 中图文件名
+	MimeType string `gorm:"type:varchar(64);default:''" json:"mimeType"`
+	Width    int    `gorm:"type:int(11);default:0" json:"width"`
+	Height   int    `gorm:"type:int(11);default:0" json:"height"`
+	Size     int    `gorm:"type:int(11);default:0" json:"size"` // 原图字节数
+	// 所属相册 相册 产品 客户删除后置空
+	AlbumId    *int          `gorm:"type:int(11);index" json:"albumId"`
+	Album      *GalleryAlbum `gorm:"foreignKey:AlbumId;constraint:OnDelete:SET NULL;" json:"album"`
+	ProductId  *int          `gorm:"type:int(11);index" json:"productId"`
+	Product    *Product      `gorm:"foreignKey:ProductId;constraint:OnDelete:SET NULL;" json:"product"`
+	CustomerId *int          `gorm:"type:int(11);index" json:"customerId"`
+	Customer   *Customer     `gorm:"foreignKey:CustomerId;constraint:OnDelete:SET NULL;" json:"customer"`
+	Tags       string        `gorm:"type:varchar(500);default:''" json:"tags"` // 标签 ; 分隔
+
+	TagList []string `gorm:"-" json:"tagList"`
+}
+
+// GalleryAlbum 图库相册
+type GalleryAlbum struct {
+	BaseModel
+	Name  string `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
+	Sort  int    `gorm:"type:int(11);default:0" json:"sort"`
+	Count int    `gorm:"-" json:"count"` // 图片数量
+}
+
+// GalleryRef 图库图片引用 ref_type 为 order_product finished_adjust ingredient_inspection express_claim
+type GalleryRef struct {
+	BaseModel
+	GalleryId int    `gorm:"type:int(11);index" json:"galleryId"`
+	RefType   string `gorm:"type:varchar(32);index:idx_ref" json:"refType"`
+	RefId     int    `gorm:"type:int(11);index:idx_ref" json:"refId"`
 }
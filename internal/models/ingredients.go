@@ -25,6 +25,8 @@ type IngredientInBound struct {
 	StockUser      string       `gorm:"type:varchar(256)" json:"stockUser"`
 	StockTime      time.Time    `gorm:"type:Time" json:"stockTime"`
 	IsPackage      int          `gorm:"type:int(11);default:0" json:"isPackage"`
+	LocationId     int          `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
+	InspectStatus  int          `gorm:"type:int(11);default:0" json:"inspectStatus"`    // 0:无需检验 1:待检验 2:已检验
 }
 
 type IngredientStock struct {
@@ -34,6 +36,7 @@ type IngredientStock struct {
 	StockNum     float64      `gorm:"type:decimal(16,4)" json:"stockNum"`
 	StockUnit    int          `gorm:"type:int(2)" json:"stockUnit"`
 	IsPackage    int          `gorm:"type:int(11);default:0" json:"isPackage"`
+	LocationId   int          `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
 
 	InBoundId *int `gorm:"-" json:"inBoundId"`
 }
@@ -54,11 +57,43 @@ type IngredientConsume struct {
 	OperationType    *bool               `gorm:"type:bool" json:"operationType"` // true表示启用，false表示禁用
 	OperationDetails string              `gorm:"type:varchar(256)" json:"operationDetails"`
 	IsPackage        int                 `gorm:"type:int(11);default:0" json:"isPackage"`
+	LocationId       int                 `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
 
 	// 返回参数
 	Cost float64 `gorm:"-" json:"cost"`
 }
 
+// IngredientInspection 配料来料检验
+type IngredientInspection struct {
+	BaseModel
+	InBoundId   int                `gorm:"type:int(11);index" json:"inBoundId"`
+	InBound     *IngredientInBound `gorm:"foreignKey:InBoundId" json:"inBound"`
+	ReceivedNum float64            `gorm:"type:decimal(16,4)" json:"receivedNum"` // 到货数量
+	AcceptedNum float64            `gorm:"type:decimal(16,4)" json:"acceptedNum"` // 合格数量
+	RejectedNum float64            `gorm:"type:decimal(16,4)" json:"rejectedNum"` // 不合格数量
+	Inspector   string             `gorm:"type:varchar(256)" json:"inspector"`
+	InspectTime time.Time          `gorm:"type:Time" json:"inspectTime"`
+	Images      string             `gorm:"type:text" json:"images"` // 图片列表
+
+	// 请求参数
+	ImageList []string `gorm:"-" json:"imageList"`
+}
+
+// IngredientReturn 配料退供应商
+type IngredientReturn struct {
+	BaseModel
+	InBoundId    int                `gorm:"type:int(11);index" json:"inBoundId"`
+	InBound      *IngredientInBound `gorm:"foreignKey:InBoundId" json:"inBound"`
+	IngredientId *int               `gorm:"type:int(11)" json:"ingredientId"`
+	Ingredient   *Ingredients       `gorm:"foreignKey:IngredientId" json:"ingredient"`
+	Supplier     string             `gorm:"type:varchar(256); DEFAULT ''" json:"supplier"`
+	StockNum     float64            `gorm:"type:decimal(16,4)" json:"stockNum"`
+	StockUnit    int                `gorm:"type:int(2)" json:"stockUnit"`
+	UnitPrice    float64            `gorm:"type:decimal(12,2)" json:"unitPrice"`
+	DebitPrice   float64            `gorm:"type:decimal(12,2)" json:"debitPrice"` // 冲减应付金额
+	ReturnTime   time.Time          `gorm:"type:Time" json:"returnTime"`
+}
+
 // 返回数据
 
 // GetInBoundList 配料入库列表查询数据
@@ -82,6 +117,8 @@ type GetInBoundList struct {
 	StockUnit       int                 `json:"stockUnit"`
 	StockUser       string              `json:"stockUser"`
 	StockTime       time.Time           `json:"stockTime"`
+	LocationId      int                 `json:"locationId"`
+	InspectStatus   int                 `json:"inspectStatus"`
 	FinishPriceList []map[string]string `json:"finishPriceList"`
 }
 
@@ -103,3 +140,32 @@ type FinishInBound struct {
 	PaymentTime string  `json:"paymentTime"`
 	Operator    string  `json:"operator"`
 }
+
+// IngredientPricePoint 配料采购价格走势点
+type IngredientPricePoint struct {
+	InBoundId       int       `json:"inBoundId"`
+	IngredientId    int       `json:"ingredientId"`
+	IngredientName  string    `json:"ingredientName"`
+	Supplier        string    `json:"supplier"`
+	UnitPrice       float64   `json:"unitPrice"`       // 原始单价
+	StockUnit       int       `json:"stockUnit"`       // 原始单位
+	NormalPrice     float64   `json:"normalPrice"`     // 换算后单价
+	NormalUnit      int       `json:"normalUnit"`      // 换算后单位
+	StockNum        float64   `json:"stockNum"`        // 入库数量
+	StockTime       time.Time `json:"stockTime"`       // 入库时间
+	TrailingAverage float64   `json:"trailingAverage"` // 前N次入库均价
+	Deviation       float64   `json:"deviation"`       // 偏离百分比
+}
+
+// SupplierPriceStat 供应商价格对比
+type SupplierPriceStat struct {
+	IngredientId   int     `json:"ingredientId"`
+	IngredientName string  `json:"ingredientName"`
+	Supplier       string  `json:"supplier"`
+	NormalUnit     int     `json:"normalUnit"`
+	MinPrice       float64 `json:"minPrice"`
+	AvgPrice       float64 `json:"avgPrice"`
+	MaxPrice       float64 `json:"maxPrice"`
+	Count          int     `json:"count"`
+	TotalNum       float64 `json:"totalNum"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// JobRun 定时任务执行记录
+type JobRun struct {
+	BaseModel
+	JobName   string     `gorm:"type:varchar(64);index" json:"jobName"`
+	Manual    bool       `gorm:"default:false" json:"manual"` // 是否手动执行
+	StartTime time.Time  `gorm:"type:Time" json:"startTime"`
+	EndTime   *time.Time `gorm:"type:Time" json:"endTime"`
+	Status    int        `gorm:"type:int(2)" json:"status"` // 0 执行中 1 成功 2 失败
+	Message   string     `gorm:"type:Text" json:"message"`
+}
+
+// JobInfo 定时任务信息
+type JobInfo struct {
+	Name     string     `json:"name"`
+	Spec     string     `json:"spec"`
+	Desc     string     `json:"desc"`
+	Running  bool       `json:"running"`
+	NextTime *time.Time `json:"nextTime"`
+	LastRun  *JobRun    `json:"lastRun"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// Label 标签信息
+type Label struct {
+	Code string `json:"code"` // 条码内容 如 IB-1
+	Type string `json:"type"` // IB:配料入库批次 FS:成品 PD:产品 OP:订单产品
+	Name string `json:"name"`
+	Desc string `json:"desc"`
+}
+
+// ScanResult 扫码查询结果
+type ScanResult struct {
+	Label
+	Entity interface{}     `json:"entity"`
+	Stock  []LocationStock `json:"stock"`
+	Total  float64         `json:"total"`
+}
+
+// LocationStock 库位库存
+type LocationStock struct {
+	LocationId   int     `json:"locationId"`
+	LocationName string  `json:"locationName"`
+	StockUnit    int     `json:"stockUnit"`
+	Amount       float64 `json:"amount"`
+}
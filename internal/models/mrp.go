@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// MrpProduct 产品需求
+type MrpProduct struct {
+	ProductId     int       `json:"productId"`
+	ProductName   string    `json:"productName"`
+	Specification string    `json:"specification"`
+	Required      float64   `json:"required"`      // 订单需求数量
+	Stock         float64   `json:"stock"`         // 产品库存
+	Shortfall     float64   `json:"shortfall"`     // 缺口数量
+	ShortfallDate time.Time `json:"shortfallDate"` // 最早缺货日期(订单销售日期)
+}
+
+// MrpProduction 建议报工
+type MrpProduction struct {
+	FinishedId    int       `json:"finishedId"`
+	FinishedName  string    `json:"finishedName"`
+	Required      float64   `json:"required"`      // 需求数量
+	Stock         float64   `json:"stock"`         // 成品库存
+	InProduction  float64   `json:"inProduction"`  // 生产中数量
+	Shortfall     float64   `json:"shortfall"`     // 缺口数量
+	SuggestAmount int       `json:"suggestAmount"` // 建议报工数量
+	ShortfallDate time.Time `json:"shortfallDate"`
+	OrderNumbers  []string  `json:"orderNumbers"` // 缺货订单
+}
+
+// MrpPurchase 建议采购
+type MrpPurchase struct {
+	IngredientId   int       `json:"ingredientId"`
+	IngredientName string    `json:"ingredientName"`
+	StockUnit      int       `json:"stockUnit"`
+	Required       float64   `json:"required"`  // 需求数量
+	Stock          float64   `json:"stock"`     // 配料库存
+	Shortfall      float64   `json:"shortfall"` // 建议采购数量
+	ShortfallDate  time.Time `json:"shortfallDate"`
+	Supplier       string    `json:"supplier"`  // 最近一次入库供应商
+	UnitPrice      float64   `json:"unitPrice"` // 最近一次入库单价
+}
+
+// MrpSkipped 无法展开的订单产品 如产品或成品已删除
+type MrpSkipped struct {
+	OrderNumber string `json:"orderNumber"`
+	ProductId   int    `json:"productId"`
+	FinishedId  int    `json:"finishedId"`
+	Reason      string `json:"reason"`
+}
@@ -29,6 +29,8 @@ type OrderProduct struct {
 	ProductNameDesc string          `gorm:"type:varchar(256);not null" json:"productNameDesc"`
 	Specification   string          `gorm:"type:varchar(256)" json:"specification"`
 	Price           float64         `gorm:"type:decimal(10,2)" json:"price"`
+	StandardCost    float64         `gorm:"type:decimal(10,2);default:0" json:"standardCost"` // 下单时产品标准成本
+	SuggestPrice    float64         `gorm:"type:decimal(10,2);default:0" json:"suggestPrice"` // 下单时建议售价
 	Amount          int             `gorm:"type:int(11);not null" json:"amount"`
 	Images          string          `gorm:"type:text" json:"images"`                       // 图片列表
 	UserList        []User          `gorm:"many2many:order_product_user;" json:"userList"` // 订单分配
@@ -39,6 +41,7 @@ type OrderProduct struct {
 
 	// 请求参数
 	ImageList []string `gorm:"-" json:"imageList"`
+	ThumbList []string `gorm:"-" json:"thumbList"` // 图片缩略图地址
 }
 
 type AddIngredient struct {
@@ -5,6 +5,10 @@ type Product struct {
 	Name           string           `gorm:"uniqueIndex:idx_name_specification;type:varchar(256)" json:"name"`
 	Specification  string           `gorm:"uniqueIndex:idx_name_specification;type:varchar(256)" json:"specification"`
 	ProductContent []ProductContent `gorm:"foreignKey:ProductId;references:ID" json:"productContent"`
+	MarginRate     float64          `gorm:"type:decimal(6,2);default:0" json:"marginRate"` // 目标毛利率(%)
+
+	Cost         float64 `gorm:"-" json:"cost"`         // 标准成本 按成品配方成本汇总
+	SuggestPrice float64 `gorm:"-" json:"suggestPrice"` // 建议售价
 }
 
 type ProductContent struct {
@@ -20,6 +24,9 @@ type ProductInventory struct {
 	Product          *Product           `gorm:"foreignKey:ProductId;" json:"product"`
 	Amount           int                `gorm:"type:int(11);not null" json:"amount"`
 	InventoryContent []InventoryContent `gorm:"foreignKey:InventoryId;" json:"inventoryContent"`
+	LocationId       int                `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
+	// 调拨生成的批次记录最初组装的批次ID 拆解时按该批次的成品出库记录返还
+	SourceId *int `gorm:"type:int(11)" json:"sourceId"`
 	// 记录产品使用的成品ID和数量
 
 	ProductIdList string `gorm:"-" json:"productIdList" form:"productIdList"`
@@ -31,10 +38,34 @@ type InventoryContent struct {
 	Quantity    float64 `gorm:"type:decimal(10,4);not null" json:"quantity"` // 用量
 }
 
+// ProductDisassembly 产品拆解记录 拆解产品库存批次返还成品
+type ProductDisassembly struct {
+	BaseModel
+	InventoryId int                      `gorm:"type:int(11);index" json:"inventoryId"` // 产品库存批次ID
+	ProductId   int                      `gorm:"type:int(11)" json:"productId"`
+	Product     *Product                 `gorm:"foreignKey:ProductId;" json:"product"`
+	Amount      int                      `gorm:"type:int(11);not null" json:"amount"`
+	LocationId  int                      `gorm:"type:int(11);default:0" json:"locationId"` // 返还成品库位ID
+	Lines       []ProductDisassemblyLine `gorm:"foreignKey:DisassemblyId;" json:"lines"`
+}
+
+// ProductDisassemblyLine 拆解返还明细 记录返还的成品出库记录(成品批次)以及数量
+type ProductDisassemblyLine struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	DisassemblyId   int       `gorm:"index" json:"disassemblyId"`
+	ConsumeId       int       `gorm:"type:int(11);index" json:"consumeId"` // 组装产品时的成品出库记录ID 没有出库记录按批次组成返还时为0
+	FinishedId      int       `gorm:"type:int(11)" json:"finishedId"`
+	Finished        *Finished `gorm:"foreignKey:FinishedId;" json:"finished"`
+	Amount          float64   `gorm:"type:decimal(16,4);not null" json:"amount"`
+	ReturnConsumeId int       `gorm:"type:int(11)" json:"returnConsumeId"` // 返还生成的成品入库记录ID
+}
+
 type ProductConsume struct {
 	BaseModel
 	// 订单ID
 	OrderId *int `gorm:"type:int(11)" json:"orderId"`
+	// 电商账单ID
+	BillId *int `gorm:"type:int(11);index" json:"billId"`
 	// 产品Id
 	ProductId int      `gorm:"type:int(11);default:0" json:"productId"`
 	Product   *Product `gorm:"foreignKey:ProductId;" json:"product"`
@@ -42,6 +73,7 @@ type ProductConsume struct {
 	StockNum         float64 `gorm:"type:decimal(16,4)" json:"stockNum"`
 	OperationType    *bool   `gorm:"type:bool;default:true" json:"operationType"` // true 表示启用，false 表示禁用
 	OperationDetails string  `gorm:"type:varchar(256)" json:"operationDetails"`
+	LocationId       int     `gorm:"type:int(11);default:0;index" json:"locationId"` // 库位ID
 
 	ProductIdList string `gorm:"-" json:"productIdList" form:"productIdList"`
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type StockTransfer struct {
+	BaseModel
+	TransferNumber string               `gorm:"type:varchar(256);not null" json:"transferNumber"`
+	FromLocationId int                  `gorm:"type:int(11);not null" json:"fromLocationId"` // 调出库位
+	FromLocation   *Location            `gorm:"foreignKey:FromLocationId" json:"fromLocation"`
+	ToLocationId   int                  `gorm:"type:int(11);not null" json:"toLocationId"` // 调入库位
+	ToLocation     *Location            `gorm:"foreignKey:ToLocationId" json:"toLocation"`
+	Status         int                  `gorm:"type:int(11);not null" json:"status"` // 1:草稿 2:在途 3:已收货 4:部分收货 5:作废
+	ShipTime       *time.Time           `gorm:"type:Time" json:"shipTime"`
+	ReceiveTime    *time.Time           `gorm:"type:Time" json:"receiveTime"`
+	Lines          []*StockTransferLine `gorm:"foreignKey:TransferId;references:ID" json:"lines"`
+}
+
+type StockTransferLine struct {
+	ID             int     `gorm:"primaryKey" json:"id"`
+	TransferId     int     `gorm:"index" json:"transferId"`
+	ItemType       int     `gorm:"type:int(2);not null" json:"itemType"` // 1:配料 2:成品 3:产品
+	ItemId         int     `gorm:"type:int(11);not null" json:"itemId"`  // 配料ID 成品ID 产品ID
+	StockUnit      int     `gorm:"type:int(2)" json:"stockUnit"`         // 配料单位
+	InBoundId      *int    `gorm:"type:int(11)" json:"inBoundId"`        // 指定调出的配料入库批次 为空时先进先出
+	Amount         float64 `gorm:"type:decimal(16,4);not null" json:"amount"`
+	ReceivedAmount float64 `gorm:"type:decimal(16,4);default:0" json:"receivedAmount"`
+	UnitCost       float64 `gorm:"type:decimal(12,4);default:0" json:"unitCost"` // 调出时的单位成本 多个批次时为加权平均
+
+	Lots     []*StockTransferLot `gorm:"foreignKey:LineId;references:ID" json:"lots"`
+	ItemName string              `gorm:"-" json:"itemName"`
+}
+
+// StockTransferLot 调拨批次明细 发货时按实际扣减的批次记录 收货时按批次入库
+type StockTransferLot struct {
+	ID             int     `gorm:"primaryKey" json:"id"`
+	LineId         int     `gorm:"index" json:"lineId"`
+	InBoundId      *int    `gorm:"type:int(11)" json:"inBoundId"`   // 配料入库批次
+	InventoryId    *int    `gorm:"type:int(11)" json:"inventoryId"` // 产品库存批次
+	Amount         float64 `gorm:"type:decimal(16,4);not null" json:"amount"`
+	ReceivedAmount float64 `gorm:"type:decimal(16,4);default:0" json:"receivedAmount"`
+	UnitCost       float64 `gorm:"type:decimal(12,4);default:0" json:"unitCost"`
+}
+
+// ReceiveTransfer 调拨收货参数
+type ReceiveTransfer struct {
+	ID    int `json:"id" binding:"required"`
+	Lines []struct {
+		LineId int     `json:"lineId"`
+		Amount float64 `json:"amount"`
+	} `json:"lines"` // 为空时收取全部剩余数量
+}
@@ -0,0 +1,16 @@
+package models
+
+type Warehouse struct {
+	BaseModel
+	Name     string     `gorm:"type:varchar(256);not null;unique" json:"name"`
+	Address  string     `gorm:"type:varchar(256)" json:"address"`
+	Location []Location `gorm:"foreignKey:WarehouseId;references:ID" json:"location"`
+}
+
+type Location struct {
+	BaseModel
+	WarehouseId int        `gorm:"uniqueIndex:idx_warehouse_name;type:int(11);not null" json:"warehouseId"`
+	Warehouse   *Warehouse `gorm:"foreignKey:WarehouseId" json:"warehouse"`
+	Name        string     `gorm:"uniqueIndex:idx_warehouse_name;type:varchar(256);not null" json:"name"` // 库位名称
+	Code        string     `gorm:"type:varchar(100)" json:"code"`                                         // 库位编码
+}
@@ -0,0 +1,40 @@
+package models
+
+// YieldFinished 成品良品率及配料差异汇总
+type YieldFinished struct {
+	FinishedId      int     `json:"finishedId"`
+	FinishedName    string  `json:"finishedName"`
+	ProductionCount int     `json:"productionCount"` // 报工次数
+	ExpectAmount    int     `json:"expectAmount"`    // 预计数量
+	ActualAmount    int     `json:"actualAmount"`    // 实际数量
+	Ratio           float64 `json:"ratio"`           // 良品率 实际/预计
+	StandardCost    float64 `json:"standardCost"`    // 配方标准用量成本
+	ActualCost      float64 `json:"actualCost"`      // 实际消耗成本
+	VarianceCost    float64 `json:"varianceCost"`    // 差异金额 实际-标准
+}
+
+// YieldIngredient 成品配料用量差异
+type YieldIngredient struct {
+	FinishedId     int     `json:"finishedId"`
+	FinishedName   string  `json:"finishedName"`
+	IngredientId   int     `json:"ingredientId"`
+	IngredientName string  `json:"ingredientName"`
+	StockUnit      int     `json:"stockUnit"`
+	StandardNum    float64 `json:"standardNum"`
+	ActualNum      float64 `json:"actualNum"`
+	VarianceNum    float64 `json:"varianceNum"`
+	VarianceRate   float64 `json:"varianceRate"` // 差异率 差异/标准
+	VarianceCost   float64 `json:"varianceCost"`
+}
+
+// YieldOperator 生产人员按月良品率趋势
+type YieldOperator struct {
+	UserId          int     `json:"userId"`
+	UserName        string  `json:"userName"`
+	Month           string  `json:"month"`
+	ProductionCount int     `json:"productionCount"`
+	ExpectAmount    int     `json:"expectAmount"`
+	ActualAmount    int     `json:"actualAmount"`
+	Ratio           float64 `json:"ratio"`
+	VarianceCost    float64 `json:"varianceCost"`
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
@@ -57,3 +58,9 @@ func GetUpdate(update, updateTime string) (int64, error) {
 
 	return num, nil
 }
+
+// isDuplicateKey 唯一索引冲突
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"io"
+	"math"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/storage"
+	"warehouse_oa/utils"
+)
+
+const importErrorDir = "import"
+
+var (
+	eCommBillHeaders  = []string{"客户名称", "主订单编号", "快递单号", "商品标题", "商品销售规格", "数量", "退货数量", "发货时间", "备注"}
+	eCommBillRequired = []string{"主订单编号", "商品标题", "数量", "发货时间"}
+	fastBillHeaders   = []string{"订单编号", "快递单号", "商品标题", "商品销售规格", "数量", "状态", "赔付金额", "备注"}
+	fastBillRequired  = []string{"订单编号", "快递单号", "数量"}
+
+	importTimeLayouts = []string{
+		"2006-01-02 15:04:05",
+		"2006/01/02 15:04:05",
+		"2006-01-02 15:04",
+		"2006/01/02 15:04",
+		"2006-1-2 15:04:05",
+		"2006/1/2 15:04:05",
+		"2006/1/2 15:04",
+		"2006-01-02",
+		"2006/01/02",
+		"2006-1-2",
+		"2006/1/2",
+	}
+)
+
+// billImport 账单导入 记录校验失败以及重复的数据行
+type billImport struct {
+	result  *models.ImportResult
+	headers []string
+	seen    map[string]bool
+}
+
+func newBillImport(total int, headers []string, dryRun bool) *billImport {
+	return &billImport{
+		result: &models.ImportResult{
+			DryRun: dryRun,
+			Total:  total,
+			Errors: make([]models.ImportRowError, 0),
+		},
+		headers: headers,
+		seen:    make(map[string]bool),
+	}
+}
+
+func (b *billImport) reject(row utils.XlsxRow, message string) {
+	b.result.Invalid++
+	b.result.Errors = append(b.result.Errors, models.ImportRowError{
+		Row:     row.Row,
+		Message: message,
+		Data:    row.Data,
+	})
+}
+
+func (b *billImport) duplicate(row utils.XlsxRow, message string) {
+	b.result.Duplicate++
+	b.result.Errors = append(b.result.Errors, models.ImportRowError{
+		Row:     row.Row,
+		Message: message,
+		Data:    row.Data,
+	})
+}
+
+// UploadECommBill 导入电商账单 按指定模板或店铺的模板识别表头 dryRun 为 true 时只校验不写入
+func UploadECommBill(file *multipart.FileHeader, username string, shopId int,
+	profileCode string, dryRun bool) (*models.ImportResult, error) {
+
+	var shop *models.ECommCustomers
+	var err error
+	if shopId > 0 {
+		shop, err = GetECommCustomersById(shopId)
+		if err != nil {
+			return nil, err
+		}
+		if profileCode == "" {
+			profileCode = shop.ImportProfile
+		}
+	}
+	profile, err := getImportProfile(profileCode, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := utils.UploadXlsx(file, eCommBillRequired, profileAlias(profile))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applyImportProfile(profile, row.Data)
+		// 文件中没有客户名称时使用店铺名称
+		if shop != nil && row.Data["客户名称"] == "" {
+			row.Data["客户名称"] = shop.Name
+		}
+	}
+
+	return importECommBill(rows, profile, shopId, username, dryRun)
+}
+
+// UploadFastBill 导入快递账单 dryRun 为 true 时只校验不写入
+func UploadFastBill(file *multipart.FileHeader, username, profileCode string,
+	dryRun bool) (*models.ImportResult, error) {
+
+	profile, err := getImportProfile(profileCode, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := utils.UploadXlsx(file, fastBillRequired, profileAlias(profile))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applyImportProfile(profile, row.Data)
+	}
+
+	return importFastBill(rows, username, dryRun)
+}
+
+// GetImportErrorFile 读取导入错误明细文件 返回文件名和内容
+func GetImportErrorFile(name string) (string, io.ReadCloser, error) {
+	name = filepath.Base(name)
+	if !strings.HasSuffix(name, ".xlsx") {
+		return "", nil, errors.New("文件名错误")
+	}
+	reader, err := storage.Default.Get(context.Background(), importErrorDir+"/"+name)
+	if errors.Is(err, storage.ErrNotExist) {
+		return "", nil, errors.New("文件不存在或已过期")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, reader, nil
+}
+
+func importECommBill(rows []utils.XlsxRow, profile *models.ImportProfile, shopId int,
+	username string, dryRun bool) (*models.ImportResult, error) {
+
+	layouts := profileDateLayouts(profile)
+	b := newBillImport(len(rows), eCommBillHeaders, dryRun)
+
+	billList := make([]models.ECommBill, 0)
+	billRows := make([]utils.XlsxRow, 0)
+	for _, row := range rows {
+		data := row.Data
+		errList := checkImportRequired(data, eCommBillRequired)
+		amount, err := parseImportInt(data["数量"])
+		if data["数量"] != "" && err != nil {
+			errList = append(errList, "数量格式错误")
+		}
+		if err == nil && amount <= 0 {
+			errList = append(errList, "数量必须大于0")
+		}
+		returnAmount, err := parseImportInt(data["退货数量"])
+		if data["退货数量"] != "" && (err != nil || returnAmount < 0) {
+			errList = append(errList, "退货数量格式错误")
+		}
+		if err == nil && returnAmount > amount {
+			errList = append(errList, "退货数量不能大于数量")
+		}
+		deliveryTime, err := parseImportTime(data["发货时间"], layouts...)
+		if data["发货时间"] != "" && err != nil {
+			errList = append(errList, "发货时间格式错误")
+		}
+		if len(errList) > 0 {
+			b.reject(row, strings.Join(errList, "；"))
+			continue
+		}
+
+		key := data["主订单编号"] + "|" + data["快递单号"]
+		if b.seen[key] {
+			b.duplicate(row, "文件内订单编号和快递单号重复")
+			continue
+		}
+		b.seen[key] = true
+
+		billList = append(billList, models.ECommBill{
+			BaseModel: models.BaseModel{
+				Operator: username,
+				Remark:   data["备注"],
+			},
+			Name:           data["客户名称"],
+			OrderNumber:    data["主订单编号"],
+			TrackingNumber: data["快递单号"],
+			Title:          data["商品标题"],
+			Specification:  data["商品销售规格"],
+			Amount:         amount,
+			ReturnAmount:   returnAmount,
+			DeliveryTime:   deliveryTime,
+		})
+		billRows = append(billRows, row)
+	}
+
+	// 与已导入数据去重
+	keys := make([][]interface{}, 0)
+	for _, bill := range billList {
+		keys = append(keys, []interface{}{bill.OrderNumber, bill.TrackingNumber})
+	}
+	exists, err := getExistsBillKeys(&models.ECommBill{}, keys)
+	if err != nil {
+		return nil, err
+	}
+	insertList := make([]models.ECommBill, 0)
+	insertRows := make([]utils.XlsxRow, 0)
+	for i, bill := range billList {
+		if exists[bill.OrderNumber+"|"+bill.TrackingNumber] {
+			b.duplicate(billRows[i], "订单编号和快递单号已导入")
+			continue
+		}
+		insertList = append(insertList, bill)
+		insertRows = append(insertRows, billRows[i])
+	}
+	// 按映射匹配产品 未匹配的进入待匹配
+	err = setBillMapping(insertList)
+	if err != nil {
+		return nil, err
+	}
+	err = setBillShop(insertList, shopId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.finish(insertRows, "电商账单", func(tx *gorm.DB, beg, end int) error {
+		list := insertList[beg:end]
+		return tx.Model(&models.ECommBill{}).CreateInBatches(&list, 100).Error
+	})
+	if dryRun {
+		b.result.Preview = insertList
+	}
+
+	return b.result, err
+}
+
+func importFastBill(rows []utils.XlsxRow, username string, dryRun bool) (*models.ImportResult, error) {
+	b := newBillImport(len(rows), fastBillHeaders, dryRun)
+
+	billList := make([]models.FastBill, 0)
+	billRows := make([]utils.XlsxRow, 0)
+	for _, row := range rows {
+		data := row.Data
+		errList := checkImportRequired(data, fastBillRequired)
+		amount, err := parseImportInt(data["数量"])
+		if data["数量"] != "" && err != nil {
+			errList = append(errList, "数量格式错误")
+		}
+		if err == nil && amount <= 0 {
+			errList = append(errList, "数量必须大于0")
+		}
+		var status int
+		if data["状态"] != "" {
+			status, err = parseImportInt(data["状态"])
+			if err != nil {
+				errList = append(errList, "状态格式错误")
+			}
+		}
+		var payAmount float64
+		if data["赔付金额"] != "" {
+			payAmount, err = parseImportFloat(data["赔付金额"])
+			if err != nil || payAmount < 0 {
+				errList = append(errList, "赔付金额格式错误")
+			}
+		}
+		if len(errList) > 0 {
+			b.reject(row, strings.Join(errList, "；"))
+			continue
+		}
+
+		key := data["订单编号"] + "|" + data["快递单号"]
+		if b.seen[key] {
+			b.duplicate(row, "文件内订单编号和快递单号重复")
+			continue
+		}
+		b.seen[key] = true
+
+		billList = append(billList, models.FastBill{
+			BaseModel: models.BaseModel{
+				Operator: username,
+				Remark:   data["备注"],
+			},
+			OrderNumber:    data["订单编号"],
+			TrackingNumber: data["快递单号"],
+			Title:          data["商品标题"],
+			Specification:  data["商品销售规格"],
+			Amount:         amount,
+			Status:         status,
+			PayAmount:      payAmount,
+		})
+		billRows = append(billRows, row)
+	}
+
+	keys := make([][]interface{}, 0)
+	for _, bill := range billList {
+		keys = append(keys, []interface{}{bill.OrderNumber, bill.TrackingNumber})
+	}
+	exists, err := getExistsBillKeys(&models.FastBill{}, keys)
+	if err != nil {
+		return nil, err
+	}
+	insertList := make([]models.FastBill, 0)
+	insertRows := make([]utils.XlsxRow, 0)
+	for i, bill := range billList {
+		if exists[bill.OrderNumber+"|"+bill.TrackingNumber] {
+			b.duplicate(billRows[i], "订单编号和快递单号已导入")
+			continue
+		}
+		insertList = append(insertList, bill)
+		insertRows = append(insertRows, billRows[i])
+	}
+
+	err = b.finish(insertRows, "快递账单", func(tx *gorm.DB, beg, end int) error {
+		list := insertList[beg:end]
+		return tx.Model(&models.FastBill{}).CreateInBatches(&list, 100).Error
+	})
+	if dryRun {
+		b.result.Preview = insertList
+	}
+
+	return b.result, err
+}
+
+// finish 非预览时在事务中写入数据 生成错误明细文件
+// insert 写入 rows[beg:end] 对应的数据
+func (b *billImport) finish(rows []utils.XlsxRow, name string, insert func(tx *gorm.DB, beg, end int) error) error {
+	b.result.Valid = len(rows)
+	if !b.result.DryRun && len(rows) > 0 {
+		err := b.insert(rows, insert)
+		if err != nil {
+			return err
+		}
+	}
+	if len(b.result.Errors) > 0 {
+		fileName, err := b.saveErrorFile(name)
+		if err != nil {
+			return err
+		}
+		b.result.ErrorFile = fileName
+	}
+
+	return nil
+}
+
+// insert 批量写入 同时导入相同账单触发唯一索引冲突时改为逐行写入 冲突的行记为重复
+func (b *billImport) insert(rows []utils.XlsxRow, insert func(tx *gorm.DB, beg, end int) error) error {
+	err := global.Db.Transaction(func(tx *gorm.DB) error {
+		return insert(tx, 0, len(rows))
+	})
+	if err == nil {
+		b.result.Inserted = len(rows)
+		return nil
+	}
+	if !isDuplicateKey(err) {
+		return err
+	}
+
+	inserted := 0
+	err = global.Db.Transaction(func(tx *gorm.DB) error {
+		for i, row := range rows {
+			err := insert(tx, i, i+1)
+			if isDuplicateKey(err) {
+				b.duplicate(row, "订单编号和快递单号已导入")
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			inserted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.result.Valid = inserted
+	b.result.Inserted = inserted
+
+	return nil
+}
+
+// saveErrorFile 保存导入失败的数据行以及原因
+func (b *billImport) saveErrorFile(name string) (string, error) {
+	keyList := append([]string{"行号"}, b.headers...)
+	keyList = append(keyList, "错误原因")
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, e := range b.result.Errors {
+		value := map[string]interface{}{
+			"行号":   e.Row,
+			"错误原因": e.Message,
+		}
+		for _, h := range b.headers {
+			value[h] = e.Data[h]
+		}
+		valueList = append(valueList, value)
+	}
+
+	redCol, err := excelize.ColumnNumberToName(len(keyList))
+	if err != nil {
+		return "", err
+	}
+	f, err := utils.ExportExcel(keyList, valueList, []string{redCol})
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return "", err
+	}
+	fileName := fmt.Sprintf("%s导入错误_%d.xlsx", name, time.Now().UnixNano())
+	key := importErrorDir + "/" + fileName
+	err = storage.Default.Put(context.Background(), key, buf, storage.ContentType(key))
+
+	return fileName, err
+}
+
+// getExistsBillKeys 查询已导入的 订单编号|快递单号
+func getExistsBillKeys(model interface{}, keys [][]interface{}) (map[string]bool, error) {
+	exists := make(map[string]bool)
+	for beg := 0; beg < len(keys); beg += 500 {
+		end := beg + 500
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		var found []struct {
+			OrderNumber    string
+			TrackingNumber string
+		}
+		err := global.Db.Model(model).Select("order_number, tracking_number").
+			Where("(order_number, tracking_number) IN ?", keys[beg:end]).Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range found {
+			exists[v.OrderNumber+"|"+v.TrackingNumber] = true
+		}
+	}
+
+	return exists, nil
+}
+
+func checkImportRequired(data map[string]string, required []string) []string {
+	errList := make([]string, 0)
+	for _, h := range required {
+		if data[h] == "" {
+			errList = append(errList, fmt.Sprintf("%s不能为空", h))
+		}
+	}
+
+	return errList
+}
+
+// parseImportTime 解析导入的时间 支持常见格式以及 Excel 日期序列号
+func parseImportTime(s string, layouts ...string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = importTimeLayouts
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 1 {
+		return excelize.ExcelDateToTime(f, false)
+	}
+
+	return time.Time{}, fmt.Errorf("时间格式错误: %s", s)
+}
+
+func parseImportInt(s string) (int, error) {
+	f, err := parseImportFloat(s)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("不是整数: %s", s)
+	}
+
+	return int(f), nil
+}
+
+func parseImportFloat(s string) (float64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	s = strings.TrimPrefix(s, "¥")
+
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseImportTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		layouts []string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "标准格式", value: "2024-03-05 08:09:10", want: time.Date(2024, 3, 5, 8, 9, 10, 0, time.Local)},
+		{name: "斜杠不补零", value: "2024/3/5 08:09", want: time.Date(2024, 3, 5, 8, 9, 0, 0, time.Local)},
+		{name: "只有日期", value: "2024-03-05", want: time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)},
+		{name: "Excel 日期序列号", value: "45292", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "Excel 日期时间序列号", value: "45292.5", want: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "指定格式", value: "05.03.2024", layouts: []string{"02.01.2006"},
+			want: time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)},
+		{name: "指定格式不匹配时仍支持序列号", value: "45292", layouts: []string{"02.01.2006"},
+			want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "序列号过小", value: "1", wantErr: true},
+		{name: "负数", value: "-45292", wantErr: true},
+		{name: "无法解析", value: "昨天", wantErr: true},
+		{name: "空值", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportTime(tt.value, tt.layouts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseImportTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "12", want: 12},
+		{value: " 12 ", want: 12},
+		{value: "1,200", want: 1200},
+		{value: "¥30", want: 30},
+		{value: "2.0", want: 2},
+		{value: "-3", want: -3},
+		{value: "2.5", wantErr: true},
+		{value: "2件", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseImportInt(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseImportInt(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseImportInt(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
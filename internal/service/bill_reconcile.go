@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// billKeySum 按订单编号+快递单号汇总的账单
+type billKeySum struct {
+	OrderNumber    string
+	TrackingNumber string
+	ShopId         *int
+	Name           string
+	Amount         int
+	PayAmount      float64
+	BillTime       time.Time
+}
+
+// GetBillReconcileList 获取对账结果列表
+func GetBillReconcileList(reconcileType, shopId int, shopName, orderNumber, begTime, endTime string,
+	pn, pSize int) (interface{}, error) {
+
+	db := global.Db.Model(&models.BillReconcile{})
+	if reconcileType > 0 {
+		db = db.Where("type = ?", reconcileType)
+	}
+	if shopId > 0 {
+		db = db.Where("shop_id = ?", shopId)
+	}
+	if shopName != "" {
+		db = db.Where("shop_name = ?", shopName)
+	}
+	if orderNumber != "" {
+		db = db.Where("order_number = ? or tracking_number = ?", orderNumber, orderNumber)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(bill_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+	db = db.Order("bill_time desc")
+
+	return Pagination(db, []models.BillReconcile{}, pn, pSize)
+}
+
+// GetBillReconcileSummary 获取对账结果各类型数量
+func GetBillReconcileSummary(begTime, endTime string) (interface{}, error) {
+	db := global.Db.Model(&models.BillReconcile{})
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(bill_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	var list []struct {
+		Type  int
+		Count int
+	}
+	err := db.Select("type, count(*) as count").Group("type").Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]int{
+		"matched":      0,
+		"noShipment":   0,
+		"noBill":       0,
+		"amountDiffer": 0,
+	}
+	for _, l := range list {
+		switch l.Type {
+		case 1:
+			data["matched"] = l.Count
+		case 2:
+			data["noShipment"] = l.Count
+		case 3:
+			data["noBill"] = l.Count
+		case 4:
+			data["amountDiffer"] = l.Count
+		}
+	}
+
+	return data, nil
+}
+
+// GetBillCompensation 按店铺和周期汇总快递赔付金额 period 为 day week month
+func GetBillCompensation(shopId int, period, begTime, endTime string) ([]models.BillCompensation, error) {
+	format, err := getPeriodFormat(period)
+	if err != nil {
+		return nil, err
+	}
+
+	db := global.Db.Model(&models.BillReconcile{}).Where("pay_amount <> 0")
+	if shopId > 0 {
+		db = db.Where("shop_id = ?", shopId)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(bill_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	data := make([]models.BillCompensation, 0)
+	err = db.Select("shop_id, max(shop_name) as shop_name, DATE_FORMAT(bill_time, ?) as period, "+
+		"count(*) as count, sum(pay_amount) as pay_amount", format).
+		Group("shop_id, period").
+		Order("period asc, shop_id asc").
+		Scan(&data).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 店铺名称使用店铺表的名称
+	shopIds := make([]int, 0)
+	for _, d := range data {
+		if d.ShopId != nil {
+			shopIds = append(shopIds, *d.ShopId)
+		}
+	}
+	var shopList []models.ECommCustomers
+	if len(shopIds) > 0 {
+		err = global.Db.Model(&models.ECommCustomers{}).Where("id in ?", shopIds).Find(&shopList).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	shopNames := make(map[int]string)
+	for _, shop := range shopList {
+		shopNames[shop.ID] = shop.Name
+	}
+	for i := range data {
+		if data[i].ShopId == nil {
+			data[i].ShopName = "未匹配"
+		} else if name, ok := shopNames[*data[i].ShopId]; ok {
+			data[i].ShopName = name
+		}
+	}
+
+	return data, nil
+}
+
+// billReconcileJob 电商账单与快递账单对账 每次全量重新生成对账结果
+func billReconcileJob(ctx context.Context) (string, error) {
+	db := global.Db.WithContext(ctx)
+
+	var billList []billKeySum
+	err := db.Model(&models.ECommBill{}).
+		Select("order_number, tracking_number, max(shop_id) as shop_id, max(name) as name, " +
+			"sum(amount) as amount, min(delivery_time) as bill_time").
+		Group("order_number, tracking_number").
+		Scan(&billList).Error
+	if err != nil {
+		return "", err
+	}
+
+	var fastList []billKeySum
+	err = db.Model(&models.FastBill{}).
+		Select("order_number, tracking_number, sum(amount) as amount, " +
+			"sum(pay_amount) as pay_amount, min(add_time) as bill_time").
+		Group("order_number, tracking_number").
+		Scan(&fastList).Error
+	if err != nil {
+		return "", err
+	}
+
+	fastMap := make(map[string]billKeySum)
+	for _, fast := range fastList {
+		fastMap[fast.OrderNumber+"|"+fast.TrackingNumber] = fast
+	}
+
+	count := make(map[int]int)
+	reconcileList := make([]models.BillReconcile, 0)
+	for _, bill := range billList {
+		key := bill.OrderNumber + "|" + bill.TrackingNumber
+		reconcile := models.BillReconcile{
+			BaseModel: models.BaseModel{
+				Operator: "system",
+			},
+			OrderNumber:    bill.OrderNumber,
+			TrackingNumber: bill.TrackingNumber,
+			ShopId:         bill.ShopId,
+			ShopName:       bill.Name,
+			BillAmount:     bill.Amount,
+			BillTime:       bill.BillTime,
+			Type:           2,
+		}
+		if fast, ok := fastMap[key]; ok {
+			reconcile.FastAmount = fast.Amount
+			reconcile.PayAmount = fast.PayAmount
+			reconcile.Type = 1
+			if fast.Amount != bill.Amount {
+				reconcile.Type = 4
+			}
+			delete(fastMap, key)
+		}
+		count[reconcile.Type]++
+		reconcileList = append(reconcileList, reconcile)
+	}
+	for _, fast := range fastList {
+		if _, ok := fastMap[fast.OrderNumber+"|"+fast.TrackingNumber]; !ok {
+			continue
+		}
+		count[3]++
+		reconcileList = append(reconcileList, models.BillReconcile{
+			BaseModel: models.BaseModel{
+				Operator: "system",
+			},
+			OrderNumber:    fast.OrderNumber,
+			TrackingNumber: fast.TrackingNumber,
+			FastAmount:     fast.Amount,
+			PayAmount:      fast.PayAmount,
+			BillTime:       fast.BillTime,
+			Type:           3,
+		})
+	}
+
+	err = saveBillReconcile(db, reconcileList)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("已匹配 %d 条 账单无快递 %d 条 快递无账单 %d 条 数量不符 %d 条",
+		count[1], count[2], count[3], count[4]), nil
+}
+
+// saveBillReconcile 替换对账结果
+func saveBillReconcile(db *gorm.DB, reconcileList []models.BillReconcile) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Where("id > 0").Delete(&models.BillReconcile{}).Error
+	if err != nil || len(reconcileList) == 0 {
+		return err
+	}
+	err = tx.Model(&models.BillReconcile{}).CreateInBatches(&reconcileList, 500).Error
+
+	return err
+}
+
+// getPeriodFormat 统计周期对应的 DATE_FORMAT 格式 day week month
+func getPeriodFormat(period string) (string, error) {
+	switch period {
+	case "day":
+		return "%Y-%m-%d", nil
+	case "week":
+		return "%x-%v", nil
+	case "", "month":
+		return "%Y-%m", nil
+	}
+
+	return "", errors.New("周期错误")
+}
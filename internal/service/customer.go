@@ -30,7 +30,11 @@ func GetCustomerById(id int) (*models.Customer, error) {
 }
 
 func SaveCustomer(customer *models.Customer) (*models.Customer, error) {
-	err := IfCustomerByName(customer.Name)
+	err := checkMarginRate(customer.MarginRate)
+	if err != nil {
+		return nil, err
+	}
+	err = IfCustomerByName(customer.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +52,10 @@ func UpdateCustomer(customer *models.Customer) (*models.Customer, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = checkMarginRate(customer.MarginRate)
+	if err != nil {
+		return nil, err
+	}
 
 	return customer, global.Db.Updates(&customer).Error
 }
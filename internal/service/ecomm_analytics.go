@@ -0,0 +1,128 @@
+package service
+
+import (
+	"gorm.io/gorm"
+	"math"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetECommAnalytics 电商销售统计 按店铺 产品 周期汇总销售数量 订单数 退货率 以及畅销产品
+func GetECommAnalytics(shopId int, period, begTime, endTime string, top int) (interface{}, error) {
+	format, err := getPeriodFormat(period)
+	if err != nil {
+		return nil, err
+	}
+	if top <= 0 {
+		top = 10
+	}
+
+	analyticsDb := func() *gorm.DB {
+		db := global.Db.Model(&models.ECommBill{})
+		if shopId > 0 {
+			db = db.Where("shop_id = ?", shopId)
+		}
+		if begTime != "" && endTime != "" {
+			db = db.Where("DATE_FORMAT(delivery_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+		}
+		return db
+	}
+	sumFields := "SUM(amount) as units, COUNT(DISTINCT order_number) as orders, " +
+		"SUM(return_amount) as return_units"
+
+	series := make([]models.ECommSalesStat, 0)
+	err = analyticsDb().
+		Select("IFNULL(shop_id, 0) as shop_id, IFNULL(product_id, 0) as product_id, "+
+			"DATE_FORMAT(delivery_time, ?) as period, "+sumFields, format).
+		Group("shop_id, product_id, period").
+		Order("period asc, shop_id asc, units desc").
+		Scan(&series).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shops := make([]models.ECommSalesStat, 0)
+	err = analyticsDb().
+		Select("IFNULL(shop_id, 0) as shop_id, " + sumFields).
+		Group("shop_id").
+		Order("units desc").
+		Scan(&shops).Error
+	if err != nil {
+		return nil, err
+	}
+
+	topSellers := make([]models.ECommSalesStat, 0)
+	err = analyticsDb().
+		Select("IFNULL(product_id, 0) as product_id, " + sumFields).
+		Group("product_id").
+		Order("units desc").
+		Limit(top).
+		Scan(&topSellers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = setSalesStatNames(series, shops, topSellers)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"series":     series,
+		"shops":      shops,
+		"topSellers": topSellers,
+	}, nil
+}
+
+// setSalesStatNames 设置店铺名称 产品名称以及退货率
+func setSalesStatNames(statLists ...[]models.ECommSalesStat) error {
+	var shopList []models.ECommCustomers
+	err := global.Db.Model(&models.ECommCustomers{}).Find(&shopList).Error
+	if err != nil {
+		return err
+	}
+	shops := make(map[int]string)
+	for _, shop := range shopList {
+		shops[shop.ID] = shop.ShopName
+		if shop.ShopName == "" {
+			shops[shop.ID] = shop.Name
+		}
+	}
+
+	productIds := make([]int, 0)
+	for _, list := range statLists {
+		for _, stat := range list {
+			if stat.ProductId > 0 {
+				productIds = append(productIds, stat.ProductId)
+			}
+		}
+	}
+	products := make(map[int]string)
+	if len(productIds) > 0 {
+		var productList []models.Product
+		err = global.Db.Model(&models.Product{}).Where("id in ?", productIds).Find(&productList).Error
+		if err != nil {
+			return err
+		}
+		for _, product := range productList {
+			products[product.ID] = product.Name + " " + product.Specification
+		}
+	}
+
+	for _, list := range statLists {
+		for i := range list {
+			stat := &list[i]
+			stat.ShopName = shops[stat.ShopId]
+			if stat.ShopId == 0 {
+				stat.ShopName = "未关联店铺"
+			}
+			stat.ProductName = products[stat.ProductId]
+			if stat.ProductId == 0 {
+				stat.ProductName = "未匹配产品"
+			}
+			stat.ReturnRate = math.Round(getRatio(stat.ReturnUnits, stat.Units)*100) / 100
+		}
+	}
+
+	return nil
+}
@@ -3,20 +3,23 @@ package service
 import (
 	"errors"
 	"gorm.io/gorm"
-	"mime/multipart"
-	"strconv"
-	"time"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
-	"warehouse_oa/utils"
 )
 
 func GetECommBillList(eCommBill *models.ECommBill, pn, pSize int) (interface{}, error) {
 	db := global.Db.Model(&models.ECommBill{})
+	db = db.Preload("Product").Preload("Shop")
 
 	if eCommBill.Name != "" {
 		db = db.Where("name = ?", eCommBill.Name)
 	}
+	if eCommBill.Status >= 0 {
+		db = db.Where("status = ?", eCommBill.Status)
+	}
+	if eCommBill.ShopId != nil {
+		db = db.Where("shop_id = ?", *eCommBill.ShopId)
+	}
 
 	return Pagination(db, []models.ECommBill{}, pn, pSize)
 }
@@ -39,7 +42,22 @@ func SaveECommBill(eCommBill *models.ECommBill) (*models.ECommBill, error) {
 		return nil, err
 	}
 
+	// 按映射匹配产品
+	billList := []models.ECommBill{*eCommBill}
+	err = setBillMapping(billList)
+	if err != nil {
+		return nil, err
+	}
+	err = setBillShop(billList, getBillShopId(eCommBill))
+	if err != nil {
+		return nil, err
+	}
+	*eCommBill = billList[0]
+
 	err = global.Db.Model(&models.ECommBill{}).Create(eCommBill).Error
+	if isDuplicateKey(err) {
+		return nil, errors.New("订单编号和快递单号已存在")
+	}
 
 	return eCommBill, err
 }
@@ -48,12 +66,42 @@ func UpdateECommBill(eCommBill *models.ECommBill) (*models.ECommBill, error) {
 	if eCommBill.ID == 0 {
 		return nil, errors.New("id is 0")
 	}
-	_, err := GetECommBillById(eCommBill.ID)
+	data, err := GetECommBillById(eCommBill.ID)
+	if err != nil {
+		return nil, err
+	}
+	if data.Status == 2 {
+		return nil, errors.New("账单已出库，不能修改")
+	}
+
+	// 标题规格或数量可能变化 重新匹配产品
+	billList := []models.ECommBill{*eCommBill}
+	err = setBillMapping(billList)
 	if err != nil {
 		return nil, err
 	}
+	err = setBillShop(billList, getBillShopId(eCommBill))
+	if err != nil {
+		return nil, err
+	}
+	*eCommBill = billList[0]
 
-	return eCommBill, global.Db.Updates(&eCommBill).Error
+	err = global.Db.Updates(&eCommBill).Error
+	if isDuplicateKey(err) {
+		return nil, errors.New("订单编号和快递单号已存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = global.Db.Model(&models.ECommBill{}).Where("id = ?", eCommBill.ID).
+		Updates(map[string]interface{}{
+			"product_id": eCommBill.ProductId,
+			"quantity":   eCommBill.Quantity,
+			"status":     eCommBill.Status,
+			"shop_id":    eCommBill.ShopId,
+		}).Error
+
+	return eCommBill, err
 }
 
 func DelECommBill(id int) error {
@@ -68,6 +116,9 @@ func DelECommBill(id int) error {
 	if data == nil {
 		return errors.New("user does not exist")
 	}
+	if data.Status == 2 {
+		return errors.New("账单已出库，不能删除")
+	}
 
 	return global.Db.Delete(&data).Error
 }
@@ -104,37 +155,85 @@ func IfECommBillByName(name string) error {
 	return nil
 }
 
-func UploadECommBill(file *multipart.FileHeader, username string) error {
-	dataList, err := utils.UploadXlsx(file)
+// LinkECommBillShop 未关联店铺的账单按客户名称关联店铺 返回关联数量
+func LinkECommBillShop() (int64, error) {
+	var shopList []models.ECommCustomers
+	err := global.Db.Model(&models.ECommCustomers{}).Find(&shopList).Error
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	fastBillList := make([]models.ECommBill, 0)
-	for _, data := range dataList {
-		amount, err := strconv.Atoi(data["数量"])
-		if err != nil {
-			return err
+	var total int64
+	for _, shop := range shopList {
+		names := make([]string, 0)
+		for _, name := range []string{shop.Name, shop.ShopName} {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
 		}
-		deliveryTime, err := time.Parse("2006-01-02 15:04:05", data["发货时间"])
+		result := global.Db.Model(&models.ECommBill{}).
+			Where("shop_id is null and name in ?", names).
+			Update("shop_id", shop.ID)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+
+	return total, nil
+}
+
+// setBillShop 设置账单店铺 指定店铺时全部使用该店铺 否则按客户名称匹配店铺名称
+func setBillShop(billList []models.ECommBill, shopId int) error {
+	if len(billList) == 0 {
+		return nil
+	}
+	if shopId > 0 {
+		_, err := GetECommCustomersById(shopId)
 		if err != nil {
-			return err
+			return errors.New("店铺不存在")
+		}
+		for i := range billList {
+			id := shopId
+			billList[i].ShopId = &id
 		}
+		return nil
+	}
+
+	var shopList []models.ECommCustomers
+	err := global.Db.Model(&models.ECommCustomers{}).Find(&shopList).Error
+	if err != nil {
+		return err
+	}
+	shops := make(map[string]int)
+	for _, shop := range shopList {
+		if shop.ShopName != "" {
+			shops[shop.ShopName] = shop.ID
+		}
+	}
+	// 客户名称优先匹配
+	for _, shop := range shopList {
+		shops[shop.Name] = shop.ID
+	}
+
+	for i := range billList {
+		billList[i].ShopId = nil
+		if id, ok := shops[billList[i].Name]; ok && billList[i].Name != "" {
+			billList[i].ShopId = &id
+		}
+	}
+
+	return nil
+}
+
+// getBillShopId 获取账单指定的店铺ID
+func getBillShopId(bill *models.ECommBill) int {
+	if bill.ShopId == nil {
+		return 0
+	}
 
-		fastBillList = append(fastBillList, models.ECommBill{
-			BaseModel: models.BaseModel{
-				Operator: username,
-				Remark:   data["备注"],
-			},
-			Name:           data["客户名称"],
-			OrderNumber:    data["主订单编号"],
-			TrackingNumber: data["快递单号"],
-			Title:          data["商品标题"],
-			Specification:  data["商品销售规格"],
-			Amount:         amount,
-			DeliveryTime:   deliveryTime,
-		})
-	}
-
-	return global.Db.Updates(&fastBillList).Error
+	return *bill.ShopId
 }
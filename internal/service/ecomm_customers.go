@@ -34,6 +34,12 @@ func SaveECommCustomers(eCommECommCustomers *models.ECommCustomers) (*models.ECo
 	if err != nil {
 		return nil, err
 	}
+	if eCommECommCustomers.ImportProfile != "" {
+		_, err = getImportProfile(eCommECommCustomers.ImportProfile, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	err = global.Db.Model(&models.ECommCustomers{}).Create(eCommECommCustomers).Error
 
@@ -48,6 +54,12 @@ func UpdateECommCustomers(eCommECommCustomers *models.ECommCustomers) (*models.E
 	if err != nil {
 		return nil, err
 	}
+	if eCommECommCustomers.ImportProfile != "" {
+		_, err = getImportProfile(eCommECommCustomers.ImportProfile, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return eCommECommCustomers, global.Db.Updates(&eCommECommCustomers).Error
 }
@@ -65,6 +77,15 @@ func DelECommCustomers(id int) error {
 		return errors.New("user does not exist")
 	}
 
+	var count int64
+	err = global.Db.Model(&models.ECommBill{}).Where("shop_id = ?", id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("店铺已有账单，不能删除")
+	}
+
 	return global.Db.Delete(&data).Error
 }
 
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetProductMappingList 获取电商商品映射列表
+func GetProductMappingList(title string, productId, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.ECommProductMapping{})
+	db = db.Preload("Product")
+
+	if title != "" {
+		db = db.Where("title like ?", "%"+title+"%")
+	}
+	if productId > 0 {
+		db = db.Where("product_id = ?", productId)
+	}
+
+	return Pagination(db, []models.ECommProductMapping{}, pn, pSize)
+}
+
+// GetProductMappingById 根据ID获取电商商品映射
+func GetProductMappingById(id int) (*models.ECommProductMapping, error) {
+	data := &models.ECommProductMapping{}
+	err := global.Db.Model(&models.ECommProductMapping{}).
+		Where("id = ?", id).First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("商品映射不存在")
+	}
+
+	return data, err
+}
+
+// SaveProductMapping 保存电商商品映射 并匹配待匹配的账单
+func SaveProductMapping(mapping *models.ECommProductMapping) (*models.ECommProductMapping, error) {
+	err := checkProductMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err = global.Db.Model(&models.ECommProductMapping{}).
+		Where("title = ? and specification = ?", mapping.Title, mapping.Specification).
+		Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("商品标题和销售规格已存在映射")
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Model(&models.ECommProductMapping{}).Create(mapping).Error
+	if err != nil {
+		return nil, err
+	}
+	err = matchProductMapping(tx, mapping)
+
+	return mapping, err
+}
+
+// UpdateProductMapping 修改电商商品映射 已出库的账单不受影响
+func UpdateProductMapping(mapping *models.ECommProductMapping) (*models.ECommProductMapping, error) {
+	if mapping.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	old, err := GetProductMappingById(mapping.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 标题和规格不允许修改
+	mapping.Title = old.Title
+	mapping.Specification = old.Specification
+	err = checkProductMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Select("product_id", "quantity", "remark", "operator").Updates(mapping).Error
+	if err != nil {
+		return nil, err
+	}
+	err = matchProductMapping(tx, mapping)
+
+	return mapping, err
+}
+
+// DelProductMapping 删除电商商品映射 已匹配未出库的账单退回待匹配
+func DelProductMapping(id int) error {
+	mapping, err := GetProductMappingById(id)
+	if err != nil {
+		return err
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Delete(mapping).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.ECommBill{}).
+		Where("title = ? and specification = ? and status = 1", mapping.Title, mapping.Specification).
+		Updates(map[string]interface{}{
+			"product_id": nil,
+			"quantity":   0,
+			"status":     0,
+		}).Error
+
+	return err
+}
+
+// GetBillReviewList 获取待匹配账单 按商品标题+销售规格汇总
+func GetBillReviewList(title string, pn, pSize int) (interface{}, error) {
+	reviewDb := func() *gorm.DB {
+		db := global.Db.Model(&models.ECommBill{}).Where("status = 0")
+		if title != "" {
+			db = db.Where("title like ?", "%"+title+"%")
+		}
+		return db.Group("title, specification")
+	}
+
+	var total int64
+	err := global.Db.Table("(?) as t", reviewDb().Select("title")).Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	db := reviewDb().Select("title, specification, count(*) as bill_count, sum(amount) as amount")
+	if pn != 0 && pSize != 0 {
+		offset := (pn - 1) * pSize
+		db = db.Order("bill_count desc").Limit(pSize).Offset(offset)
+	}
+
+	var data []models.ECommBillReview
+	err = db.Scan(&data).Error
+
+	return map[string]interface{}{
+		"data":       data,
+		"pageNo":     pn,
+		"pageSize":   pSize,
+		"totalCount": total,
+	}, err
+}
+
+// ConfirmECommBill 确认电商账单出库 按订单出库的方式扣除产品库存 产品库存不足时扣除成品
+func ConfirmECommBill(ids []int, locationId int, username string) error {
+	if len(ids) == 0 {
+		return errors.New("请选择账单")
+	}
+	err := CheckLocation(locationId)
+	if err != nil {
+		return err
+	}
+
+	var billList []models.ECommBill
+	err = global.Db.Model(&models.ECommBill{}).Where("id in ?", ids).Find(&billList).Error
+	if err != nil {
+		return err
+	}
+	if len(billList) != len(ids) {
+		return errors.New("账单不存在")
+	}
+	for _, bill := range billList {
+		if bill.Status == 0 {
+			return errors.New(fmt.Sprintf("账单【%s】未匹配产品", bill.OrderNumber))
+		}
+		if bill.Status == 2 {
+			return errors.New(fmt.Sprintf("账单【%s】已出库", bill.OrderNumber))
+		}
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	for i := range billList {
+		billList[i].Operator = username
+		err = outOfStockByBill(tx, &billList[i], locationId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// outOfStockByBill 电商账单出库 先按状态更新账单 同一账单同时确认时只有一次出库
+func outOfStockByBill(db *gorm.DB, bill *models.ECommBill, locationId int) error {
+	result := db.Model(&models.ECommBill{}).Where("id = ? and status = 1", bill.ID).
+		Updates(map[string]interface{}{
+			"status":   2,
+			"operator": bill.Operator,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(fmt.Sprintf("账单【%s】已出库或未匹配产品", bill.OrderNumber))
+	}
+	bill.Status = 2
+
+	product := &models.Product{}
+	err := db.Model(&models.Product{}).Preload("ProductContent").
+		Where("id = ?", bill.ProductId).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New(fmt.Sprintf("账单【%s】匹配的产品不存在", bill.OrderNumber))
+	}
+	if err != nil {
+		return err
+	}
+
+	drawn, surplusNum, err := DeductProductStock(db, product.ID, bill.Quantity, locationId)
+	if err != nil {
+		return err
+	}
+
+	falseValue := false
+	err = saveProductConsumeByLocation(db, models.ProductConsume{
+		BaseModel: models.BaseModel{
+			Operator: bill.Operator,
+		},
+		BillId:           &bill.ID,
+		ProductId:        product.ID,
+		OperationType:    &falseValue,
+		OperationDetails: fmt.Sprintf("电商账单【%s】出库", bill.OrderNumber),
+	}, drawn)
+	if err != nil {
+		return err
+	}
+
+	// 消耗成品
+	if surplusNum > 0 {
+		if len(product.ProductContent) == 0 {
+			return errors.New(fmt.Sprintf("产品【%s】库存不足", product.Name))
+		}
+		for _, content := range product.ProductContent {
+			err = DeductFinishedStockByConsume(db, models.FinishedConsume{
+				BaseModel: models.BaseModel{
+					Operator: bill.Operator,
+				},
+				ProductId:        product.ID,
+				BillId:           &bill.ID,
+				OperationDetails: fmt.Sprintf("电商账单【%s】销售出库", bill.OrderNumber),
+			}, &models.FinishedStock{
+				FinishedId: content.FinishedId,
+				Amount:     content.Quantity * float64(surplusNum),
+				LocationId: locationId,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchProductMapping 按映射匹配未出库的账单
+func matchProductMapping(db *gorm.DB, mapping *models.ECommProductMapping) error {
+	return db.Model(&models.ECommBill{}).
+		Where("title = ? and specification = ? and status < 2", mapping.Title, mapping.Specification).
+		Updates(map[string]interface{}{
+			"product_id": mapping.ProductId,
+			"quantity":   gorm.Expr("(amount - return_amount) * ?", mapping.Quantity),
+			"status":     1,
+		}).Error
+}
+
+// setBillMapping 新增账单时按映射设置产品 未找到映射的进入待匹配
+func setBillMapping(billList []models.ECommBill) error {
+	if len(billList) == 0 {
+		return nil
+	}
+	titles := make([]string, 0)
+	for _, bill := range billList {
+		titles = append(titles, bill.Title)
+	}
+
+	var mappingList []models.ECommProductMapping
+	err := global.Db.Model(&models.ECommProductMapping{}).
+		Where("title in ?", titles).Find(&mappingList).Error
+	if err != nil {
+		return err
+	}
+	mappings := make(map[string]models.ECommProductMapping)
+	for _, mapping := range mappingList {
+		mappings[mapping.Title+"|"+mapping.Specification] = mapping
+	}
+
+	for i := range billList {
+		mapping, ok := mappings[billList[i].Title+"|"+billList[i].Specification]
+		if !ok {
+			billList[i].ProductId = nil
+			billList[i].Quantity = 0
+			billList[i].Status = 0
+			continue
+		}
+		productId := mapping.ProductId
+		billList[i].ProductId = &productId
+		billList[i].Quantity = (billList[i].Amount - billList[i].ReturnAmount) * mapping.Quantity
+		billList[i].Status = 1
+	}
+
+	return nil
+}
+
+// checkProductMapping 校验电商商品映射
+func checkProductMapping(mapping *models.ECommProductMapping) error {
+	if mapping.Title == "" {
+		return errors.New("商品标题不能为空")
+	}
+	if mapping.Quantity <= 0 {
+		mapping.Quantity = 1
+	}
+	_, err := GetProductById(mapping.ProductId)
+
+	return err
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"path"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// claimTransitions 快递赔付状态流转 已立案->已提交快递->已审核通过->已赔付 赔付前可拒绝
+var claimTransitions = map[int][]int{
+	1: {2, 5},
+	2: {3, 5},
+	3: {4},
+}
+
+// GetExpressClaimList 获取快递赔付列表
+func GetExpressClaimList(claim *models.ExpressClaim, begTime, endTime string,
+	pn, pSize int) (interface{}, error) {
+
+	db := global.Db.Model(&models.ExpressClaim{})
+	if claim.FastBillId > 0 {
+		db = db.Where("fast_bill_id = ?", claim.FastBillId)
+	}
+	if claim.Carrier != "" {
+		db = db.Where("carrier = ?", claim.Carrier)
+	}
+	if claim.Status > 0 {
+		db = db.Where("status = ?", claim.Status)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(open_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if pn != 0 && pSize != 0 {
+		offset := (pn - 1) * pSize
+		db = db.Limit(pSize).Offset(offset)
+	}
+
+	data := make([]models.ExpressClaim, 0)
+	err := db.Preload("FastBill").Order("open_time desc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i].ImageList = make([]string, 0)
+		if data[i].Images != "" {
+			data[i].ImageList = strings.Split(data[i].Images, ";")
+		}
+	}
+
+	return map[string]interface{}{
+		"data":       data,
+		"pageNo":     pn,
+		"pageSize":   pSize,
+		"totalCount": total,
+	}, nil
+}
+
+// GetExpressClaimById 根据ID获取快递赔付
+func GetExpressClaimById(id int) (*models.ExpressClaim, error) {
+	data := &models.ExpressClaim{}
+	err := global.Db.Model(&models.ExpressClaim{}).
+		Where("id = ?", id).First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("快递赔付不存在")
+	}
+
+	return data, err
+}
+
+// SaveExpressClaim 快递账单立案 同一快递账单只能有一个未结束的赔付
+func SaveExpressClaim(claim *models.ExpressClaim) (*models.ExpressClaim, error) {
+	err := checkExpressClaim(claim)
+	if err != nil {
+		return nil, err
+	}
+	_, err = GetFastBillById(claim.FastBillId)
+	if err != nil {
+		return nil, err
+	}
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 锁定快递账单 同一账单的立案依次执行
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.FastBill{}).
+		Select("id").Where("id = ?", claim.FastBillId).First(&models.FastBill{}).Error
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	err = tx.Model(&models.ExpressClaim{}).
+		Where("fast_bill_id = ? and status in ?", claim.FastBillId, []int{1, 2, 3}).
+		Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		err = errors.New("快递账单已有未结束的赔付")
+		return nil, err
+	}
+
+	claim.ID = 0
+	claim.Status = 1
+	claim.OpenTime = time.Now()
+	claim.ReceivedAmount = 0
+	claim.SubmitTime, claim.ApproveTime, claim.PayTime, claim.RejectTime = nil, nil, nil, nil
+	claim.FastBill = nil
+	err = tx.Model(&models.ExpressClaim{}).Create(claim).Error
+	if err != nil {
+		return nil, err
+	}
+	err = saveGalleryRefs(tx, galleryRefExpressClaim, claim.ID, claim.ImageList)
+	if err != nil {
+		return nil, err
+	}
+	err = syncFastBillClaim(tx, claim.FastBillId)
+
+	return claim, err
+}
+
+// UpdateExpressClaim 修改快递赔付 已赔付或已拒绝的不能修改
+func UpdateExpressClaim(claim *models.ExpressClaim) (*models.ExpressClaim, error) {
+	if claim.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	data, err := GetExpressClaimById(claim.ID)
+	if err != nil {
+		return nil, err
+	}
+	if data.Status >= 4 {
+		return nil, errors.New("赔付已结束，不能修改")
+	}
+	claim.FastBillId = data.FastBillId
+	err = checkExpressClaim(claim)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Model(&models.ExpressClaim{}).Where("id = ?", claim.ID).
+		Select("carrier", "reason", "expected_amount", "images", "remark", "operator").
+		Updates(claim).Error
+	if err != nil {
+		return nil, err
+	}
+	err = saveGalleryRefs(tx, galleryRefExpressClaim, claim.ID, claim.ImageList)
+
+	return claim, err
+}
+
+// ChangeExpressClaimStatus 修改快递赔付状态 赔付时 amount 为实际到账金额
+func ChangeExpressClaimStatus(id, status int, amount float64, remark, username string) (*models.ExpressClaim, error) {
+	claim, err := GetExpressClaimById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, s := range claimTransitions[claim.Status] {
+		if s == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("赔付状态不能从 %d 改为 %d", claim.Status, status)
+	}
+
+	fromStatus := claim.Status
+	now := time.Now()
+	switch status {
+	case 2:
+		claim.SubmitTime = &now
+	case 3:
+		claim.ApproveTime = &now
+	case 4:
+		if amount <= 0 {
+			return nil, errors.New("到账金额必须大于0")
+		}
+		claim.ReceivedAmount = amount
+		claim.PayTime = &now
+	case 5:
+		claim.RejectTime = &now
+	}
+	claim.Status = status
+	claim.Operator = username
+	if remark != "" {
+		claim.Remark = remark
+	}
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 只更新状态未被其他请求修改的记录
+	result := tx.Model(&models.ExpressClaim{}).Where("id = ? and status = ?", claim.ID, fromStatus).
+		Select("status", "received_amount", "submit_time", "approve_time",
+			"pay_time", "reject_time", "remark", "operator").
+		Updates(claim)
+	err = result.Error
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		err = errors.New("赔付状态已变更，请刷新后重试")
+		return nil, err
+	}
+	err = syncFastBillClaim(tx, claim.FastBillId)
+
+	return claim, err
+}
+
+// GetExpressClaimOutstanding 按快递公司汇总未结束的赔付
+func GetExpressClaimOutstanding(carrier string) ([]models.ExpressClaimOutstanding, error) {
+	db := global.Db.Model(&models.ExpressClaim{}).Where("status in ?", []int{1, 2, 3})
+	if carrier != "" {
+		db = db.Where("carrier = ?", carrier)
+	}
+
+	data := make([]models.ExpressClaimOutstanding, 0)
+	err := db.Select("carrier, count(*) as count, sum(expected_amount) as expected_amount, " +
+		"sum(received_amount) as received_amount, min(open_time) as oldest_time").
+		Group("carrier").
+		Order("expected_amount desc").
+		Scan(&data).Error
+
+	return data, err
+}
+
+// syncFastBillClaim 快递账单记录最新赔付状态以及已赔付金额合计 不修改导入的状态和赔付金额
+func syncFastBillClaim(db *gorm.DB, fastBillId int) error {
+	claim := &models.ExpressClaim{}
+	err := db.Model(&models.ExpressClaim{}).Where("fast_bill_id = ?", fastBillId).
+		Order("id desc").First(&claim).Error
+	if err != nil {
+		return err
+	}
+
+	var claimPaid float64
+	err = db.Model(&models.ExpressClaim{}).
+		Where("fast_bill_id = ? and status = 4", fastBillId).
+		Select("IFNULL(SUM(received_amount), 0)").Scan(&claimPaid).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&models.FastBill{}).Where("id = ?", fastBillId).
+		Updates(map[string]interface{}{
+			"claim_status": claim.Status,
+			"claim_paid":   claimPaid,
+		}).Error
+}
+
+// checkExpressClaim 校验快递赔付 凭证图片必须是图库中的图片
+func checkExpressClaim(claim *models.ExpressClaim) error {
+	claim.Carrier = strings.TrimSpace(claim.Carrier)
+	claim.Reason = strings.TrimSpace(claim.Reason)
+	if claim.Carrier == "" {
+		return errors.New("快递公司不能为空")
+	}
+	if claim.Reason == "" {
+		return errors.New("赔付原因不能为空")
+	}
+	if claim.ExpectedAmount < 0 {
+		return errors.New("申请赔付金额错误")
+	}
+
+	if len(claim.ImageList) > 0 {
+		// 图片可以是图库文件名或完整地址
+		names := make(map[string]bool)
+		for _, image := range claim.ImageList {
+			names[path.Base(image)] = true
+		}
+		nameList := make([]string, 0)
+		for name := range names {
+			nameList = append(nameList, name)
+		}
+
+		var count int64
+		err := global.Db.Model(&models.Gallery{}).
+			Where("url in ?", nameList).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if int(count) != len(nameList) {
+			return errors.New("凭证图片不在图库中")
+		}
+	}
+	claim.Images = strings.Join(claim.ImageList, ";")
+
+	return nil
+}
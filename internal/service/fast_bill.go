@@ -3,11 +3,8 @@ package service
 import (
 	"errors"
 	"gorm.io/gorm"
-	"mime/multipart"
-	"strconv"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
-	"warehouse_oa/utils"
 )
 
 func GetFastBillList(fastBill *models.FastBill, pn, pSize int) (interface{}, error) {
@@ -38,7 +35,13 @@ func SaveFastBill(fastBill *models.FastBill) (*models.FastBill, error) {
 		return nil, err
 	}
 
+	// 赔付状态以及已赔付金额由快递赔付同步
+	fastBill.ClaimStatus = 0
+	fastBill.ClaimPaid = 0
 	err = global.Db.Model(&models.FastBill{}).Create(fastBill).Error
+	if isDuplicateKey(err) {
+		return nil, errors.New("订单编号和快递单号已存在")
+	}
 
 	return fastBill, err
 }
@@ -52,7 +55,12 @@ func UpdateFastBill(fastBill *models.FastBill) (*models.FastBill, error) {
 		return nil, err
 	}
 
-	return fastBill, global.Db.Updates(&fastBill).Error
+	err = global.Db.Omit("claim_status", "claim_paid").Updates(&fastBill).Error
+	if isDuplicateKey(err) {
+		return nil, errors.New("订单编号和快递单号已存在")
+	}
+
+	return fastBill, err
 }
 
 func DelFastBill(id int) error {
@@ -102,42 +110,3 @@ func IfFastBillByName(name string) error {
 
 	return nil
 }
-
-func UploadFastBill(file *multipart.FileHeader, username string) error {
-	dataList, err := utils.UploadXlsx(file)
-	if err != nil {
-		return err
-	}
-
-	fastBillList := make([]models.FastBill, 0)
-	for _, data := range dataList {
-		amount, err := strconv.Atoi(data["数量"])
-		if err != nil {
-			return err
-		}
-		status, err := strconv.Atoi(data["状态"])
-		if err != nil {
-			return err
-		}
-		payAmount, err := strconv.ParseFloat(data["赔付金额"], 64)
-		if err != nil {
-			return err
-		}
-
-		fastBillList = append(fastBillList, models.FastBill{
-			BaseModel: models.BaseModel{
-				Operator: username,
-				Remark:   data["备注"],
-			},
-			OrderNumber:    data["订单编号"],
-			TrackingNumber: data["快递单号"],
-			Title:          data["商品标题"],
-			Specification:  data["商品销售规格"],
-			Amount:         amount,
-			Status:         status,
-			PayAmount:      payAmount,
-		})
-	}
-
-	return global.Db.Updates(&fastBillList).Error
-}
@@ -3,7 +3,9 @@ package service
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"strings"
+	"time"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
 )
@@ -29,16 +31,23 @@ func GetFinishedIngredients(id int) (interface{}, error) {
 	if id == 0 {
 		return nil, errors.New("id is 0")
 	}
-	db := global.Db
-	productIngredient := make([]models.FinishedMaterial, 0)
-
-	err := db.Where("finished_id = ?", id).Preload(
-		"Ingredient").Find(&productIngredient).Error
+	// 按当前生效的配方版本返回 未来生效的修改不影响
+	bom, err := GetEffectiveBom(id, time.Now())
 	if err != nil {
 		return nil, err
 	}
+	productIngredient := make([]models.FinishedMaterial, 0)
+	for _, m := range bom.Material {
+		productIngredient = append(productIngredient, models.FinishedMaterial{
+			FinishedId:   id,
+			IngredientId: m.IngredientId,
+			Ingredient:   m.Ingredient,
+			StockUnit:    m.StockUnit,
+			Quantity:     m.Quantity,
+		})
+	}
 
-	return productIngredient, err
+	return productIngredient, nil
 }
 
 // GetFinishedById ID查询成品
@@ -47,7 +56,7 @@ func GetFinishedById(id int) (*models.Finished, error) {
 
 	data := &models.Finished{}
 	err := db.Where("id = ?", id).Preload(
-		"Material.Ingredient").First(&data).Error
+		"Material.Ingredient").Preload("SubMaterial.SubFinished").First(&data).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, errors.New("查找成品失败")
 	}
@@ -65,6 +74,10 @@ func SaveFinished(finished *models.Finished) (*models.Finished, error) {
 			return nil, err
 		}
 	}
+	err = checkSubMaterial(finished)
+	if err != nil {
+		return nil, err
+	}
 
 	err = global.Db.Model(&models.Finished{}).Create(&finished).Error
 	if err != nil {
@@ -78,6 +91,12 @@ func SaveFinished(finished *models.Finished) (*models.Finished, error) {
 		FinishedId: finished.ID,
 		Amount:     0,
 	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 保存第一个配方版本
+	err = saveBomVersion(global.Db, finished)
 
 	return finished, err
 }
@@ -91,6 +110,11 @@ func UpdateFinished(finished *models.Finished) (*models.Finished, error) {
 	if err != nil {
 		return nil, err
 	}
+	// 修改前保存原有配方
+	_, err = ensureBom(finished.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	// 判断配料是否都存在
 	for _, material := range finished.Material {
@@ -99,6 +123,10 @@ func UpdateFinished(finished *models.Finished) (*models.Finished, error) {
 			return nil, err
 		}
 	}
+	err = checkSubMaterial(finished)
+	if err != nil {
+		return nil, err
+	}
 
 	db := global.Db
 	tx := db.Begin()
@@ -110,13 +138,32 @@ func UpdateFinished(finished *models.Finished) (*models.Finished, error) {
 		}
 	}()
 
+	// 未来生效的配方只保存配方版本 当前用料保持不变
+	if finished.EffectiveTime != nil && finished.EffectiveTime.After(time.Now()) {
+		err = tx.Omit(clause.Associations).Updates(finished).Error
+		if err != nil {
+			return nil, err
+		}
+		err = saveBomVersion(tx, finished)
+
+		return finished, err
+	}
+
 	// 删除关联
 	err = RemoveIngredients(tx, finished.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	return finished, tx.Updates(&finished).Error
+	err = tx.Updates(&finished).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 用料变化时保存新的配方版本 历史报工仍关联原版本
+	err = saveBomVersion(tx, finished)
+
+	return finished, err
 }
 
 func DelFinished(id int) error {
@@ -139,6 +186,34 @@ func DelFinished(id int) error {
 	if total > 0 {
 		return errors.New("成品有报工记录，无法删除")
 	}
+	err = global.Db.Model(&models.FinishedSubMaterial{}).
+		Where("sub_finished_id = ?", id).Count(&total).Error
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return errors.New("成品被其他成品使用，无法删除")
+	}
+	var finishedIds []int
+	err = global.Db.Model(&models.FinishedBom{}).
+		Where("id in (?)", global.Db.Model(&models.FinishedBomSubMaterial{}).
+			Select("bom_id").Where("sub_finished_id = ?", id)).
+		Where("finished_id in (?)", global.Db.Model(&models.Finished{}).Select("id")).
+		Distinct().Pluck("finished_id", &finishedIds).Error
+	if err != nil {
+		return err
+	}
+	for _, finishedId := range finishedIds {
+		ids, err := getActiveSubFinishedIds(finishedId)
+		if err != nil {
+			return err
+		}
+		for _, subId := range ids {
+			if subId == id {
+				return errors.New("成品被其他成品的配方版本使用，无法删除")
+			}
+		}
+	}
 
 	db := global.Db
 	tx := db.Begin()
@@ -176,8 +251,67 @@ func GetFinishedFieldList(field string) ([]string, error) {
 	return fields, nil
 }
 
-// RemoveIngredients 删除关联的配料
+// RemoveIngredients 删除关联的配料以及半成品
 func RemoveIngredients(db *gorm.DB, finishedId int) error {
-	return db.Model(&models.FinishedMaterial{}).Where(
+	err := db.Model(&models.FinishedMaterial{}).Where(
 		"finished_id = ?", finishedId).Delete(&models.FinishedMaterial{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&models.FinishedSubMaterial{}).Where(
+		"finished_id = ?", finishedId).Delete(&models.FinishedSubMaterial{}).Error
+}
+
+// checkSubMaterial 校验半成品是否存在 以及是否存在循环引用
+func checkSubMaterial(finished *models.Finished) error {
+	subIdList := make([]int, 0)
+	for i := range finished.SubMaterial {
+		sub := &finished.SubMaterial[i]
+		if sub.SubFinishedId == finished.ID && finished.ID != 0 {
+			return errors.New("成品不能使用自身作为半成品")
+		}
+		_, err := GetFinishedById(sub.SubFinishedId)
+		if err != nil {
+			return err
+		}
+		if sub.Quantity <= 0 {
+			return errors.New("半成品用量错误")
+		}
+		sub.SubFinished = nil
+		subIdList = append(subIdList, sub.SubFinishedId)
+	}
+	if finished.ID == 0 {
+		return nil
+	}
+
+	// 从半成品向下查找 找到自身说明存在循环
+	visited := make(map[int]bool)
+	for len(subIdList) > 0 {
+		id := subIdList[len(subIdList)-1]
+		subIdList = subIdList[:len(subIdList)-1]
+		if id == finished.ID {
+			return errors.New("成品配方存在循环引用")
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		var ids []int
+		err := global.Db.Model(&models.FinishedSubMaterial{}).
+			Where("finished_id = ?", id).Pluck("sub_finished_id", &ids).Error
+		if err != nil {
+			return err
+		}
+		subIdList = append(subIdList, ids...)
+		// 未来生效的配方版本也要校验
+		ids, err = getActiveSubFinishedIds(id)
+		if err != nil {
+			return err
+		}
+		subIdList = append(subIdList, ids...)
+	}
+
+	return nil
 }
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm/clause"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// finishedConsumeAdjust 成品出入库类型 库存调整
+const finishedConsumeAdjust = 1
+
+// GetFinishedAdjustList 成品库存调整记录
+func GetFinishedAdjustList(finishedId, locationId int, begTime, endTime string,
+	pn, pSize int) (interface{}, error) {
+
+	db := global.Db.Model(&models.FinishedAdjust{})
+	if finishedId > 0 {
+		db = db.Where("finished_id = ?", finishedId)
+	}
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if pn != 0 && pSize != 0 {
+		offset := (pn - 1) * pSize
+		db = db.Limit(pSize).Offset(offset)
+	}
+
+	data := make([]models.FinishedAdjust, 0)
+	err := db.Preload("Finished").Order("add_time desc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i].ImageList = make([]string, 0)
+		if data[i].Images != "" {
+			data[i].ImageList = strings.Split(data[i].Images, ";")
+		}
+	}
+
+	return map[string]interface{}{
+		"data":       data,
+		"pageNo":     pn,
+		"pageSize":   pSize,
+		"totalCount": total,
+	}, nil
+}
+
+// AdjustFinishedStock 手动调整成品库存 仅管理员可操作 必须填写调整原因
+func AdjustFinishedStock(adjust *models.FinishedAdjust, userId int) (*models.FinishedAdjust, error) {
+	err := checkAdmin(userId)
+	if err != nil {
+		return nil, err
+	}
+	adjust.Reason = strings.TrimSpace(adjust.Reason)
+	if adjust.Reason == "" {
+		return nil, errors.New("调整原因不能为空")
+	}
+	if adjust.AdjustNum == 0 {
+		return nil, errors.New("调整数量错误")
+	}
+	_, err = GetFinishedById(adjust.FinishedId)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckLocation(adjust.LocationId)
+	if err != nil {
+		return nil, err
+	}
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 库位下所有批次的合计库存 锁定批次防止并发调整
+	stockDb := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.FinishedStock{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("finished_id = ?", adjust.FinishedId)
+	if adjust.LocationId > 0 {
+		stockDb = stockDb.Where("location_id = ?", adjust.LocationId)
+	}
+	var amount float64
+	err = stockDb.Scan(&amount).Error
+	if err != nil {
+		return nil, err
+	}
+	if amount+adjust.AdjustNum < 0 {
+		err = errors.New("成品库存不足，无法调减")
+		return nil, err
+	}
+	adjust.BeforeAmount = amount
+	adjust.AfterAmount = amount + adjust.AdjustNum
+
+	adjust.Images = strings.Join(adjust.ImageList, ";")
+	adjust.Finished = nil
+	err = tx.Model(&models.FinishedAdjust{}).Create(adjust).Error
+	if err != nil {
+		return nil, err
+	}
+	err = saveGalleryRefs(tx, galleryRefFinishedAdjust, adjust.ID, adjust.ImageList)
+	if err != nil {
+		return nil, err
+	}
+
+	// 盘盈新增一个批次 盘亏按先进先出扣除批次
+	consume := models.FinishedConsume{
+		BaseModel: models.BaseModel{
+			Operator: adjust.Operator,
+		},
+		FinishedId:       adjust.FinishedId,
+		AdjustId:         &adjust.ID,
+		ConsumeType:      finishedConsumeAdjust,
+		OperationDetails: fmt.Sprintf("库存调整【%s】", adjust.Reason),
+		LocationId:       adjust.LocationId,
+	}
+	if adjust.AdjustNum < 0 {
+		err = DeductFinishedStockByConsume(tx, consume, &models.FinishedStock{
+			FinishedId: adjust.FinishedId,
+			Amount:     -adjust.AdjustNum,
+			LocationId: adjust.LocationId,
+		})
+		return adjust, err
+	}
+
+	_, err = SaveFinishedStock(tx, &models.FinishedStock{
+		BaseModel: models.BaseModel{
+			Operator: adjust.Operator,
+		},
+		FinishedId: adjust.FinishedId,
+		Amount:     adjust.AdjustNum,
+		LocationId: adjust.LocationId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	trueValue := true
+	consume.StockNum = adjust.AdjustNum
+	consume.OperationType = &trueValue
+	_, err = SaveFinishedConsume(tx, &consume)
+
+	return adjust, err
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetBomList 获取成品配方版本列表
+func GetBomList(finishedId int) ([]*models.FinishedBom, error) {
+	if finishedId == 0 {
+		return nil, errors.New("id is 0")
+	}
+
+	data := make([]*models.FinishedBom, 0)
+	err := global.Db.Model(&models.FinishedBom{}).
+		Where("finished_id = ?", finishedId).
+		Preload("Material.Ingredient").Preload("SubMaterial.SubFinished").
+		Order("version desc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, bom := range data {
+		bom.Cost, err = getBomCost(bom)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// GetBomById 根据ID获取配方版本
+func GetBomById(id int) (*models.FinishedBom, error) {
+	data := &models.FinishedBom{}
+	err := global.Db.Model(&models.FinishedBom{}).Where("id = ?", id).
+		Preload("Material.Ingredient").Preload("SubMaterial.SubFinished").First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("配方版本不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	data.Cost, err = getBomCost(data)
+
+	return data, err
+}
+
+// GetEffectiveBom 获取成品在指定时间生效的配方版本
+func GetEffectiveBom(finishedId int, t time.Time) (*models.FinishedBom, error) {
+	data := &models.FinishedBom{}
+	err := global.Db.Model(&models.FinishedBom{}).
+		Where("finished_id = ? and effective_time <= ?", finishedId, t).
+		Order("effective_time desc, version desc").
+		Preload("Material.Ingredient").Preload("SubMaterial.SubFinished").First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("成品没有生效的配方")
+	}
+
+	return data, err
+}
+
+// CompareBom 对比两个配方版本的用量以及成本差异
+func CompareBom(fromId, toId int) (interface{}, error) {
+	from, err := GetBomById(fromId)
+	if err != nil {
+		return nil, err
+	}
+	to, err := GetBomById(toId)
+	if err != nil {
+		return nil, err
+	}
+	if from.FinishedId != to.FinishedId {
+		return nil, errors.New("只能对比同一成品的配方版本")
+	}
+
+	lineMap := make(map[string]*models.BomCompareLine)
+	getLine := func(m models.FinishedBomMaterial) (*models.BomCompareLine, error) {
+		key := fmt.Sprintf("%d_%d", m.IngredientId, m.StockUnit)
+		if line, ok := lineMap[key]; ok {
+			return line, nil
+		}
+		line := &models.BomCompareLine{
+			IngredientId: m.IngredientId,
+			StockUnit:    m.StockUnit,
+		}
+		if m.Ingredient != nil {
+			line.IngredientName = m.Ingredient.Name
+		}
+		price, err := getLatestUnitPrice(m.IngredientId, m.StockUnit)
+		if err != nil {
+			return nil, err
+		}
+		line.UnitPrice = price
+		lineMap[key] = line
+
+		return line, nil
+	}
+	for _, m := range from.Material {
+		line, err := getLine(m)
+		if err != nil {
+			return nil, err
+		}
+		line.FromQuantity += m.Quantity
+	}
+	for _, m := range to.Material {
+		line, err := getLine(m)
+		if err != nil {
+			return nil, err
+		}
+		line.ToQuantity += m.Quantity
+	}
+	getSubLine := func(m models.FinishedBomSubMaterial) (*models.BomCompareLine, error) {
+		key := fmt.Sprintf("s_%d", m.SubFinishedId)
+		if line, ok := lineMap[key]; ok {
+			return line, nil
+		}
+		line := &models.BomCompareLine{
+			SubFinishedId: m.SubFinishedId,
+		}
+		if m.SubFinished != nil {
+			line.IngredientName = m.SubFinished.Name
+		}
+		price, err := getFinishedCost(m.SubFinishedId, map[int]bool{from.FinishedId: true})
+		if err != nil {
+			return nil, err
+		}
+		line.UnitPrice = price
+		lineMap[key] = line
+
+		return line, nil
+	}
+	for _, m := range from.SubMaterial {
+		line, err := getSubLine(m)
+		if err != nil {
+			return nil, err
+		}
+		line.FromQuantity += m.Quantity
+	}
+	for _, m := range to.SubMaterial {
+		line, err := getSubLine(m)
+		if err != nil {
+			return nil, err
+		}
+		line.ToQuantity += m.Quantity
+	}
+
+	lineList := make([]*models.BomCompareLine, 0)
+	for _, line := range lineMap {
+		line.FromCost = line.FromQuantity * line.UnitPrice
+		line.ToCost = line.ToQuantity * line.UnitPrice
+		line.Difference = line.ToCost - line.FromCost
+		lineList = append(lineList, line)
+	}
+	sort.Slice(lineList, func(i, j int) bool {
+		if lineList[i].SubFinishedId != lineList[j].SubFinishedId {
+			return lineList[i].SubFinishedId < lineList[j].SubFinishedId
+		}
+		return lineList[i].IngredientId < lineList[j].IngredientId
+	})
+
+	return map[string]interface{}{
+		"from":       from,
+		"to":         to,
+		"lines":      lineList,
+		"difference": to.Cost - from.Cost,
+	}, nil
+}
+
+// saveBomVersion 保存成品配方版本 用料与最新版本一致时不新增
+func saveBomVersion(db *gorm.DB, finished *models.Finished) error {
+	effectiveTime := time.Now()
+	if finished.EffectiveTime != nil {
+		effectiveTime = *finished.EffectiveTime
+	}
+
+	latest := &models.FinishedBom{}
+	err := db.Model(&models.FinishedBom{}).Where("finished_id = ?", finished.ID).
+		Preload("Material").Preload("SubMaterial").Order("version desc").Limit(1).Find(latest).Error
+	if err != nil {
+		return err
+	}
+	if latest.ID != 0 && finished.EffectiveTime == nil && sameBomMaterial(latest, finished) {
+		return nil
+	}
+	if latest.ID != 0 && effectiveTime.Before(latest.EffectiveTime) {
+		return errors.New("生效时间不能早于最新配方版本的生效时间")
+	}
+
+	bom := &models.FinishedBom{
+		BaseModel: models.BaseModel{
+			Operator: finished.Operator,
+		},
+		FinishedId:    finished.ID,
+		Version:       latest.Version + 1,
+		EffectiveTime: effectiveTime,
+		Material:      make([]models.FinishedBomMaterial, 0),
+		SubMaterial:   make([]models.FinishedBomSubMaterial, 0),
+	}
+	for _, m := range finished.Material {
+		bom.Material = append(bom.Material, models.FinishedBomMaterial{
+			IngredientId: m.IngredientId,
+			StockUnit:    m.StockUnit,
+			Quantity:     m.Quantity,
+		})
+	}
+	for _, m := range finished.SubMaterial {
+		bom.SubMaterial = append(bom.SubMaterial, models.FinishedBomSubMaterial{
+			SubFinishedId: m.SubFinishedId,
+			Quantity:      m.Quantity,
+		})
+	}
+
+	return db.Model(&models.FinishedBom{}).Create(bom).Error
+}
+
+// getActiveSubFinishedIds 当前生效以及未来生效的配方版本使用的半成品ID
+func getActiveSubFinishedIds(finishedId int) ([]int, error) {
+	bomList := make([]*models.FinishedBom, 0)
+	err := global.Db.Model(&models.FinishedBom{}).Select("id", "effective_time").
+		Where("finished_id = ?", finishedId).
+		Order("effective_time desc, version desc").Find(&bomList).Error
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bomIds := make([]int, 0)
+	for _, bom := range bomList {
+		bomIds = append(bomIds, bom.ID)
+		if !bom.EffectiveTime.After(now) {
+			break
+		}
+	}
+	if len(bomIds) == 0 {
+		return []int{}, nil
+	}
+
+	var ids []int
+	err = global.Db.Model(&models.FinishedBomSubMaterial{}).
+		Where("bom_id in ?", bomIds).Distinct().Pluck("sub_finished_id", &ids).Error
+
+	return ids, err
+}
+
+// InitFinishedBom 启动时为没有配方版本的历史成品按当前用料生成第一个版本
+func InitFinishedBom() error {
+	var ids []int
+	err := global.Db.Model(&models.Finished{}).
+		Where("id not in (?)", global.Db.Model(&models.FinishedBom{}).Select("finished_id")).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err = ensureBom(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureBom 历史成品没有配方版本时按当前用料生成第一个版本
+func ensureBom(finishedId int) (int64, error) {
+	var total int64
+	err := global.Db.Model(&models.FinishedBom{}).
+		Where("finished_id = ?", finishedId).Count(&total).Error
+	if err != nil || total > 0 {
+		return total, err
+	}
+
+	finished, err := GetFinishedById(finishedId)
+	if err != nil {
+		return 0, err
+	}
+	finished.EffectiveTime = &finished.CreatedAt
+
+	return 1, saveBomVersion(global.Db, finished)
+}
+
+// getBomCost 按最近入库单价计算配方成本 半成品按其生效配方逐级汇总
+func getBomCost(bom *models.FinishedBom) (float64, error) {
+	return getBomCostVisited(bom, map[int]bool{bom.FinishedId: true})
+}
+
+func getBomCostVisited(bom *models.FinishedBom, visited map[int]bool) (float64, error) {
+	var cost float64
+	for _, m := range bom.Material {
+		price, err := getLatestUnitPrice(m.IngredientId, m.StockUnit)
+		if err != nil {
+			return 0, err
+		}
+		cost += price * m.Quantity
+	}
+	for _, m := range bom.SubMaterial {
+		price, err := getFinishedCost(m.SubFinishedId, visited)
+		if err != nil {
+			return 0, err
+		}
+		cost += price * m.Quantity
+	}
+
+	return cost, nil
+}
+
+// getFinishedCost 成品单位成本 visited 防止循环引用
+func getFinishedCost(finishedId int, visited map[int]bool) (float64, error) {
+	if visited[finishedId] {
+		return 0, errors.New("成品配方存在循环引用")
+	}
+	bom, err := GetEffectiveBom(finishedId, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	next := make(map[int]bool)
+	for k := range visited {
+		next[k] = true
+	}
+	next[finishedId] = true
+
+	return getBomCostVisited(bom, next)
+}
+
+// getLatestUnitPrice 配料最近一次可换算为指定单位的入库单价 斤和克可以互相换算
+func getLatestUnitPrice(ingredientId, stockUnit int) (float64, error) {
+	units := []int{stockUnit}
+	if stockUnit == 1 || stockUnit == 2 {
+		units = []int{1, 2}
+	}
+
+	inBound := &models.IngredientInBound{}
+	err := global.Db.Model(&models.IngredientInBound{}).
+		Where("ingredient_id = ? and stock_unit in ?", ingredientId, units).
+		Order("stock_time desc, id desc").Limit(1).Find(inBound).Error
+	if err != nil || inBound.ID == 0 {
+		return 0, err
+	}
+
+	price, _ := normalizeUnitPrice(inBound.UnitPrice, inBound.StockUnit)
+	if stockUnit == 2 {
+		return price / 500, nil
+	}
+
+	return price, nil
+}
+
+func sameBomMaterial(bom *models.FinishedBom, finished *models.Finished) bool {
+	if len(bom.Material) != len(finished.Material) || len(bom.SubMaterial) != len(finished.SubMaterial) {
+		return false
+	}
+	m := make(map[string]float64)
+	for _, v := range bom.Material {
+		m[fmt.Sprintf("%d_%d", v.IngredientId, v.StockUnit)] = v.Quantity
+	}
+	for _, v := range bom.SubMaterial {
+		m[fmt.Sprintf("s_%d", v.SubFinishedId)] = v.Quantity
+	}
+	for _, v := range finished.Material {
+		q, ok := m[fmt.Sprintf("%d_%d", v.IngredientId, v.StockUnit)]
+		if !ok || q != v.Quantity {
+			return false
+		}
+	}
+	for _, v := range finished.SubMaterial {
+		q, ok := m[fmt.Sprintf("s_%d", v.SubFinishedId)]
+		if !ok || q != v.Quantity {
+			return false
+		}
+	}
+
+	return true
+}
@@ -25,9 +25,11 @@ func SaveConsumeByProduction(db *gorm.DB, production *models.FinishedProduction)
 		},
 		OrderId:          nil,
 		FinishedId:       production.FinishedId,
+		ProductionId:     &production.ID,
 		StockNum:         float64(production.ActualAmount),
 		OperationType:    &trueValue,
 		OperationDetails: "生产完工",
+		LocationId:       production.LocationId,
 	})
 
 	return err
@@ -3,8 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
-	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"math"
 	"strings"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
@@ -12,7 +12,7 @@ import (
 
 // GetFinishedStockList 查询库存列表接口
 func GetFinishedStockList(ids string, begReportingTime, endReportingTime string,
-	pn, pSize int) (interface{}, error) {
+	pn, pSize, locationId int) (interface{}, error) {
 
 	db := global.Db.Model(&models.FinishedStock{})
 	db.Preload("Finished")
@@ -24,6 +24,9 @@ func GetFinishedStockList(ids string, begReportingTime, endReportingTime string,
 	if begReportingTime != "" && endReportingTime != "" {
 		db = db.Where("add_time BETWEEN ? AND ?", begReportingTime, endReportingTime)
 	}
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+	}
 
 	var total int64
 	if err := db.Count(&total).Error; err != nil {
@@ -68,6 +71,7 @@ func SaveStockByProduction(db *gorm.DB, production *models.FinishedProduction) e
 	Stock := new(models.FinishedStock)
 	err := db.Model(&models.FinishedStock{}).
 		Where("finished_id = ?", production.FinishedId).
+		Where("location_id = ?", production.LocationId).
 		Find(&Stock).Error
 	if err != nil {
 		return err
@@ -83,6 +87,7 @@ func SaveStockByProduction(db *gorm.DB, production *models.FinishedProduction) e
 			},
 			FinishedId: production.FinishedId,
 			Amount:     float64(production.ActualAmount),
+			LocationId: production.LocationId,
 		})
 	}
 
@@ -100,21 +105,20 @@ func SaveFinishedStock(db *gorm.DB, finished *models.FinishedStock) (*models.Fin
 	return finished, err
 }
 
-// DeductFinishedStock 订单扣除库存, 并且新增消耗表
-func DeductFinishedStock(db *gorm.DB, order *models.Order,
+// DeductFinishedStockByConsume 按入库时间先进先出扣除成品库存, 并且新增消耗表
+// consume 为出库记录的关联单据 操作人以及操作明细 数量和库位按扣除的库存填写
+func DeductFinishedStockByConsume(db *gorm.DB, consume models.FinishedConsume,
 	finishedStock *models.FinishedStock) error {
 
-	var err error
-	for {
-		if finishedStock.Amount == 0 {
-			break
-		}
-
+	for finishedStock.Amount > 0 {
 		stock := &models.FinishedStock{}
-		err = db.Model(&models.FinishedStock{}).
+		stockDb := db.Model(&models.FinishedStock{}).
 			Where("finished_id = ?", finishedStock.FinishedId).
-			Where("amount > ?", 0).
-			Order("add_time asc").First(&stock).Error
+			Where("amount > ?", 0)
+		if finishedStock.LocationId > 0 {
+			stockDb = stockDb.Where("location_id = ?", finishedStock.LocationId)
+		}
+		err := stockDb.Order("add_time asc").First(&stock).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New(fmt.Sprintf("id: %d 成品库存不足", finishedStock.FinishedId))
 		}
@@ -122,148 +126,26 @@ func DeductFinishedStock(db *gorm.DB, order *models.Order,
 			return err
 		}
 
-		if stock.Amount >= finishedStock.Amount {
-			// 更新库存
-			falseValue := false
-			_, err = SaveFinishedConsume(db, &models.FinishedConsume{
-				BaseModel: models.BaseModel{
-					Operator: order.Operator,
-				},
-				OrderId:          &order.ID,
-				FinishedId:       stock.FinishedId,
-				StockNum:         0 - finishedStock.Amount,
-				OperationType:    &falseValue,
-				OperationDetails: fmt.Sprintf("【%s】销售出库", order.OrderNumber),
-			})
-
-			stock.Amount -= finishedStock.Amount
-			err = db.Select("amount").Updates(&stock).Error
-			if err != nil {
-				return err
-			}
-
-			finishedStock.Amount = 0
-		} else {
-			return errors.New(fmt.Sprintf("id: %d 成品库存不足", finishedStock.FinishedId))
-		}
-	}
-	return err
-}
-
-// DeductFinishedStockByProduct 产品扣除库存, 并且新增消耗表
-func DeductFinishedStockByProduct(db *gorm.DB, product *models.Product,
-	finishedStock *models.FinishedStock) error {
-
-	var err error
-	for {
-		if finishedStock.Amount == 0 {
-			break
-		}
-
-		stock := &models.FinishedStock{}
-		err = db.Model(&models.FinishedStock{}).
-			Where("finished_id = ?", finishedStock.FinishedId).
-			Where("amount > ?", 0).
-			Order("add_time asc").First(&stock).Error
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New(fmt.Sprintf("id: %d 成品库存不足", finishedStock.FinishedId))
-		}
+		amount := math.Min(stock.Amount, finishedStock.Amount)
+		falseValue := false
+		record := consume
+		record.FinishedId = stock.FinishedId
+		record.StockNum = 0 - amount
+		record.OperationType = &falseValue
+		record.LocationId = stock.LocationId
+		_, err = SaveFinishedConsume(db, &record)
 		if err != nil {
 			return err
 		}
 
-		if stock.Amount >= finishedStock.Amount {
-			// 更新库存
-			falseValue := false
-			_, err = SaveFinishedConsume(db, &models.FinishedConsume{
-				BaseModel: models.BaseModel{
-					Operator: product.Operator,
-				},
-				FinishedId:       stock.FinishedId,
-				ProductId:        product.ID,
-				StockNum:         0 - finishedStock.Amount,
-				OperationType:    &falseValue,
-				OperationDetails: fmt.Sprintf("产品【%s】使用", product.Name),
-			})
-			if err != nil {
-				return err
-			}
-
-			stock.Amount -= finishedStock.Amount
-			err = db.Select("amount").Updates(&stock).Error
-			if err != nil {
-				return err
-			}
-
-			finishedStock.Amount = 0
-		} else {
-			return errors.New(fmt.Sprintf("id: %d 成品库存不足", finishedStock.FinishedId))
-
+		stock.Amount -= amount
+		err = db.Select("amount").Updates(&stock).Error
+		if err != nil {
+			return err
 		}
-	}
-	return err
-}
 
-// ReturningInventory 返还库存
-func ReturningInventory(db *gorm.DB, data *models.ProductInventory, amount int) error {
-	logrus.Infoln(data.InventoryContent)
-	if data.Amount < amount {
-		amount = data.Amount
+		finishedStock.Amount -= amount
 	}
-	for _, ic := range data.InventoryContent {
-		deductNum := float64(amount) * ic.Quantity
-		for {
-			if deductNum == 0 {
-				break
-			}
-
-			// 查找创建时间排序获取最新关联成品的产品库存 (出库)
-			fc := &models.FinishedConsume{}
-			err := db.Model(&models.FinishedConsume{}).
-				Where("product_id = ? and finished_id = ?", data.ProductId, ic.FinishedId).
-				Order("add_time asc").First(&fc).Error
-			if err != nil {
-				return err
-			}
 
-			var numCopy float64
-			if -fc.StockNum >= deductNum {
-				numCopy = deductNum
-				deductNum = 0
-			} else {
-				numCopy = -fc.StockNum
-				deductNum += fc.StockNum
-			}
-
-			falseValue := false
-			err = db.Model(&models.FinishedConsume{}).Create(&models.FinishedConsume{
-				BaseModel: models.BaseModel{
-					Operator: data.Operator,
-				},
-				FinishedId:       fc.FinishedId,
-				StockNum:         numCopy,
-				OperationType:    &falseValue,
-				OperationDetails: fmt.Sprintf("产品【%s】返还库存", data.Product.Name),
-			}).Error
-			if err != nil {
-				return err
-			}
-
-			stock := new(models.FinishedStock)
-			err = db.Model(&models.FinishedStock{}).
-				Where("finished_id = ?", fc.FinishedId).
-				Find(&stock).Error
-			if err != nil {
-				return err
-			}
-			if stock.ID != 0 {
-				stock.Amount += numCopy
-				err = db.Save(&stock).Error
-				break
-			} else {
-				return errors.New("成品不存在")
-			}
-		}
-	}
 	return nil
 }
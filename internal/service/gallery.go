@@ -1,23 +1,60 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"image"
+	"io"
 	"mime/multipart"
-	"os"
-	"path/filepath"
+	"path"
+	"sort"
+	"strings"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/storage"
+	"warehouse_oa/utils"
 )
 
-func GetGalleryList(gallery *models.Gallery, pn, pSize int) (interface{}, error) {
+const (
+	galleryThumbSize  = 240  // 缩略图最长边
+	galleryMediumSize = 1024 // 中图最长边
+)
+
+// GetGalleryList 获取图库列表 按相册 标签 产品 客户 上传人 上传日期组合筛选
+// 按产品筛选时同时返回该产品订单使用的图片
+func GetGalleryList(gallery *models.Gallery, tag, begTime, endTime string, pn, pSize int) (interface{}, error) {
 	db := global.Db.Model(&models.Gallery{})
 
 	if gallery.Name != "" {
 		db = db.Where("name LIKE ?", "%"+gallery.Name+"%")
 	}
+	if gallery.AlbumId != nil {
+		db = db.Where("album_id = ?", *gallery.AlbumId)
+	}
+	if tag != "" {
+		db = db.Where("CONCAT(';', tags, ';') LIKE ?", "%;"+tag+";%")
+	}
+	if gallery.ProductId != nil {
+		orderImages := global.Db.Model(&models.GalleryRef{}).Select("tb_gallery_ref.gallery_id").
+			Joins("JOIN tb_order_product ON tb_order_product.id = tb_gallery_ref.ref_id").
+			Where("tb_gallery_ref.ref_type = ? and tb_order_product.product_id = ?",
+				galleryRefOrderProduct, *gallery.ProductId)
+		db = db.Where("product_id = ? or id in (?)", *gallery.ProductId, orderImages)
+	}
+	if gallery.CustomerId != nil {
+		db = db.Where("customer_id = ?", *gallery.CustomerId)
+	}
+	if gallery.Operator != "" {
+		db = db.Where("operator = ?", gallery.Operator)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
 
 	var total int64
 	if err := db.Count(&total).Error; err != nil {
@@ -30,17 +67,46 @@ func GetGalleryList(gallery *models.Gallery, pn, pSize int) (interface{}, error)
 	}
 
 	data := make([]models.Gallery, 0)
-	err := db.Find(&data).Error
+	err := db.Preload("Album").Preload("Product").Preload("Customer").Find(&data).Error
 	if err != nil {
 		return nil, err
 	}
 
 	var imageUrls []map[string]interface{}
 	for _, d := range data {
+		var albumName, productName, customerName string
+		if d.Album != nil {
+			albumName = d.Album.Name
+		}
+		if d.Product != nil {
+			productName = d.Product.Name
+		}
+		if d.Customer != nil {
+			customerName = d.Customer.Name
+		}
+		tagList := make([]string, 0)
+		if d.Tags != "" {
+			tagList = strings.Split(d.Tags, ";")
+		}
 		imageUrls = append(imageUrls, map[string]interface{}{
-			"id":   d.ID,
-			"name": d.Name,
-			"urls": fmt.Sprintf("http://8.138.155.131:8080/images/%s", d.Url),
+			"id":           d.ID,
+			"name":         d.Name,
+			"urls":         storage.Default.URL(galleryImageKey(d.Url)),
+			"thumb":        getGalleryUrl(d.Thumb, d.Url),
+			"medium":       getGalleryUrl(d.Medium, d.Url),
+			"width":        d.Width,
+			"height":       d.Height,
+			"size":         d.Size,
+			"mimeType":     d.MimeType,
+			"albumId":      d.AlbumId,
+			"albumName":    albumName,
+			"productId":    d.ProductId,
+			"productName":  productName,
+			"customerId":   d.CustomerId,
+			"customerName": customerName,
+			"tagList":      tagList,
+			"operator":     d.Operator,
+			"createdAt":    d.CreatedAt,
 		})
 	}
 
@@ -59,38 +125,52 @@ func GetGalleryById(id int) (*models.Gallery, error) {
 	return data, err
 }
 
-func SaveGallery(gallery *models.Gallery) error {
-	err := IfGalleryByName(gallery.Name)
-	if err != nil {
-		return err
-	}
-
+// SaveGallery 保存图库记录 失败时删除已保存的图片文件
+func SaveGallery(gallery *models.Gallery) (err error) {
 	defer func() {
 		if err != nil {
-			_ = os.Remove(gallery.Url)
+			delGalleryFiles(gallery)
 		}
 	}()
 
+	err = IfGalleryByName(gallery.Name)
+	if err != nil {
+		return err
+	}
+	err = checkGallery(gallery)
+	if err != nil {
+		return err
+	}
 	err = global.Db.Model(&models.Gallery{}).Create(gallery).Error
 
 	return err
 }
 
+// UpdateGallery 修改图库图片名称 相册 标签以及关联的产品和客户 为空时取消关联
 func UpdateGallery(gallery *models.Gallery) (*models.Gallery, error) {
 	if gallery.ID == 0 {
 		return nil, errors.New("id is 0")
 	}
-	_, err := GetGalleryById(gallery.ID)
+	old, err := GetGalleryById(gallery.ID)
+	if err != nil {
+		return nil, err
+	}
+	if gallery.Name == "" {
+		gallery.Name = old.Name
+	}
+	err = checkGallery(gallery)
 	if err != nil {
 		return nil, err
 	}
 
 	gallery.Url = ""
 
-	return gallery, global.Db.Updates(&gallery).Error
+	return gallery, global.Db.Select("name", "album_id", "product_id", "customer_id",
+		"tags", "remark", "operator").Updates(&gallery).Error
 }
 
-func DelGallery(id int) error {
+// DelGallery 删除图库图片 图片被单据引用时不能删除 管理员可强制删除
+func DelGallery(id int, force bool, userId int) error {
 	if id == 0 {
 		return errors.New("id is 0")
 	}
@@ -103,14 +183,33 @@ func DelGallery(id int) error {
 		return errors.New("user does not exist")
 	}
 
-	saveDir := "./cos/images"
-	join := filepath.Join(saveDir, data.Url)
-	err = os.Remove(join)
+	var count int64
+	err = global.Db.Model(&models.GalleryRef{}).Where("gallery_id = ?", id).Count(&count).Error
 	if err != nil {
 		return err
 	}
+	if count > 0 {
+		if !force {
+			return errors.New(fmt.Sprintf("图片正在被 %d 个单据使用，不能删除", count))
+		}
+		err = checkAdmin(userId)
+		if err != nil {
+			return err
+		}
+	}
 
-	return global.Db.Delete(&data).Error
+	err = delGalleryRows(global.Db, []int{data.ID})
+	if err != nil {
+		return err
+	}
+
+	// 记录删除后再删除文件 文件删除失败时由定时任务清理
+	err = delGalleryFiles(data)
+	if err != nil {
+		logrus.Warnf("删除图片文件失败 %s: %s", data.Url, err.Error())
+	}
+
+	return nil
 }
 
 // GetGalleryFieldList 获取字段列表
@@ -130,6 +229,69 @@ func GetGalleryFieldList(field string) ([]string, error) {
 	return fields, nil
 }
 
+// GetGalleryTagList 获取图库中使用过的标签
+func GetGalleryTagList() ([]string, error) {
+	var tagsList []string
+	err := global.Db.Model(&models.Gallery{}).Where("tags <> ''").
+		Distinct("tags").Pluck("tags", &tagsList).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tagList := make([]string, 0)
+	for _, tags := range tagsList {
+		for _, tag := range strings.Split(tags, ";") {
+			if !containsString(tagList, tag) {
+				tagList = append(tagList, tag)
+			}
+		}
+	}
+	sort.Strings(tagList)
+
+	return tagList, nil
+}
+
+// checkGallery 校验相册 产品 客户 整理标签 去掉空白和重复的标签
+func checkGallery(gallery *models.Gallery) error {
+	if gallery.AlbumId != nil {
+		_, err := GetGalleryAlbumById(*gallery.AlbumId)
+		if err != nil {
+			return err
+		}
+	}
+	if gallery.ProductId != nil {
+		_, err := GetProductById(*gallery.ProductId)
+		if err != nil {
+			return err
+		}
+	}
+	if gallery.CustomerId != nil {
+		_, err := GetCustomerById(*gallery.CustomerId)
+		if err != nil {
+			return err
+		}
+	}
+
+	tagList := make([]string, 0)
+	for _, tag := range gallery.TagList {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || containsString(tagList, tag) {
+			continue
+		}
+		if strings.Contains(tag, ";") {
+			return errors.New("标签不能包含 ;")
+		}
+		tagList = append(tagList, tag)
+	}
+	gallery.Tags = strings.Join(tagList, ";")
+	if len(gallery.Tags) > 500 {
+		return errors.New("标签过多")
+	}
+	gallery.Album, gallery.Product, gallery.Customer = nil, nil, nil
+
+	return nil
+}
+
 // IfGalleryByName 判断用户名是否已存在
 func IfGalleryByName(name string) error {
 	var count int64
@@ -145,20 +307,140 @@ func IfGalleryByName(name string) error {
 	return nil
 }
 
-func SaveCosImages(f *multipart.FileHeader) (string, string, error) {
+// SaveCosImages 校验图片并保存原图 中图 缩略图到文件存储 原图按 EXIF 方向旋转并去掉元数据
+func SaveCosImages(f *multipart.FileHeader) (*models.Gallery, error) {
+	if f.Size > utils.ImageMaxSize {
+		return nil, fmt.Errorf("图片不能超过 %dM", utils.ImageMaxSize>>20)
+	}
+	src, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, utils.ImageMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	_, err = utils.SniffImage(data)
+	if err != nil {
+		return nil, err
+	}
+	img, format, err := utils.DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成唯一文件名 不使用上传的文件名
+	name := uuid.New().String()
+	gallery := &models.Gallery{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	defer func() {
+		if err != nil {
+			delGalleryFiles(gallery)
+		}
+	}()
+
+	// gif 保留原文件以保留动画 其他格式重新编码
+	original, ext := data, ".gif"
+	if format != "gif" {
+		original, ext, err = utils.EncodeImage(img, format)
+		if err != nil {
+			return nil, err
+		}
+	}
+	gallery.Url = name + ext
+	gallery.Size = len(original)
+	gallery.MimeType = storage.ContentType(gallery.Url)
+	err = putGalleryFile(gallery.Url, original)
+	if err != nil {
+		return nil, err
+	}
+
+	gallery.Medium, err = saveGalleryRendition(img, format, "medium/"+name, galleryMediumSize)
+	if err != nil {
+		return nil, err
+	}
+	gallery.Thumb, err = saveGalleryRendition(img, format, "thumb/"+name, galleryThumbSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return gallery, nil
+}
+
+// saveGalleryRendition 保存缩放后的图片 返回文件名
+func saveGalleryRendition(img image.Image, format, name string, maxSide int) (string, error) {
+	data, ext, err := utils.EncodeImage(utils.ResizeImage(img, maxSide), format)
+	if err != nil {
+		return "", err
+	}
+
+	return name + ext, putGalleryFile(name+ext, data)
+}
+
+// putGalleryFile 保存图库文件
+func putGalleryFile(name string, data []byte) error {
+	key := galleryImageKey(name)
 
-	// 创建保存路径
-	saveDir := "./cos/images"
-	if _, err := os.Stat(saveDir); os.IsNotExist(err) {
-		err = os.MkdirAll(saveDir, os.ModePerm)
+	return storage.Default.Put(context.Background(), key, bytes.NewReader(data), storage.ContentType(key))
+}
+
+// delGalleryFiles 删除图库原图以及缩放图
+func delGalleryFiles(gallery *models.Gallery) error {
+	for _, name := range []string{gallery.Url, gallery.Medium, gallery.Thumb} {
+		if name == "" {
+			continue
+		}
+		err := storage.Default.Delete(context.Background(), galleryImageKey(name))
 		if err != nil {
-			return "", "", err
+			return err
 		}
 	}
 
-	// 生成唯一文件名
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), filepath.Base(f.Filename))
-	join := filepath.Join(saveDir, filename)
+	return nil
+}
+
+// getGalleryUrl 获取缩放图地址 没有缩放图时使用原图
+func getGalleryUrl(name, url string) string {
+	if name == "" {
+		name = url
+	}
+
+	return storage.Default.URL(galleryImageKey(name))
+}
+
+// getImageThumbs 获取图片列表对应的缩略图地址 图片可以是图库文件名或完整地址
+func getImageThumbs(images []string) ([]string, error) {
+	thumbs := make([]string, 0)
+	if len(images) == 0 {
+		return thumbs, nil
+	}
+	names := make([]string, 0)
+	for _, image := range images {
+		names = append(names, path.Base(image))
+	}
+
+	var galleryList []models.Gallery
+	err := global.Db.Model(&models.Gallery{}).Where("url in ?", names).Find(&galleryList).Error
+	if err != nil {
+		return nil, err
+	}
+	galleryMap := make(map[string]models.Gallery)
+	for _, g := range galleryList {
+		galleryMap[g.Url] = g
+	}
+
+	for i, image := range images {
+		g, ok := galleryMap[names[i]]
+		if !ok {
+			thumbs = append(thumbs, image)
+			continue
+		}
+		thumbs = append(thumbs, getGalleryUrl(g.Thumb, g.Url))
+	}
 
-	return join, filename, nil
+	return thumbs, nil
 }
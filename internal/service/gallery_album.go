@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetGalleryAlbumList 获取相册列表 以及每个相册的图片数量
+func GetGalleryAlbumList() ([]models.GalleryAlbum, error) {
+	data := make([]models.GalleryAlbum, 0)
+	err := global.Db.Model(&models.GalleryAlbum{}).Order("sort asc, id asc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		AlbumId int
+		Count   int
+	}
+	err = global.Db.Model(&models.Gallery{}).Where("album_id is not null").
+		Select("album_id, count(*) as count").Group("album_id").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	countMap := make(map[int]int)
+	for _, c := range counts {
+		countMap[c.AlbumId] = c.Count
+	}
+	for i := range data {
+		data[i].Count = countMap[data[i].ID]
+	}
+
+	return data, nil
+}
+
+// GetGalleryAlbumById 根据ID获取相册
+func GetGalleryAlbumById(id int) (*models.GalleryAlbum, error) {
+	data := &models.GalleryAlbum{}
+	err := global.Db.Model(&models.GalleryAlbum{}).Where("id = ?", id).First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("相册不存在")
+	}
+
+	return data, err
+}
+
+// SaveGalleryAlbum 新增相册
+func SaveGalleryAlbum(album *models.GalleryAlbum) (*models.GalleryAlbum, error) {
+	err := checkGalleryAlbum(album)
+	if err != nil {
+		return nil, err
+	}
+
+	return album, global.Db.Model(&models.GalleryAlbum{}).Create(album).Error
+}
+
+// UpdateGalleryAlbum 修改相册
+func UpdateGalleryAlbum(album *models.GalleryAlbum) (*models.GalleryAlbum, error) {
+	if album.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	_, err := GetGalleryAlbumById(album.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkGalleryAlbum(album)
+	if err != nil {
+		return nil, err
+	}
+
+	err = global.Db.Select("name", "sort", "remark", "operator").Updates(album).Error
+
+	return album, err
+}
+
+// DelGalleryAlbum 删除相册 相册中的图片移出相册
+func DelGalleryAlbum(id int) error {
+	data, err := GetGalleryAlbumById(id)
+	if err != nil {
+		return err
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Model(&models.Gallery{}).Where("album_id = ?", id).
+		Update("album_id", nil).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Delete(data).Error
+
+	return err
+}
+
+// checkGalleryAlbum 校验相册名称不能为空且不能重复
+func checkGalleryAlbum(album *models.GalleryAlbum) error {
+	album.Name = strings.TrimSpace(album.Name)
+	if album.Name == "" {
+		return errors.New("相册名称不能为空")
+	}
+
+	var count int64
+	err := global.Db.Model(&models.GalleryAlbum{}).
+		Where("name = ? and id <> ?", album.Name, album.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("相册名称已存在")
+	}
+
+	return nil
+}
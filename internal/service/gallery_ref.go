@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"path"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/internal/storage"
+)
+
+const (
+	galleryRefOrderProduct         = "order_product"
+	galleryRefFinishedAdjust       = "finished_adjust"
+	galleryRefIngredientInspection = "ingredient_inspection"
+	galleryRefExpressClaim         = "express_claim"
+
+	// galleryOrphanAge 上传时先保存文件再写入图库 新文件不清理
+	galleryOrphanAge = time.Hour
+)
+
+// galleryRefTables 使用图库图片的表 引用类型对应的模型
+var galleryRefTables = map[string]interface{}{
+	galleryRefOrderProduct:         &models.OrderProduct{},
+	galleryRefFinishedAdjust:       &models.FinishedAdjust{},
+	galleryRefIngredientInspection: &models.IngredientInspection{},
+	galleryRefExpressClaim:         &models.ExpressClaim{},
+}
+
+// GetGalleryRefList 获取图片的引用列表
+func GetGalleryRefList(galleryId int) ([]models.GalleryRef, error) {
+	data := make([]models.GalleryRef, 0)
+	err := global.Db.Model(&models.GalleryRef{}).
+		Where("gallery_id = ?", galleryId).Order("id asc").Find(&data).Error
+
+	return data, err
+}
+
+// saveGalleryRefs 替换单据引用的图库图片 图片可以是图库文件名或完整地址 不在图库中的忽略
+func saveGalleryRefs(db *gorm.DB, refType string, refId int, images []string) error {
+	err := db.Where("ref_type = ? and ref_id = ?", refType, refId).
+		Delete(&models.GalleryRef{}).Error
+	if err != nil || len(images) == 0 {
+		return err
+	}
+
+	names := make([]string, 0)
+	for _, image := range images {
+		names = append(names, path.Base(image))
+	}
+	var galleryIds []int
+	err = db.Model(&models.Gallery{}).Where("url in ?", names).Pluck("id", &galleryIds).Error
+	if err != nil || len(galleryIds) == 0 {
+		return err
+	}
+
+	refList := make([]models.GalleryRef, 0)
+	for _, id := range galleryIds {
+		refList = append(refList, models.GalleryRef{
+			GalleryId: id,
+			RefType:   refType,
+			RefId:     refId,
+		})
+	}
+
+	return db.Model(&models.GalleryRef{}).Create(&refList).Error
+}
+
+// rebuildGalleryRefs 按各单据的图片核对引用 补充缺少的引用 删除失效的引用 返回新增和删除数量
+func rebuildGalleryRefs(db *gorm.DB) (added, removed int, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	var galleryList []models.Gallery
+	err = tx.Model(&models.Gallery{}).Select("id", "url").Find(&galleryList).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	galleryIds := make(map[string]int)
+	for _, g := range galleryList {
+		galleryIds[g.Url] = g.ID
+	}
+
+	refKey := func(galleryId int, refType string, refId int) string {
+		return fmt.Sprintf("%d_%s_%d", galleryId, refType, refId)
+	}
+	expected := make(map[string]models.GalleryRef)
+	for refType, model := range galleryRefTables {
+		var rows []struct {
+			ID     int
+			Images string
+		}
+		err = tx.Model(model).Select("id", "images").
+			Where("images is not null and images <> ''").Scan(&rows).Error
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, row := range rows {
+			for _, image := range strings.Split(row.Images, ";") {
+				id, ok := galleryIds[path.Base(image)]
+				if !ok {
+					continue
+				}
+				expected[refKey(id, refType, row.ID)] = models.GalleryRef{
+					BaseModel: models.BaseModel{
+						Operator: "system",
+					},
+					GalleryId: id,
+					RefType:   refType,
+					RefId:     row.ID,
+				}
+			}
+		}
+	}
+
+	var refList []models.GalleryRef
+	err = tx.Model(&models.GalleryRef{}).Find(&refList).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	staleIds := make([]int, 0)
+	for _, ref := range refList {
+		key := refKey(ref.GalleryId, ref.RefType, ref.RefId)
+		if _, ok := expected[key]; ok {
+			delete(expected, key)
+			continue
+		}
+		staleIds = append(staleIds, ref.ID)
+	}
+
+	if len(staleIds) > 0 {
+		err = tx.Where("id in ?", staleIds).Delete(&models.GalleryRef{}).Error
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	missing := make([]models.GalleryRef, 0)
+	for _, ref := range expected {
+		missing = append(missing, ref)
+	}
+	if len(missing) > 0 {
+		err = tx.Model(&models.GalleryRef{}).CreateInBatches(&missing, 500).Error
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(missing), len(staleIds), nil
+}
+
+// galleryOrphanJob 核对图片引用 删除文件已丢失且没有引用的图库记录 删除没有图库记录的文件
+func galleryOrphanJob(ctx context.Context) (string, error) {
+	db := global.Db.WithContext(ctx)
+
+	added, removed, err := rebuildGalleryRefs(db)
+	if err != nil {
+		return "", err
+	}
+
+	var galleryList []models.Gallery
+	err = db.Model(&models.Gallery{}).Find(&galleryList).Error
+	if err != nil {
+		return "", err
+	}
+	objects, err := storage.Default.List(ctx, "images")
+	if err != nil {
+		return "", err
+	}
+	// 存储目录配置错误时会把所有图片当作丢失
+	if len(objects) == 0 && len(galleryList) > 0 {
+		return "", errors.New("图片目录为空，请检查文件存储配置")
+	}
+
+	files := make(map[string]bool)
+	for _, o := range objects {
+		files[o.Key] = true
+	}
+
+	missingIds := make([]int, 0)
+	for _, g := range galleryList {
+		if !files[galleryImageKey(g.Url)] {
+			missingIds = append(missingIds, g.ID)
+		}
+	}
+
+	// 文件丢失但仍被单据引用的记录只报告 不删除
+	var refIds []int
+	if len(missingIds) > 0 {
+		err = db.Model(&models.GalleryRef{}).Where("gallery_id in ?", missingIds).
+			Distinct().Pluck("gallery_id", &refIds).Error
+		if err != nil {
+			return "", err
+		}
+	}
+	referenced := make(map[int]bool)
+	for _, id := range refIds {
+		referenced[id] = true
+	}
+	delIds := make([]int, 0)
+	for _, id := range missingIds {
+		if !referenced[id] {
+			delIds = append(delIds, id)
+		}
+	}
+	if len(delIds) > 0 {
+		err = delGalleryRows(db, delIds)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 保留仍有图库记录的文件
+	deleted := make(map[int]bool)
+	for _, id := range delIds {
+		deleted[id] = true
+	}
+	keys := make(map[string]bool)
+	for _, g := range galleryList {
+		if deleted[g.ID] {
+			continue
+		}
+		for _, name := range []string{g.Url, g.Medium, g.Thumb} {
+			if name != "" {
+				keys[galleryImageKey(name)] = true
+			}
+		}
+	}
+
+	fileCount := 0
+	for _, o := range objects {
+		if keys[o.Key] || time.Since(o.ModTime) < galleryOrphanAge {
+			continue
+		}
+		err = storage.Default.Delete(ctx, o.Key)
+		if err != nil {
+			return "", err
+		}
+		fileCount++
+	}
+
+	return fmt.Sprintf("新增图片引用 %d 条 删除失效引用 %d 条 删除文件丢失的图片 %d 条 "+
+		"文件丢失但仍被引用的图片 %d 条 删除无记录的文件 %d 个",
+		added, removed, len(delIds), len(refIds), fileCount), nil
+}
+
+// delGalleryRows 删除图库记录以及引用
+func delGalleryRows(db *gorm.DB, ids []int) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Where("gallery_id in ?", ids).Delete(&models.GalleryRef{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("id in ?", ids).Delete(&models.Gallery{}).Error
+
+	return err
+}
@@ -31,6 +31,21 @@ func GetCostByProduction(id int) (float64, error) {
 		}
 		cost += consumeCost
 	}
+
+	// 半成品按配方成本计算
+	var subConsume []models.FinishedConsume
+	err = global.Db.Model(&models.FinishedConsume{}).
+		Where("production_id = ? and stock_num < 0", id).Find(&subConsume).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range subConsume {
+		unitCost, err := getFinishedCost(c.FinishedId, map[int]bool{})
+		if err != nil {
+			return 0, err
+		}
+		cost += unitCost * (-c.StockNum)
+	}
 	logrus.Infoln("GetCostByProduction-cost", cost)
 
 	return cost, err
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+var notNumberRegexp = regexp.MustCompile(`[^0-9.\-]`)
+
+// importTransforms 支持的取值转换
+var importTransforms = map[string]func(string) string{
+	// 去掉导出时为防止科学计数法添加的 = " ' 以及制表符
+	"stripQuote": func(s string) string {
+		s = strings.TrimPrefix(s, "=")
+		return strings.Trim(s, "\"'\t ")
+	},
+	// 取最后一段 如 "中通快递 7312345" 取快递单号
+	"lastWord": func(s string) string {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			return s
+		}
+		return fields[len(fields)-1]
+	},
+	// 去掉数字以外的字符 如 "2件"
+	"number": func(s string) string {
+		return notNumberRegexp.ReplaceAllString(s, "")
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// builtinImportProfiles 内置导入模板
+var builtinImportProfiles = []*models.ImportProfile{
+	{
+		Code:     "default",
+		Name:     "默认电商账单",
+		BillType: 1,
+	},
+	{
+		Code:        "taobao",
+		Name:        "淘宝/天猫订单报表",
+		BillType:    1,
+		DateFormats: "2006-01-02 15:04:05;2006/01/02 15:04:05",
+		Columns: []models.ImportProfileColumn{
+			{Field: "客户名称", Aliases: "店铺名称"},
+			{Field: "主订单编号", Aliases: "订单编号", Transforms: "stripQuote"},
+			{Field: "快递单号", Aliases: "物流单号;运单号", Transforms: "stripQuote;lastWord"},
+			{Field: "商品标题", Aliases: "宝贝标题;标题"},
+			{Field: "商品销售规格", Aliases: "商品属性;宝贝属性;销售属性"},
+			{Field: "数量", Aliases: "宝贝总数量;购买数量", Transforms: "number"},
+			{Field: "发货时间", Aliases: "订单付款时间"},
+			{Field: "备注", Aliases: "订单备注;卖家备注"},
+		},
+	},
+	{
+		Code:        "douyin",
+		Name:        "抖店订单导出",
+		BillType:    1,
+		DateFormats: "2006/01/02 15:04:05;2006-01-02 15:04:05",
+		Columns: []models.ImportProfileColumn{
+			{Field: "客户名称", Aliases: "店铺名称"},
+			{Field: "主订单编号", Aliases: "订单编号;订单号", Transforms: "stripQuote"},
+			{Field: "快递单号", Aliases: "运单号;快递信息", Transforms: "stripQuote;lastWord"},
+			{Field: "商品标题", Aliases: "选购商品;商品名称"},
+			{Field: "商品销售规格", Aliases: "商品规格"},
+			{Field: "数量", Aliases: "商品数量", Transforms: "number"},
+			{Field: "发货时间", Aliases: "订单完成时间;订单提交时间"},
+			{Field: "备注", Aliases: "商家备注;买家留言"},
+		},
+	},
+	{
+		Code:        "pinduoduo",
+		Name:        "拼多多订单导出",
+		BillType:    1,
+		DateFormats: "2006-01-02 15:04:05;2006/01/02 15:04:05",
+		Columns: []models.ImportProfileColumn{
+			{Field: "客户名称", Aliases: "店铺名称"},
+			{Field: "主订单编号", Aliases: "订单号", Transforms: "stripQuote"},
+			{Field: "快递单号", Aliases: "运单号", Transforms: "stripQuote"},
+			{Field: "商品标题", Aliases: "商品;商品名称"},
+			{Field: "商品销售规格", Aliases: "商品规格;样式"},
+			{Field: "数量", Aliases: "商品数量(件);商品数量（件）;商品数量", Transforms: "number"},
+			{Field: "发货时间", Aliases: "订单成交时间"},
+			{Field: "备注", Aliases: "商家备注"},
+		},
+	},
+	{
+		Code:     "fastDefault",
+		Name:     "默认快递账单",
+		BillType: 2,
+	},
+}
+
+func init() {
+	for _, p := range builtinImportProfiles {
+		p.Builtin = true
+		setImportProfileList(p)
+	}
+}
+
+// GetImportProfileList 导入模板列表 包含内置模板
+func GetImportProfileList(billType int) ([]*models.ImportProfile, error) {
+	data := make([]*models.ImportProfile, 0)
+	for _, p := range builtinImportProfiles {
+		if billType > 0 && p.BillType != billType {
+			continue
+		}
+		data = append(data, p)
+	}
+
+	db := global.Db.Model(&models.ImportProfile{})
+	if billType > 0 {
+		db = db.Where("bill_type = ?", billType)
+	}
+	custom := make([]*models.ImportProfile, 0)
+	err := db.Preload("Columns").Order("id").Find(&custom).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range custom {
+		setImportProfileList(p)
+	}
+
+	return append(data, custom...), nil
+}
+
+// SaveImportProfile 保存自定义导入模板
+func SaveImportProfile(profile *models.ImportProfile) (*models.ImportProfile, error) {
+	err := checkImportProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	err = global.Db.Model(&models.ImportProfile{}).Where("code = ?", profile.Code).Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+	if total > 0 || getBuiltinImportProfile(profile.Code) != nil {
+		return nil, errors.New("模板编码已存在")
+	}
+
+	err = global.Db.Model(&models.ImportProfile{}).Create(profile).Error
+
+	return profile, err
+}
+
+// UpdateImportProfile 修改自定义导入模板
+func UpdateImportProfile(profile *models.ImportProfile) (*models.ImportProfile, error) {
+	if profile.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	old := &models.ImportProfile{}
+	err := global.Db.Model(&models.ImportProfile{}).Where("id = ?", profile.ID).First(old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("导入模板不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 编码被店铺引用 不允许修改
+	profile.Code = old.Code
+	err = checkImportProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Where("profile_id = ?", profile.ID).Delete(&models.ImportProfileColumn{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Select("Name", "BillType", "DateFormats", "Operator").Updates(profile).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range profile.Columns {
+		profile.Columns[i].ProfileId = profile.ID
+	}
+	if len(profile.Columns) > 0 {
+		err = tx.Model(&models.ImportProfileColumn{}).Create(&profile.Columns).Error
+	}
+
+	return profile, err
+}
+
+// DelImportProfile 删除自定义导入模板
+func DelImportProfile(id int) error {
+	profile := &models.ImportProfile{}
+	err := global.Db.Model(&models.ImportProfile{}).Where("id = ?", id).First(profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("导入模板不存在")
+	}
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	err = global.Db.Model(&models.ECommCustomers{}).
+		Where("import_profile = ?", profile.Code).Count(&total).Error
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return errors.New("导入模板被店铺使用，无法删除")
+	}
+
+	return global.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("profile_id = ?", id).Delete(&models.ImportProfileColumn{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(profile).Error
+	})
+}
+
+// getImportProfile 根据编码获取导入模板
+func getImportProfile(code string, billType int) (*models.ImportProfile, error) {
+	if code == "" {
+		code = "default"
+		if billType == 2 {
+			code = "fastDefault"
+		}
+	}
+
+	profile := getBuiltinImportProfile(code)
+	if profile == nil {
+		profile = &models.ImportProfile{}
+		err := global.Db.Model(&models.ImportProfile{}).Where("code = ?", code).
+			Preload("Columns").First(profile).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("导入模板 %s 不存在", code)
+		}
+		if err != nil {
+			return nil, err
+		}
+		setImportProfileList(profile)
+	}
+	if profile.BillType != billType {
+		return nil, errors.New("导入模板的账单类型不匹配")
+	}
+
+	return profile, nil
+}
+
+func getBuiltinImportProfile(code string) *models.ImportProfile {
+	for _, p := range builtinImportProfiles {
+		if p.Code == code {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func checkImportProfile(profile *models.ImportProfile) error {
+	profile.Code = strings.TrimSpace(profile.Code)
+	if profile.Code == "" || profile.Name == "" {
+		return errors.New("模板编码和名称不能为空")
+	}
+	headers := eCommBillHeaders
+	switch profile.BillType {
+	case 1:
+	case 2:
+		headers = fastBillHeaders
+	default:
+		return errors.New("账单类型错误")
+	}
+
+	if len(profile.DateFormatList) > 0 {
+		profile.DateFormats = strings.Join(profile.DateFormatList, ";")
+	}
+	for i := range profile.Columns {
+		c := &profile.Columns[i]
+		if !containsString(headers, c.Field) {
+			return fmt.Errorf("列 %s 不存在，可选: %s", c.Field, strings.Join(headers, "、"))
+		}
+		if len(c.AliasList) > 0 {
+			c.Aliases = strings.Join(c.AliasList, ";")
+		}
+		if len(c.TransformList) > 0 {
+			c.Transforms = strings.Join(c.TransformList, ";")
+		}
+		for _, t := range splitProfileValue(c.Transforms) {
+			if _, ok := importTransforms[t]; !ok {
+				return fmt.Errorf("取值转换 %s 不存在", t)
+			}
+		}
+	}
+
+	return nil
+}
+
+func setImportProfileList(profile *models.ImportProfile) {
+	profile.DateFormatList = splitProfileValue(profile.DateFormats)
+	for i := range profile.Columns {
+		c := &profile.Columns[i]
+		c.AliasList = splitProfileValue(c.Aliases)
+		c.TransformList = splitProfileValue(c.Transforms)
+	}
+}
+
+// profileAlias 表头别名 -> 标准列名
+func profileAlias(profile *models.ImportProfile) map[string]string {
+	alias := make(map[string]string)
+	for _, c := range profile.Columns {
+		for _, a := range splitProfileValue(c.Aliases) {
+			alias[a] = c.Field
+		}
+	}
+
+	return alias
+}
+
+// applyImportProfile 按模板转换数据行
+func applyImportProfile(profile *models.ImportProfile, data map[string]string) {
+	for _, c := range profile.Columns {
+		value, ok := data[c.Field]
+		if !ok {
+			continue
+		}
+		for _, t := range splitProfileValue(c.Transforms) {
+			if fn, ok := importTransforms[t]; ok {
+				value = fn(value)
+			}
+		}
+		data[c.Field] = strings.TrimSpace(value)
+	}
+}
+
+// profileDateLayouts 模板日期格式 为空时使用默认格式 支持 yyyy-MM-dd HH:mm:ss 写法
+func profileDateLayouts(profile *models.ImportProfile) []string {
+	layouts := make([]string, 0)
+	replacer := strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02",
+		"HH", "15", "mm", "04", "ss", "05")
+	for _, f := range splitProfileValue(profile.DateFormats) {
+		layouts = append(layouts, replacer.Replace(f))
+	}
+	if len(layouts) == 0 {
+		return importTimeLayouts
+	}
+
+	return append(layouts, importTimeLayouts...)
+}
+
+func splitProfileValue(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ";") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
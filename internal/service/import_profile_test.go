@@ -0,0 +1,87 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"warehouse_oa/internal/models"
+)
+
+func TestApplyImportProfile(t *testing.T) {
+	profile := &models.ImportProfile{
+		Columns: []models.ImportProfileColumn{
+			{Field: "订单号", Transforms: "stripQuote"},
+			{Field: "快递单号", Transforms: "stripQuote; lastWord ;upper"},
+			{Field: "数量", Transforms: "number"},
+			{Field: "店铺", Transforms: "lower;notExist"},
+			{Field: "备注"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		data map[string]string
+		want map[string]string
+	}{
+		{
+			name: "去掉防止科学计数法的符号",
+			data: map[string]string{"订单号": "=\"2024030512345678\""},
+			want: map[string]string{"订单号": "2024030512345678"},
+		},
+		{
+			name: "单引号和制表符",
+			data: map[string]string{"订单号": "'2024030512345678\t"},
+			want: map[string]string{"订单号": "2024030512345678"},
+		},
+		{
+			name: "按顺序执行多个转换",
+			data: map[string]string{"快递单号": "\t中通快递 yt7312345"},
+			want: map[string]string{"快递单号": "YT7312345"},
+		},
+		{
+			name: "空值",
+			data: map[string]string{"快递单号": ""},
+			want: map[string]string{"快递单号": ""},
+		},
+		{
+			name: "只保留数字",
+			data: map[string]string{"数量": "共 -2.5 件"},
+			want: map[string]string{"数量": "-2.5"},
+		},
+		{
+			name: "不存在的转换忽略",
+			data: map[string]string{"店铺": "ABC旗舰店"},
+			want: map[string]string{"店铺": "abc旗舰店"},
+		},
+		{
+			name: "没有转换时去掉首尾空格",
+			data: map[string]string{"备注": "  加急  "},
+			want: map[string]string{"备注": "加急"},
+		},
+		{
+			name: "模板以外的列不处理 缺少的列不添加",
+			data: map[string]string{"金额": " =\"12\" "},
+			want: map[string]string{"金额": " =\"12\" "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyImportProfile(profile, tt.data)
+			if !reflect.DeepEqual(tt.data, tt.want) {
+				t.Errorf("applyImportProfile() = %v, want %v", tt.data, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileDateLayouts(t *testing.T) {
+	got := profileDateLayouts(&models.ImportProfile{})
+	if !reflect.DeepEqual(got, importTimeLayouts) {
+		t.Errorf("profileDateLayouts() = %v, want default layouts", got)
+	}
+
+	got = profileDateLayouts(&models.ImportProfile{DateFormats: "yyyy年MM月dd日 HH:mm:ss; dd.MM.yyyy"})
+	want := append([]string{"2006年01月02日 15:04:05", "02.01.2006"}, importTimeLayouts...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profileDateLayouts() = %v, want %v", got, want)
+	}
+}
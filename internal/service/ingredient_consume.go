@@ -15,7 +15,7 @@ import (
 
 // GetConsumeList 返回出入库列表查询数据
 func GetConsumeList(ids, stockUnit, begTime, endTime string,
-	inOrOut, pn, pSize, isPackage int) (interface{}, error) {
+	inOrOut, pn, pSize, isPackage, locationId int) (interface{}, error) {
 
 	db := global.Db.Model(&models.IngredientConsume{})
 	totalDb := global.Db.Model(&models.IngredientConsume{})
@@ -44,6 +44,10 @@ func GetConsumeList(ids, stockUnit, begTime, endTime string,
 		db = db.Where("stock_num < 0")
 		totalDb = totalDb.Where("stock_num < 0")
 	}
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+		totalDb = totalDb.Where("location_id = ?", locationId)
+	}
 
 	consumeCost, err := GetConsumeAllCost()
 	db = db.Preload("Ingredient")
@@ -88,7 +92,7 @@ func GetConsumeList(ids, stockUnit, begTime, endTime string,
 
 // GetIngredientSum 返回出入库列表查询数据
 func GetIngredientSum(ids, stockUnit, begTime, endTime string,
-	inOrOut int) (interface{}, error) {
+	inOrOut, locationId int) (interface{}, error) {
 
 	enterDb := global.Db.Model(&models.IngredientConsume{})
 	outDb := global.Db.Model(&models.IngredientConsume{})
@@ -106,6 +110,10 @@ func GetIngredientSum(ids, stockUnit, begTime, endTime string,
 		enterDb = enterDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 		outDb = outDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 	}
+	if locationId > 0 {
+		enterDb = enterDb.Where("location_id = ?", locationId)
+		outDb = outDb.Where("location_id = ?", locationId)
+	}
 
 	var enterNum, outNum float64
 	err := enterDb.Where("stock_num >= 0").Select("IFNULL(SUM(stock_num), 0) AS stock_num").First(&enterNum).Error
@@ -186,6 +194,7 @@ func SaveConsumeByInBound(db *gorm.DB, inBound *models.IngredientInBound, detail
 		OperationType:    &b,
 		OperationDetails: details,
 		IsPackage:        inBound.IsPackage,
+		LocationId:       inBound.LocationId,
 	})
 
 	return err
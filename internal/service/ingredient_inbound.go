@@ -15,7 +15,7 @@ import (
 
 // GetInBoundList 返回入库列表查询数据
 func GetInBoundList(name, stockUnit, supplier, begTime, endTime string,
-	pn, pSize, isPackage int) (interface{}, error) {
+	pn, pSize, isPackage, locationId int) (interface{}, error) {
 	db := global.Db.Model(&models.IngredientInBound{})
 	totalDb := global.Db.Model(&models.IngredientInBound{})
 
@@ -43,6 +43,10 @@ func GetInBoundList(name, stockUnit, supplier, begTime, endTime string,
 		db = db.Where("DATE_FORMAT(stock_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 		totalDb = totalDb.Where("DATE_FORMAT(stock_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 	}
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+		totalDb = totalDb.Where("location_id = ?", locationId)
+	}
 
 	// 应结金额
 	var totalPrice float64
@@ -98,6 +102,8 @@ func GetInBoundList(name, stockUnit, supplier, begTime, endTime string,
 			StockUnit:       d.StockUnit,
 			StockUser:       d.StockUser,
 			StockTime:       d.StockTime,
+			LocationId:      d.LocationId,
+			InspectStatus:   d.InspectStatus,
 			FinishPriceList: make([]map[string]string, 0),
 		}
 
@@ -161,6 +167,10 @@ func SaveInBound(inBound *models.IngredientInBound) (*models.IngredientInBound,
 	if err != nil {
 		return nil, err
 	}
+	err = CheckLocation(inBound.LocationId)
+	if err != nil {
+		return nil, err
+	}
 
 	totalPrice := big.NewFloat(inBound.TotalPrice)
 	stockNum := big.NewFloat(inBound.StockNum)
@@ -169,6 +179,9 @@ func SaveInBound(inBound *models.IngredientInBound) (*models.IngredientInBound,
 	inBound.Ingredient = ingredients
 	inBound.UnitPrice, _ = price.Float64()
 	inBound.FinishPrice = 0.0
+	if inBound.InspectStatus != 1 {
+		inBound.InspectStatus = 0
+	}
 
 	db := global.Db
 	tx := db.Begin()
@@ -185,6 +198,11 @@ func SaveInBound(inBound *models.IngredientInBound) (*models.IngredientInBound,
 		return nil, err
 	}
 
+	// 需要检验的配料检验后再入库存
+	if inBound.InspectStatus == 1 {
+		return inBound, nil
+	}
+
 	// 添加配料库存
 	err = SaveStockByInBound(tx, inBound)
 	if err != nil {
@@ -225,6 +243,8 @@ func UpdateInBound(inBound *models.IngredientInBound) (*models.IngredientInBound
 	stockNum := big.NewFloat(inBound.StockNum)
 	price := new(big.Float).Quo(totalPrice, stockNum)
 	inBound.UnitPrice, _ = price.Float64()
+	// 检验状态只能通过检验接口修改
+	inBound.InspectStatus = oldData.InspectStatus
 
 	db := global.Db
 	tx := db.Begin()
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetPendingInspectionList 待检验入库列表
+func GetPendingInspectionList(name, supplier string, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.IngredientInBound{})
+	db = db.Where("inspect_status = ?", 1)
+
+	if name != "" {
+		idList, err := GetIngredientsByName(name)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("ingredient_id in ?", idList)
+	}
+	if supplier != "" {
+		slice := strings.Split(supplier, ";")
+		db = db.Where("supplier in ?", slice)
+	}
+	db = db.Preload("Ingredient").Order("stock_time")
+
+	return Pagination(db, []models.IngredientInBound{}, pn, pSize)
+}
+
+// GetInspectionList 检验记录列表
+func GetInspectionList(inBoundId int, begTime, endTime string, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.IngredientInspection{})
+
+	if inBoundId != 0 {
+		db = db.Where("in_bound_id = ?", inBoundId)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(inspect_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if pn != 0 && pSize != 0 {
+		offset := (pn - 1) * pSize
+		db = db.Limit(pSize).Offset(offset)
+	}
+
+	data := make([]models.IngredientInspection, 0)
+	err := db.Preload("InBound.Ingredient").Order("inspect_time desc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i].ImageList = make([]string, 0)
+		if data[i].Images != "" {
+			data[i].ImageList = strings.Split(data[i].Images, ";")
+		}
+	}
+
+	return map[string]interface{}{
+		"data":       data,
+		"pageNo":     pn,
+		"pageSize":   pSize,
+		"totalCount": total,
+	}, nil
+}
+
+// GetReturnList 退供应商列表
+func GetReturnList(supplier, begTime, endTime string, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.IngredientReturn{})
+	totalDb := global.Db.Model(&models.IngredientReturn{})
+
+	if supplier != "" {
+		slice := strings.Split(supplier, ";")
+		db = db.Where("supplier in ?", slice)
+		totalDb = totalDb.Where("supplier in ?", slice)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(return_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+		totalDb = totalDb.Where("DATE_FORMAT(return_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	// 冲减金额合计
+	var debitPrice float64
+	if err := totalDb.Select("COALESCE(SUM(debit_price), 0)").Scan(&debitPrice).Error; err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if pn != 0 && pSize != 0 {
+		offset := (pn - 1) * pSize
+		db = db.Limit(pSize).Offset(offset)
+	}
+
+	data := make([]models.IngredientReturn, 0)
+	err := db.Preload("Ingredient").Order("return_time desc").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"data":          data,
+		"pageNo":        pn,
+		"pageSize":      pSize,
+		"totalCount":    total,
+		"sumDebitPrice": debitPrice,
+	}, nil
+}
+
+// InspectInBound 来料检验 合格数量入库存 不合格数量退供应商并冲减应付金额
+func InspectInBound(inspection *models.IngredientInspection) (err error) {
+	if inspection.InBoundId == 0 {
+		return errors.New("id is 0")
+	}
+	inBound, err := GetInBoundById(inspection.InBoundId)
+	if err != nil {
+		return err
+	}
+	if inBound.InspectStatus != 1 {
+		return errors.New("该入库记录不需要检验或已检验")
+	}
+	if inspection.AcceptedNum < 0 || inspection.RejectedNum < 0 {
+		return errors.New("检验数量错误")
+	}
+	if math.Abs(inspection.AcceptedNum+inspection.RejectedNum-inBound.StockNum) > 0.0001 {
+		return errors.New("合格数量与不合格数量之和必须等于到货数量")
+	}
+
+	now := time.Now()
+	inspection.ReceivedNum = inBound.StockNum
+	inspection.InspectTime = now
+	inspection.Images = strings.Join(inspection.ImageList, ";")
+	inspection.InBound = nil
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Model(&models.IngredientInspection{}).Create(inspection).Error
+	if err != nil {
+		return err
+	}
+	err = saveGalleryRefs(tx, galleryRefIngredientInspection, inspection.ID, inspection.ImageList)
+	if err != nil {
+		return err
+	}
+
+	inBound.Operator = inspection.Operator
+	inBound.StockNum = inspection.AcceptedNum
+	inBound.InspectStatus = 2
+
+	if inspection.RejectedNum > 0 {
+		debitPrice := math.Round(inspection.RejectedNum*inBound.UnitPrice*100) / 100
+		err = tx.Model(&models.IngredientReturn{}).Create(&models.IngredientReturn{
+			BaseModel: models.BaseModel{
+				Operator: inspection.Operator,
+				Remark:   inspection.Remark,
+			},
+			InBoundId:    inBound.ID,
+			IngredientId: inBound.IngredientId,
+			Supplier:     inBound.Supplier,
+			StockNum:     inspection.RejectedNum,
+			StockUnit:    inBound.StockUnit,
+			UnitPrice:    inBound.UnitPrice,
+			DebitPrice:   debitPrice,
+			ReturnTime:   now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		inBound.TotalPrice -= debitPrice
+		if inBound.TotalPrice-inBound.FinishPrice > 0 {
+			inBound.Status = 0
+		} else {
+			inBound.Status = 1
+		}
+	}
+
+	err = tx.Select("Operator", "StockNum", "TotalPrice", "Status",
+		"InspectStatus").Updates(inBound).Error
+	if err != nil {
+		return err
+	}
+
+	if inBound.StockNum == 0 {
+		return nil
+	}
+
+	// 合格数量入库存
+	err = SaveStockByInBound(tx, inBound)
+	if err != nil {
+		return err
+	}
+
+	err = SaveConsumeByInBound(tx, inBound, "配料入库")
+
+	return err
+}
@@ -10,10 +10,13 @@ import (
 )
 
 // GetStockList 获取库存列表
-func GetStockList(name string, pn, pSize, isPackage int) (interface{}, error) {
+func GetStockList(name string, pn, pSize, isPackage, locationId int) (interface{}, error) {
 	db := global.Db.Model(&models.IngredientStock{})
 	db = db.Select("ingredient_id, stock_unit, sum(stock_num) as stock_num, max(add_time) as add_time")
 	db = db.Where("is_package = ?", isPackage)
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+	}
 	db = db.Group("ingredient_id, stock_unit")
 
 	if name != "" {
@@ -112,6 +115,7 @@ func SaveStockByInBound(db *gorm.DB, inBound *models.IngredientInBound) error {
 	Stock := new(models.IngredientStock)
 	err := db.Model(&models.IngredientStock{}).
 		Where("ingredient_id = ? and stock_unit = ?", *inBound.IngredientId, inBound.StockUnit).
+		Where("location_id = ?", inBound.LocationId).
 		Find(&Stock).Error
 	if err != nil {
 		return err
@@ -130,6 +134,7 @@ func SaveStockByInBound(db *gorm.DB, inBound *models.IngredientInBound) error {
 			StockNum:     inBound.StockNum,
 			StockUnit:    inBound.StockUnit,
 			IsPackage:    inBound.IsPackage,
+			LocationId:   inBound.LocationId,
 		})
 	}
 
@@ -187,11 +192,14 @@ func DeductOrderAttach(db *gorm.DB, order *models.Order,
 		}
 
 		stock := &models.IngredientStock{}
-		err = global.Db.Model(&models.IngredientStock{}).
+		stockDb := global.Db.Model(&models.IngredientStock{}).
 			Where("ingredient_id = ?", *ingredientStock.IngredientId).
 			Where("stock_unit = ?", ingredientStock.StockUnit).
-			Where("stock_num > ?", 0).
-			Order("add_time asc").First(&stock).Error
+			Where("stock_num > ?", 0)
+		if ingredientStock.LocationId > 0 {
+			stockDb = stockDb.Where("location_id = ?", ingredientStock.LocationId)
+		}
+		err = stockDb.Order("add_time asc").First(&stock).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New(fmt.Sprintf("id: %d 附加材料库存不足", *ingredientStock.IngredientId))
 		}
@@ -217,6 +225,7 @@ func DeductOrderAttach(db *gorm.DB, order *models.Order,
 				OperationType:    &falseValue,
 				OperationDetails: fmt.Sprintf("订单【%s】附加材料", order.OrderNumber),
 				IsPackage:        ingredientStock.IsPackage,
+				LocationId:       stock.LocationId,
 			})
 
 			stock.StockNum -= ingredientStock.StockNum
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"math"
+	"sort"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/utils"
+)
+
+// GetPriceHistory 配料采购价格走势 以及供应商价格对比
+func GetPriceHistory(ids, supplier, begTime, endTime string) (interface{}, error) {
+	points, err := getPricePoints(ids, supplier, begTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[int][]models.IngredientPricePoint)
+	for _, p := range points {
+		series[p.IngredientId] = append(series[p.IngredientId], p)
+	}
+
+	return map[string]interface{}{
+		"series":    series,
+		"suppliers": getSupplierPriceStat(points),
+	}, nil
+}
+
+// GetPriceDeviation 查询单价偏离前N次入库均价超过阈值(百分比)的入库记录
+func GetPriceDeviation(ids, supplier, begTime, endTime string,
+	window int, threshold float64) ([]models.IngredientPricePoint, error) {
+	if window <= 0 {
+		window = 5
+	}
+	if threshold <= 0 {
+		threshold = 20
+	}
+
+	// 均价需要包含查询时间之前的入库记录
+	points, err := getPricePoints(ids, "", "", endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var supplierList []string
+	if supplier != "" {
+		supplierList = strings.Split(supplier, ";")
+	}
+
+	history := make(map[string][]float64)
+	data := make([]models.IngredientPricePoint, 0)
+	for _, p := range points {
+		key := fmt.Sprintf("%d_%d", p.IngredientId, p.NormalUnit)
+		prices := history[key]
+		history[key] = append(prices, p.NormalPrice)
+		if len(prices) == 0 {
+			continue
+		}
+		if len(prices) > window {
+			prices = prices[len(prices)-window:]
+		}
+
+		var sum float64
+		for _, v := range prices {
+			sum += v
+		}
+		p.TrailingAverage = sum / float64(len(prices))
+		if p.TrailingAverage == 0 {
+			continue
+		}
+		p.Deviation = (p.NormalPrice - p.TrailingAverage) / p.TrailingAverage * 100
+
+		if begTime != "" && p.StockTime.Format("2006-01-02") < begTime {
+			continue
+		}
+		if len(supplierList) > 0 && !containsString(supplierList, p.Supplier) {
+			continue
+		}
+		if math.Abs(p.Deviation) > threshold {
+			data = append(data, p)
+		}
+	}
+
+	return data, nil
+}
+
+// ExportPriceHistory 配料采购价格导出
+func ExportPriceHistory(ids, supplier, begTime, endTime string) (*excelize.File, error) {
+	points, err := getPricePoints(ids, supplier, begTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := []string{
+		"配料名称",
+		"供应商",
+		"入库时间",
+		"入库数量",
+		"单价（元）",
+		"换算单价（元）",
+		"最低单价（元）",
+		"平均单价（元）",
+		"最高单价（元）",
+		"入库次数",
+	}
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, p := range points {
+		valueList = append(valueList, map[string]interface{}{
+			"配料名称":    p.IngredientName,
+			"供应商":     p.Supplier,
+			"入库时间":    p.StockTime.Format("2006-01-02"),
+			"入库数量":    fmt.Sprintf("%.2f%s", p.StockNum, returnUnit(p.StockUnit)),
+			"单价（元）":   fmt.Sprintf("%.2f/%s", p.UnitPrice, returnUnit(p.StockUnit)),
+			"换算单价（元）": fmt.Sprintf("%.2f/%s", p.NormalPrice, returnUnit(p.NormalUnit)),
+		})
+	}
+	for _, s := range getSupplierPriceStat(points) {
+		valueList = append(valueList, map[string]interface{}{
+			"配料名称":    s.IngredientName,
+			"供应商":     s.Supplier,
+			"最低单价（元）": fmt.Sprintf("%.2f/%s", s.MinPrice, returnUnit(s.NormalUnit)),
+			"平均单价（元）": fmt.Sprintf("%.2f/%s", s.AvgPrice, returnUnit(s.NormalUnit)),
+			"最高单价（元）": fmt.Sprintf("%.2f/%s", s.MaxPrice, returnUnit(s.NormalUnit)),
+			"入库次数":    s.Count,
+		})
+	}
+
+	return utils.ExportExcel(keyList, valueList, []string{"F", "H"})
+}
+
+// ExportPriceDeviation 配料价格异常导出
+func ExportPriceDeviation(ids, supplier, begTime, endTime string,
+	window int, threshold float64) (*excelize.File, error) {
+	points, err := GetPriceDeviation(ids, supplier, begTime, endTime, window, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := []string{
+		"配料名称",
+		"供应商",
+		"入库时间",
+		"换算单价（元）",
+		"前期均价（元）",
+		"偏离比例",
+	}
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, p := range points {
+		valueList = append(valueList, map[string]interface{}{
+			"配料名称":    p.IngredientName,
+			"供应商":     p.Supplier,
+			"入库时间":    p.StockTime.Format("2006-01-02"),
+			"换算单价（元）": fmt.Sprintf("%.2f/%s", p.NormalPrice, returnUnit(p.NormalUnit)),
+			"前期均价（元）": fmt.Sprintf("%.2f/%s", p.TrailingAverage, returnUnit(p.NormalUnit)),
+			"偏离比例":    fmt.Sprintf("%.2f%%", p.Deviation),
+		})
+	}
+
+	return utils.ExportExcel(keyList, valueList, []string{"F"})
+}
+
+// getPricePoints 按入库时间查询配料价格
+func getPricePoints(ids, supplier, begTime, endTime string) ([]models.IngredientPricePoint, error) {
+	db := global.Db.Model(&models.IngredientInBound{})
+
+	if ids != "" {
+		idList := strings.Split(ids, ";")
+		db = db.Where("ingredient_id in ?", idList)
+	}
+	if supplier != "" {
+		slice := strings.Split(supplier, ";")
+		db = db.Where("supplier in ?", slice)
+	}
+	if begTime != "" {
+		db = db.Where("DATE_FORMAT(stock_time, '%Y-%m-%d') >= ?", begTime)
+	}
+	if endTime != "" {
+		db = db.Where("DATE_FORMAT(stock_time, '%Y-%m-%d') <= ?", endTime)
+	}
+
+	data := make([]models.IngredientInBound, 0)
+	err := db.Preload("Ingredient").Order("stock_time, id").Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.IngredientPricePoint, 0)
+	for _, d := range data {
+		if d.IngredientId == nil || d.StockNum == 0 {
+			continue
+		}
+		p := models.IngredientPricePoint{
+			InBoundId:    d.ID,
+			IngredientId: *d.IngredientId,
+			Supplier:     d.Supplier,
+			UnitPrice:    d.UnitPrice,
+			StockUnit:    d.StockUnit,
+			StockNum:     d.StockNum,
+			StockTime:    d.StockTime,
+		}
+		if d.Ingredient != nil {
+			p.IngredientName = d.Ingredient.Name
+		}
+		p.NormalPrice, p.NormalUnit = normalizeUnitPrice(d.UnitPrice, d.StockUnit)
+		points = append(points, p)
+	}
+
+	return points, nil
+}
+
+// getSupplierPriceStat 统计每个供应商的最低 平均(按数量加权) 最高单价
+func getSupplierPriceStat(points []models.IngredientPricePoint) []models.SupplierPriceStat {
+	statMap := make(map[string]*models.SupplierPriceStat)
+	amountMap := make(map[string]float64)
+	for _, p := range points {
+		key := fmt.Sprintf("%d_%s_%d", p.IngredientId, p.Supplier, p.NormalUnit)
+		normalNum, _ := normalizeUnitNum(p.StockNum, p.StockUnit)
+
+		s, ok := statMap[key]
+		if !ok {
+			s = &models.SupplierPriceStat{
+				IngredientId:   p.IngredientId,
+				IngredientName: p.IngredientName,
+				Supplier:       p.Supplier,
+				NormalUnit:     p.NormalUnit,
+				MinPrice:       p.NormalPrice,
+				MaxPrice:       p.NormalPrice,
+			}
+			statMap[key] = s
+		}
+		s.MinPrice = math.Min(s.MinPrice, p.NormalPrice)
+		s.MaxPrice = math.Max(s.MaxPrice, p.NormalPrice)
+		s.Count++
+		s.TotalNum += normalNum
+		amountMap[key] += p.NormalPrice * normalNum
+	}
+
+	data := make([]models.SupplierPriceStat, 0)
+	for key, s := range statMap {
+		if s.TotalNum != 0 {
+			s.AvgPrice = amountMap[key] / s.TotalNum
+		}
+		data = append(data, *s)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].IngredientId != data[j].IngredientId {
+			return data[i].IngredientId < data[j].IngredientId
+		}
+		return data[i].AvgPrice < data[j].AvgPrice
+	})
+
+	return data
+}
+
+// normalizeUnitPrice 单价换算 克统一换算为斤 其余单位不变
+func normalizeUnitPrice(price float64, unit int) (float64, int) {
+	if unit == 2 {
+		return price * 500, 1
+	}
+	return price, unit
+}
+
+// normalizeUnitNum 数量换算 克统一换算为斤 其余单位不变
+func normalizeUnitNum(num float64, unit int) (float64, int) {
+	if unit == 2 {
+		return num / 500, 1
+	}
+	return num, unit
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
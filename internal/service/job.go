@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/utils"
+)
+
+type job struct {
+	name     string
+	spec     string
+	desc     string
+	schedule *utils.CronSchedule
+	run      func(ctx context.Context) (string, error)
+
+	mu       sync.Mutex
+	running  bool
+	nextTime *time.Time
+}
+
+var (
+	jobMap = make(map[string]*job)
+	// jobMu 保护 jobCtx 以及手动执行时的 jobWait.Add
+	jobMu   sync.Mutex
+	jobCtx  = context.Background()
+	jobWait sync.WaitGroup
+)
+
+// InitJobs 注册定时任务
+func InitJobs() error {
+	err := RegisterJob("overdueProduction", "* * * * *",
+		"报工超过预计完成时间仍在生产中的改为超时", overdueProductionJob)
+	if err != nil {
+		return err
+	}
+
+	err = RegisterJob("billReconcile", "0 3 * * *",
+		"电商账单与快递账单按订单编号和快递单号对账", billReconcileJob)
+	if err != nil {
+		return err
+	}
+
+	return RegisterJob("galleryOrphan", "0 4 * * *",
+		"重建图库图片引用 清理文件丢失的图库记录和没有图库记录的文件", galleryOrphanJob)
+}
+
+// RegisterJob 注册定时任务 spec 为 "分 时 日 月 周" 或 "@every 时长"
+func RegisterJob(name, spec, desc string, run func(ctx context.Context) (string, error)) error {
+	if _, ok := jobMap[name]; ok {
+		return fmt.Errorf("定时任务 %s 已存在", name)
+	}
+	schedule, err := utils.ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	jobMap[name] = &job{
+		name:     name,
+		spec:     spec,
+		desc:     desc,
+		schedule: schedule,
+		run:      run,
+	}
+
+	return nil
+}
+
+// StartJobs 启动定时任务 ctx 取消后不再触发新的执行 并等待执行中的任务结束
+func StartJobs(ctx context.Context) {
+	jobMu.Lock()
+	jobCtx = ctx
+	jobMu.Unlock()
+	for _, j := range jobMap {
+		jobWait.Add(1)
+		go func(j *job) {
+			defer jobWait.Done()
+			j.loop(ctx)
+		}(j)
+	}
+
+	<-ctx.Done()
+	// 加锁后 ctx 已取消 不会再有新的手动执行加入 jobWait
+	jobMu.Lock()
+	jobMu.Unlock()
+	jobWait.Wait()
+	logrus.Infoln("定时任务已停止")
+}
+
+// GetJobList 定时任务列表
+func GetJobList(userId int) ([]*models.JobInfo, error) {
+	if err := checkAdmin(userId); err != nil {
+		return nil, err
+	}
+
+	data := make([]*models.JobInfo, 0)
+	for _, j := range jobMap {
+		j.mu.Lock()
+		info := &models.JobInfo{
+			Name:     j.name,
+			Spec:     j.spec,
+			Desc:     j.desc,
+			Running:  j.running,
+			NextTime: j.nextTime,
+		}
+		j.mu.Unlock()
+
+		lastRun := &models.JobRun{}
+		err := global.Db.Model(&models.JobRun{}).Where("job_name = ?", j.name).
+			Order("start_time desc").Limit(1).Find(lastRun).Error
+		if err != nil {
+			return nil, err
+		}
+		if lastRun.ID != 0 {
+			info.LastRun = lastRun
+		}
+		data = append(data, info)
+	}
+	sort.Slice(data, func(i, k int) bool {
+		return data[i].Name < data[k].Name
+	})
+
+	return data, nil
+}
+
+// GetJobRunList 定时任务执行记录
+func GetJobRunList(name string, status, pn, pSize, userId int) (interface{}, error) {
+	if err := checkAdmin(userId); err != nil {
+		return nil, err
+	}
+
+	db := global.Db.Model(&models.JobRun{})
+	if name != "" {
+		db = db.Where("job_name = ?", name)
+	}
+	if status >= 0 {
+		db = db.Where("status = ?", status)
+	}
+	db = db.Order("start_time desc")
+
+	return Pagination(db, []models.JobRun{}, pn, pSize)
+}
+
+// RunJob 手动执行定时任务
+func RunJob(name string, userId int) (*models.JobRun, error) {
+	if err := checkAdmin(userId); err != nil {
+		return nil, err
+	}
+	j, ok := jobMap[name]
+	if !ok {
+		return nil, errors.New("定时任务不存在")
+	}
+
+	// 手动执行也加入 jobWait 停止服务时等待执行结束
+	jobMu.Lock()
+	ctx := jobCtx
+	if ctx.Err() != nil {
+		jobMu.Unlock()
+		return nil, errors.New("服务正在停止")
+	}
+	jobWait.Add(1)
+	jobMu.Unlock()
+	defer jobWait.Done()
+
+	return j.execute(ctx, true)
+}
+
+// loop 按执行计划等待下一次执行
+func (j *job) loop(ctx context.Context) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			logrus.Errorf("定时任务 %s 没有下一次执行时间", j.name)
+			return
+		}
+		j.mu.Lock()
+		j.nextTime = &next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		_, err := j.execute(ctx, false)
+		if err != nil {
+			logrus.Errorf("定时任务 %s 执行失败: %s", j.name, err.Error())
+		}
+	}
+}
+
+// execute 执行任务并保存执行记录 同一任务不会同时执行
+func (j *job) execute(ctx context.Context, manual bool) (run *models.JobRun, err error) {
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		return nil, fmt.Errorf("定时任务 %s 正在执行", j.name)
+	}
+	j.running = true
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		j.running = false
+		j.mu.Unlock()
+	}()
+
+	run = &models.JobRun{
+		BaseModel: models.BaseModel{
+			Operator: "system",
+		},
+		JobName:   j.name,
+		Manual:    manual,
+		StartTime: time.Now(),
+	}
+	if err = global.Db.Model(&models.JobRun{}).Create(run).Error; err != nil {
+		return nil, err
+	}
+
+	message, err := j.safeRun(ctx)
+	endTime := time.Now()
+	run.EndTime = &endTime
+	run.Status = 1
+	run.Message = message
+	if err != nil {
+		run.Status = 2
+		run.Message = err.Error()
+	}
+	if saveErr := global.Db.Select("EndTime", "Status", "Message").Updates(run).Error; saveErr != nil {
+		logrus.Errorf("定时任务 %s 保存执行记录失败: %s", j.name, saveErr.Error())
+	}
+
+	return run, err
+}
+
+// safeRun 任务 panic 时记为失败
+func (j *job) safeRun(ctx context.Context) (message string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("定时任务异常: %v", r)
+		}
+	}()
+
+	return j.run(ctx)
+}
+
+// overdueProductionJob 报工超时 批量修改状态
+func overdueProductionJob(ctx context.Context) (string, error) {
+	result := global.Db.WithContext(ctx).Model(&models.FinishedProduction{}).
+		Where("estimated_time <= ?", time.Now()).
+		Where("status = ?", 1).
+		Update("status", 4)
+	if result.Error != nil {
+		return "", result.Error
+	}
+
+	return fmt.Sprintf("%d 条报工超时", result.RowsAffected), nil
+}
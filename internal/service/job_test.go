@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// TestJobCtxRace 启动和停止定时任务时并发读取 jobCtx 使用 go test -race 检查
+func TestJobCtxRace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		StartJobs(ctx)
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobMu.Lock()
+			_ = jobCtx.Err()
+			jobMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	cancel()
+	<-done
+
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	if jobCtx.Err() == nil {
+		t.Error("停止后 jobCtx 应已取消")
+	}
+}
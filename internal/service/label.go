@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// 标签字体 存在时标签上打印中文名称
+const labelFontPath = "./cos/fonts/label.ttf"
+
+// GetLabel 根据类型和ID获取标签信息
+func GetLabel(labelType string, id int) (*models.Label, error) {
+	label := &models.Label{
+		Code: fmt.Sprintf("%s-%d", labelType, id),
+		Type: labelType,
+	}
+
+	switch labelType {
+	case "IB":
+		inBound, err := GetInBoundById(id)
+		if err != nil {
+			return nil, err
+		}
+		if inBound.IngredientId == nil {
+			return nil, errors.New("入库记录没有原料")
+		}
+		ingredient, err := GetIngredientsById(*inBound.IngredientId)
+		if err != nil {
+			return nil, err
+		}
+		label.Name = ingredient.Name
+		label.Desc = fmt.Sprintf("%s %.2f%s", inBound.StockTime.Format("2006-01-02"),
+			inBound.StockNum, returnUnit(inBound.StockUnit))
+	case "FS":
+		finished, err := GetFinishedById(id)
+		if err != nil {
+			return nil, err
+		}
+		label.Name = finished.Name
+	case "PD":
+		product, err := GetProductById(id)
+		if err != nil {
+			return nil, err
+		}
+		label.Name = product.Name
+		label.Desc = product.Specification
+	case "OP":
+		orderProduct, err := GetOrderProductById(id)
+		if err != nil {
+			return nil, err
+		}
+		order := &models.Order{}
+		err = global.Db.Model(&models.Order{}).Where("id = ?", orderProduct.OrderId).
+			First(order).Error
+		if err != nil {
+			return nil, err
+		}
+		label.Name = orderProduct.ProductName
+		label.Desc = fmt.Sprintf("%s x%d", order.OrderNumber, orderProduct.Amount)
+	default:
+		return nil, errors.New("标签类型错误")
+	}
+
+	return label, nil
+}
+
+// GetLabelPng 生成标签图片 format: code128 qr
+func GetLabelPng(code, format string) ([]byte, error) {
+	var bc barcode.Barcode
+	var err error
+	var width, height int
+
+	switch format {
+	case "qr":
+		bc, err = qr.Encode(code, qr.M, qr.Auto)
+		width, height = 200, 200
+	case "", "code128":
+		bc, err = code128.Encode(code)
+		width, height = 300, 80
+	default:
+		return nil, errors.New("条码格式错误")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bc, err = barcode.Scale(bc, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, bc)
+
+	return buf.Bytes(), err
+}
+
+// ExportLabelPdf 生成A4标签打印页 每页3列8行
+func ExportLabelPdf(labelType, ids, format string, copies int) ([]byte, error) {
+	if ids == "" {
+		return nil, errors.New("请选择需要打印的标签")
+	}
+	if copies <= 0 {
+		copies = 1
+	}
+
+	labelList := make([]*models.Label, 0)
+	for _, s := range strings.Split(ids, ";") {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("ID错误")
+		}
+		label, err := GetLabel(labelType, id)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < copies; i++ {
+			labelList = append(labelList, label)
+		}
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	fontName := "Helvetica"
+	hasFont := false
+	if _, err := os.Stat(labelFontPath); err == nil {
+		pdf.AddUTF8Font("label", "", labelFontPath)
+		fontName = "label"
+		hasFont = true
+	}
+
+	const cols, rows = 3, 8
+	const cellW, cellH = 70.0, 37.125
+	for i, label := range labelList {
+		if i%(cols*rows) == 0 {
+			pdf.AddPage()
+		}
+		x := float64(i%cols) * cellW
+		y := float64(i%(cols*rows)/cols) * cellH
+
+		img, err := GetLabelPng(label.Code, format)
+		if err != nil {
+			return nil, err
+		}
+		opt := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(label.Code, opt, bytes.NewReader(img))
+
+		if format == "qr" {
+			pdf.ImageOptions(label.Code, x+3, y+3, 25, 25, false, opt, 0, "")
+			pdf.SetFont(fontName, "", 8)
+			pdf.SetXY(x+30, y+5)
+			pdf.CellFormat(38, 5, label.Code, "", 2, "L", false, 0, "")
+			if hasFont {
+				pdf.SetX(x + 30)
+				pdf.CellFormat(38, 5, label.Name, "", 2, "L", false, 0, "")
+				pdf.SetX(x + 30)
+				pdf.CellFormat(38, 5, label.Desc, "", 2, "L", false, 0, "")
+			}
+		} else {
+			pdf.ImageOptions(label.Code, x+5, y+3, 60, 16, false, opt, 0, "")
+			pdf.SetFont(fontName, "", 8)
+			pdf.SetXY(x+5, y+20)
+			pdf.CellFormat(60, 4, label.Code, "", 2, "C", false, 0, "")
+			if hasFont {
+				pdf.SetX(x + 5)
+				pdf.CellFormat(60, 4, label.Name, "", 2, "C", false, 0, "")
+				pdf.SetX(x + 5)
+				pdf.CellFormat(60, 4, label.Desc, "", 2, "C", false, 0, "")
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err := pdf.Output(buf)
+
+	return buf.Bytes(), err
+}
+
+// ScanCode 扫码查询 返回对应数据以及各库位当前库存
+func ScanCode(code string) (*models.ScanResult, error) {
+	labelType, idStr, ok := strings.Cut(strings.TrimSpace(code), "-")
+	if !ok {
+		return nil, errors.New("无法识别的条码")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, errors.New("无法识别的条码")
+	}
+
+	label, err := GetLabel(strings.ToUpper(labelType), id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &models.ScanResult{Label: *label}
+	switch label.Type {
+	case "IB":
+		var inBound *models.IngredientInBound
+		inBound, err = GetInBoundById(id)
+		if err != nil {
+			return nil, err
+		}
+		if inBound.IngredientId == nil {
+			return nil, errors.New("入库记录没有原料")
+		}
+		data.Entity = inBound
+		err = global.Db.Model(&models.IngredientStock{}).
+			Select("location_id, stock_unit, SUM(stock_num) as amount").
+			Where("ingredient_id = ?", *inBound.IngredientId).
+			Group("location_id, stock_unit").
+			Scan(&data.Stock).Error
+	case "FS":
+		data.Entity, err = GetFinishedById(id)
+		if err != nil {
+			return nil, err
+		}
+		err = global.Db.Model(&models.FinishedStock{}).
+			Select("location_id, SUM(amount) as amount").
+			Where("finished_id = ?", id).
+			Group("location_id").
+			Scan(&data.Stock).Error
+	case "PD":
+		data.Entity, err = GetProductById(id)
+		if err != nil {
+			return nil, err
+		}
+		err = getProductLocationStock(id, &data.Stock)
+	case "OP":
+		var orderProduct *models.OrderProduct
+		orderProduct, err = GetOrderProductById(id)
+		if err != nil {
+			return nil, err
+		}
+		data.Entity = orderProduct
+		err = getProductLocationStock(orderProduct.ProductId, &data.Stock)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range data.Stock {
+		data.Total += data.Stock[i].Amount
+		if data.Stock[i].LocationId == 0 {
+			data.Stock[i].LocationName = "未指定库位"
+			continue
+		}
+		location, err := GetLocationById(data.Stock[i].LocationId)
+		if err == nil {
+			data.Stock[i].LocationName = returnLocationName(location)
+		}
+	}
+
+	return data, nil
+}
+
+// getProductLocationStock 产品各库位库存
+func getProductLocationStock(productId int, stock *[]models.LocationStock) error {
+	return global.Db.Model(&models.ProductInventory{}).
+		Select("location_id, SUM(amount) as amount").
+		Where("product_id = ?", productId).
+		Group("location_id").
+		Scan(stock).Error
+}
@@ -0,0 +1,488 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/utils"
+)
+
+// mrp 物料需求计算过程数据
+type mrp struct {
+	productPool    map[int]float64
+	finishedPool   map[int]float64
+	ingredientPool map[string]float64
+
+	productList    map[int]*models.MrpProduct
+	productionList map[int]*models.MrpProduction
+	purchaseList   map[string]*models.MrpPurchase
+	skippedList    []*models.MrpSkipped
+
+	productCache  map[int]*models.Product
+	finishedCache map[int]*models.Finished
+	bomCache      map[int]*models.FinishedBom
+}
+
+// GetMrp 根据待出库订单计算物料需求 返回产品缺口 建议报工 建议采购 以及无法展开的订单产品
+func GetMrp(endTime string) (map[string]interface{}, error) {
+	m, err := runMrp(endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	productList := make([]*models.MrpProduct, 0)
+	for _, p := range m.productList {
+		productList = append(productList, p)
+	}
+	sort.Slice(productList, func(i, j int) bool {
+		return productList[i].ProductId < productList[j].ProductId
+	})
+
+	productionList := make([]*models.MrpProduction, 0)
+	for _, p := range m.productionList {
+		if p.Shortfall <= 0 {
+			continue
+		}
+		p.SuggestAmount = int(math.Ceil(p.Shortfall))
+		productionList = append(productionList, p)
+	}
+	sort.Slice(productionList, func(i, j int) bool {
+		return productionList[i].ShortfallDate.Before(productionList[j].ShortfallDate)
+	})
+
+	purchaseList := make([]*models.MrpPurchase, 0)
+	for _, p := range m.purchaseList {
+		if p.Shortfall <= 0 {
+			continue
+		}
+		inBound := &models.IngredientInBound{}
+		err = global.Db.Model(&models.IngredientInBound{}).
+			Where("ingredient_id = ? and stock_unit = ?", p.IngredientId, p.StockUnit).
+			Order("stock_time desc").Limit(1).Find(inBound).Error
+		if err != nil {
+			return nil, err
+		}
+		p.Supplier = inBound.Supplier
+		p.UnitPrice = inBound.UnitPrice
+		purchaseList = append(purchaseList, p)
+	}
+	sort.Slice(purchaseList, func(i, j int) bool {
+		return purchaseList[i].ShortfallDate.Before(purchaseList[j].ShortfallDate)
+	})
+
+	return map[string]interface{}{
+		"product":    productList,
+		"production": productionList,
+		"purchase":   purchaseList,
+		"skipped":    m.skippedList,
+	}, nil
+}
+
+// ExportMrp 物料需求导出
+func ExportMrp(endTime string) (*excelize.File, error) {
+	data, err := GetMrp(endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := []string{
+		"类型",
+		"名称",
+		"需求数量",
+		"库存数量",
+		"生产中数量",
+		"缺口数量",
+		"建议数量",
+		"最早缺货日期",
+		"供应商",
+		"参考单价（元）",
+		"说明",
+	}
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, p := range data["product"].([]*models.MrpProduct) {
+		value := map[string]interface{}{
+			"类型":   "产品",
+			"名称":   fmt.Sprintf("%s(%s)", p.ProductName, p.Specification),
+			"需求数量": fmt.Sprintf("%.0f", p.Required),
+			"库存数量": fmt.Sprintf("%.0f", p.Stock),
+			"缺口数量": fmt.Sprintf("%.0f", p.Shortfall),
+		}
+		if p.Shortfall > 0 {
+			value["最早缺货日期"] = p.ShortfallDate.Format("2006-01-02")
+		}
+		valueList = append(valueList, value)
+	}
+	for _, p := range data["production"].([]*models.MrpProduction) {
+		valueList = append(valueList, map[string]interface{}{
+			"类型":     "建议报工",
+			"名称":     p.FinishedName,
+			"需求数量":   fmt.Sprintf("%.2f", p.Required),
+			"库存数量":   fmt.Sprintf("%.2f", p.Stock),
+			"生产中数量":  fmt.Sprintf("%.0f", p.InProduction),
+			"缺口数量":   fmt.Sprintf("%.2f", p.Shortfall),
+			"建议数量":   p.SuggestAmount,
+			"最早缺货日期": p.ShortfallDate.Format("2006-01-02"),
+		})
+	}
+	for _, p := range data["purchase"].([]*models.MrpPurchase) {
+		unit := returnUnit(p.StockUnit)
+		valueList = append(valueList, map[string]interface{}{
+			"类型":      "建议采购",
+			"名称":      p.IngredientName,
+			"需求数量":    fmt.Sprintf("%.2f%s", p.Required, unit),
+			"库存数量":    fmt.Sprintf("%.2f%s", p.Stock, unit),
+			"缺口数量":    fmt.Sprintf("%.2f%s", p.Shortfall, unit),
+			"建议数量":    fmt.Sprintf("%.2f%s", p.Shortfall, unit),
+			"最早缺货日期":  p.ShortfallDate.Format("2006-01-02"),
+			"供应商":     p.Supplier,
+			"参考单价（元）": fmt.Sprintf("%.2f/%s", p.UnitPrice, unit),
+		})
+	}
+
+	for _, p := range data["skipped"].([]*models.MrpSkipped) {
+		valueList = append(valueList, map[string]interface{}{
+			"类型": "未计算",
+			"名称": p.OrderNumber,
+			"说明": p.Reason,
+		})
+	}
+
+	return utils.ExportExcel(keyList, valueList, []string{"F", "G"})
+}
+
+// runMrp 按销售日期依次展开订单产品 产品->成品->配料 并与库存抵扣
+func runMrp(endTime string) (*mrp, error) {
+	m := &mrp{
+		productPool:    make(map[int]float64),
+		finishedPool:   make(map[int]float64),
+		ingredientPool: make(map[string]float64),
+		productList:    make(map[int]*models.MrpProduct),
+		productionList: make(map[int]*models.MrpProduction),
+		purchaseList:   make(map[string]*models.MrpPurchase),
+		skippedList:    make([]*models.MrpSkipped, 0),
+		productCache:   make(map[int]*models.Product),
+		finishedCache:  make(map[int]*models.Finished),
+		bomCache:       make(map[int]*models.FinishedBom),
+	}
+	if err := m.loadStock(); err != nil {
+		return nil, err
+	}
+
+	db := global.Db.Model(&models.Order{}).Where("status = ?", 1)
+	if endTime != "" {
+		db = db.Where("DATE_FORMAT(sale_date, '%Y-%m-%d') <= ?", endTime)
+	}
+	db = db.Preload("OrderProduct", "status = ?", false)
+	db = db.Preload("OrderProduct.Ingredient.Ingredient")
+	db = db.Preload("OrderProduct.UseFinished")
+
+	orderList := make([]models.Order, 0)
+	if err := db.Order("sale_date, id").Find(&orderList).Error; err != nil {
+		return nil, err
+	}
+
+	for _, order := range orderList {
+		for _, op := range order.OrderProduct {
+			if err := m.needProduct(&order, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// loadStock 汇总各库位库存 生产中的报工计入成品可用数量
+func (m *mrp) loadStock() error {
+	var productStock []struct {
+		ProductId int
+		Amount    float64
+	}
+	err := global.Db.Model(&models.ProductInventory{}).
+		Select("product_id, SUM(amount) as amount").
+		Group("product_id").Scan(&productStock).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range productStock {
+		m.productPool[s.ProductId] = s.Amount
+	}
+
+	var finishedStock []struct {
+		FinishedId int
+		Amount     float64
+	}
+	err = global.Db.Model(&models.FinishedStock{}).
+		Select("finished_id, SUM(amount) as amount").
+		Group("finished_id").Scan(&finishedStock).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range finishedStock {
+		m.finishedPool[s.FinishedId] = s.Amount
+	}
+
+	var ingredientStock []struct {
+		IngredientId int
+		StockUnit    int
+		Amount       float64
+	}
+	err = global.Db.Model(&models.IngredientStock{}).
+		Select("ingredient_id, stock_unit, SUM(stock_num) as amount").
+		Group("ingredient_id, stock_unit").Scan(&ingredientStock).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range ingredientStock {
+		m.ingredientPool[fmt.Sprintf("%d_%d", s.IngredientId, s.StockUnit)] = s.Amount
+	}
+
+	return nil
+}
+
+// needProduct 订单产品需求 产品库存不足时展开成品
+func (m *mrp) needProduct(order *models.Order, op *models.OrderProduct) error {
+	product, err := m.getProduct(op.ProductId)
+	if err != nil {
+		return err
+	}
+	// 产品已删除时跳过该订单产品 在结果中列出
+	if product == nil {
+		m.skippedList = append(m.skippedList, &models.MrpSkipped{
+			OrderNumber: order.OrderNumber,
+			ProductId:   op.ProductId,
+			Reason:      fmt.Sprintf("产品【%d】不存在", op.ProductId),
+		})
+		return nil
+	}
+
+	p, ok := m.productList[op.ProductId]
+	if !ok {
+		p = &models.MrpProduct{
+			ProductId:     product.ID,
+			ProductName:   product.Name,
+			Specification: product.Specification,
+			Stock:         m.productPool[op.ProductId],
+		}
+		m.productList[op.ProductId] = p
+	}
+
+	amount := float64(op.Amount)
+	p.Required += amount
+	use := math.Min(math.Max(m.productPool[op.ProductId], 0), amount)
+	m.productPool[op.ProductId] -= use
+	short := amount - use
+	if short > 0 {
+		if p.Shortfall == 0 {
+			p.ShortfallDate = order.SaleDate
+		}
+		p.Shortfall += short
+	}
+
+	// 附加材料出库时无论产品库存是否充足都会消耗
+	for _, a := range op.Ingredient {
+		if a.IngredientId == nil {
+			continue
+		}
+		name := ""
+		if a.Ingredient != nil {
+			name = a.Ingredient.Name
+		}
+		m.needIngredient(*a.IngredientId, a.StockUnit, name, a.Quantity*amount, order.SaleDate)
+	}
+
+	if short <= 0 {
+		return nil
+	}
+
+	// 订单指定成品时按订单成品计算 否则按产品组成计算
+	if len(op.UseFinished) > 0 {
+		for _, u := range op.UseFinished {
+			if err = m.needFinished(order, u.FinishedId, u.Quantity*short); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, content := range product.ProductContent {
+		if err = m.needFinished(order, content.FinishedId, content.Quantity*short); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// needFinished 成品需求 成品库存不足时展开配料
+func (m *mrp) needFinished(order *models.Order, finishedId int, amount float64) error {
+	finished, err := m.getFinished(finishedId)
+	if err != nil {
+		return err
+	}
+	if finished == nil {
+		m.skippedList = append(m.skippedList, &models.MrpSkipped{
+			OrderNumber: order.OrderNumber,
+			FinishedId:  finishedId,
+			Reason:      fmt.Sprintf("成品【%d】不存在", finishedId),
+		})
+		return nil
+	}
+
+	f, ok := m.productionList[finishedId]
+	if !ok {
+		f = &models.MrpProduction{
+			FinishedId:   finished.ID,
+			FinishedName: finished.Name,
+			Stock:        m.finishedPool[finishedId],
+			OrderNumbers: make([]string, 0),
+		}
+		// 部分完工的数量已入成品库存 只计算未完工的数量
+		err = global.Db.Model(&models.FinishedProduction{}).
+			Select("COALESCE(SUM(GREATEST(expect_amount - actual_amount, 0)), 0)").
+			Where("finished_id = ? and status in ?", finishedId, []int{1, 4}).
+			Scan(&f.InProduction).Error
+		if err != nil {
+			return err
+		}
+		m.finishedPool[finishedId] += f.InProduction
+		m.productionList[finishedId] = f
+	}
+
+	f.Required += amount
+	use := math.Min(math.Max(m.finishedPool[finishedId], 0), amount)
+	m.finishedPool[finishedId] -= use
+	short := amount - use
+	if short <= 0 {
+		return nil
+	}
+
+	if f.Shortfall == 0 {
+		f.ShortfallDate = order.SaleDate
+	}
+	f.Shortfall += short
+	if !containsString(f.OrderNumbers, order.OrderNumber) {
+		f.OrderNumbers = append(f.OrderNumbers, order.OrderNumber)
+	}
+
+	bom, err := m.getBom(finishedId)
+	if err != nil {
+		return err
+	}
+	if bom == nil {
+		m.skippedList = append(m.skippedList, &models.MrpSkipped{
+			OrderNumber: order.OrderNumber,
+			FinishedId:  finishedId,
+			Reason:      fmt.Sprintf("成品【%s】没有生效的配方", finished.Name),
+		})
+		return nil
+	}
+
+	for _, material := range bom.Material {
+		name := ""
+		if material.Ingredient != nil {
+			name = material.Ingredient.Name
+		}
+		m.needIngredient(material.IngredientId, material.StockUnit, name,
+			material.Quantity*short, order.SaleDate)
+	}
+
+	// 半成品逐级展开 保存配方时已校验循环引用
+	for _, sub := range bom.SubMaterial {
+		if err = m.needFinished(order, sub.SubFinishedId, sub.Quantity*short); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// needIngredient 配料需求
+func (m *mrp) needIngredient(ingredientId, stockUnit int, name string, amount float64, date time.Time) {
+	key := fmt.Sprintf("%d_%d", ingredientId, stockUnit)
+
+	p, ok := m.purchaseList[key]
+	if !ok {
+		p = &models.MrpPurchase{
+			IngredientId:   ingredientId,
+			IngredientName: name,
+			StockUnit:      stockUnit,
+			Stock:          m.ingredientPool[key],
+		}
+		m.purchaseList[key] = p
+	}
+
+	p.Required += amount
+	use := math.Min(math.Max(m.ingredientPool[key], 0), amount)
+	m.ingredientPool[key] -= use
+	short := amount - use
+	if short <= 0 {
+		return
+	}
+
+	if p.Shortfall == 0 {
+		p.ShortfallDate = date
+	}
+	p.Shortfall += short
+}
+
+// getProduct 查询产品 不存在时返回 nil
+func (m *mrp) getProduct(id int) (*models.Product, error) {
+	if p, ok := m.productCache[id]; ok {
+		return p, nil
+	}
+	p := &models.Product{}
+	err := global.Db.Model(&models.Product{}).Preload("ProductContent").
+		Where("id = ?", id).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p = nil
+	} else if err != nil {
+		return nil, err
+	}
+	m.productCache[id] = p
+
+	return p, nil
+}
+
+// getFinished 查询成品 不存在时返回 nil
+func (m *mrp) getFinished(id int) (*models.Finished, error) {
+	if f, ok := m.finishedCache[id]; ok {
+		return f, nil
+	}
+	f := &models.Finished{}
+	err := global.Db.Model(&models.Finished{}).Where("id = ?", id).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		f = nil
+	} else if err != nil {
+		return nil, err
+	}
+	m.finishedCache[id] = f
+
+	return f, nil
+}
+
+// getBom 查询成品当前生效的配方版本 没有时返回 nil
+func (m *mrp) getBom(finishedId int) (*models.FinishedBom, error) {
+	if bom, ok := m.bomCache[finishedId]; ok {
+		return bom, nil
+	}
+	bom := &models.FinishedBom{}
+	err := global.Db.Model(&models.FinishedBom{}).
+		Where("finished_id = ? and effective_time <= ?", finishedId, time.Now()).
+		Order("effective_time desc, version desc").
+		Preload("Material.Ingredient").Preload("SubMaterial").First(&bom).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bom = nil
+	} else if err != nil {
+		return nil, err
+	}
+	m.bomCache[finishedId] = bom
+
+	return bom, nil
+}
@@ -9,6 +9,7 @@ import (
 	_ "image/png"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 	"warehouse_oa/internal/global"
@@ -183,6 +184,10 @@ func GetListById(id int) (interface{}, error) {
 		if op.Images != "" {
 			op.ImageList = strings.Split(op.Images, ";")
 		}
+		op.ThumbList, err = getImageThumbs(op.ImageList)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	cost, err := GetCostByOrder(data)
@@ -232,12 +237,13 @@ func GetOrderProductById(id int) (*models.OrderProduct, error) {
 func SaveOrder(order *models.Order) (*models.Order, error) {
 	var err error
 
-	_, err = GetCustomerById(order.CustomerId)
+	customer, err := GetCustomerById(order.CustomerId)
 	if err != nil {
 		return nil, err
 	}
 
 	var totalPrice float64
+	costs := make(map[int]float64)
 	for _, orderProduct := range order.OrderProduct {
 		if orderProduct.UserList == nil {
 			return nil, errors.New("包装分配不能为空")
@@ -265,6 +271,17 @@ func SaveOrder(order *models.Order) (*models.Order, error) {
 		}
 		orderProduct.Specification = product.Specification
 
+		// 记录下单时的标准成本以及建议售价
+		orderProduct.StandardCost, err = getProductCost(product, costs)
+		if err != nil {
+			return nil, err
+		}
+		marginRate := product.MarginRate
+		if customer.MarginRate > 0 {
+			marginRate = customer.MarginRate
+		}
+		orderProduct.SuggestPrice = getSuggestPrice(orderProduct.StandardCost, marginRate)
+
 		orderProduct.Images = strings.Join(orderProduct.ImageList, ";")
 		orderProduct.UseFinished = make([]models.UseFinished, 0)
 		for _, p := range product.ProductContent {
@@ -287,10 +304,25 @@ func SaveOrder(order *models.Order) (*models.Order, error) {
 	order.FinishPrice = 0
 	order.Status = 1
 
-	err = global.Db.Model(&models.Order{}).Create(&order).Error
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Model(&models.Order{}).Create(&order).Error
 	if err != nil {
 		return nil, err
 	}
+	for _, orderProduct := range order.OrderProduct {
+		err = saveGalleryRefs(tx, galleryRefOrderProduct, orderProduct.ID, orderProduct.ImageList)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return order, err
 }
@@ -324,11 +356,15 @@ func UpdateOrder(order *models.Order) (*models.Order, error) {
 }
 
 // OutOfStock 出库
-func OutOfStock(orderId, orderProductId, userId int, username string) error {
+func OutOfStock(orderId, orderProductId, locationId, userId int, username string) error {
 	order, err := GetOrderById(orderId)
 	if err != nil {
 		return err
 	}
+	err = CheckLocation(locationId)
+	if err != nil {
+		return err
+	}
 	op, err := GetOrderProductById(orderProductId)
 	if err != nil {
 		return err
@@ -360,7 +396,7 @@ func OutOfStock(orderId, orderProductId, userId int, username string) error {
 		}
 	}()
 
-	surplusNum, err := DeductProductStock(tx, op.ProductId, op.Amount)
+	drawn, surplusNum, err := DeductProductStock(tx, op.ProductId, op.Amount, locationId)
 	if err != nil {
 		return err
 	}
@@ -368,16 +404,15 @@ func OutOfStock(orderId, orderProductId, userId int, username string) error {
 	logrus.Infoln(surplusNum)
 
 	trueValue := true
-	err = tx.Model(&models.ProductConsume{}).Create(&models.ProductConsume{
+	err = saveProductConsumeByLocation(tx, models.ProductConsume{
 		BaseModel: models.BaseModel{
 			Operator: username,
 		},
 		OrderId:          &order.ID,
 		ProductId:        op.ProductId,
-		StockNum:         0 - float64(op.Amount) + float64(surplusNum),
 		OperationType:    &trueValue,
 		OperationDetails: fmt.Sprintf("订单【%s】出库", order.OrderNumber),
-	}).Error
+	}, drawn)
 	if err != nil {
 		return err
 	}
@@ -385,9 +420,16 @@ func OutOfStock(orderId, orderProductId, userId int, username string) error {
 	// 消耗成品
 	if surplusNum > 0 {
 		for _, u := range op.UseFinished {
-			err = DeductFinishedStock(tx, order, &models.FinishedStock{
+			err = DeductFinishedStockByConsume(tx, models.FinishedConsume{
+				BaseModel: models.BaseModel{
+					Operator: order.Operator,
+				},
+				OrderId:          &order.ID,
+				OperationDetails: fmt.Sprintf("【%s】销售出库", order.OrderNumber),
+			}, &models.FinishedStock{
 				FinishedId: u.FinishedId,
 				Amount:     u.Quantity * float64(surplusNum),
+				LocationId: locationId,
 			})
 			if err != nil {
 				return err
@@ -403,6 +445,7 @@ func OutOfStock(orderId, orderProductId, userId int, username string) error {
 				StockNum:     ingredient.Quantity * float64(op.Amount),
 				StockUnit:    ingredient.StockUnit,
 				IsPackage:    1,
+				LocationId:   locationId,
 			})
 		if err != nil {
 			return err
@@ -624,7 +667,14 @@ func ExportOrder(order *models.Order) ([]byte, error) {
 		return nil, err
 	}
 
-	newName := fmt.Sprintf("./cos/execl/%d.xlsx", order.ID)
+	// 在临时目录生成送货单 转换为 pdf 后保存到文件存储
+	tmpDir, err := os.MkdirTemp("", "order")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	newName := filepath.Join(tmpDir, fmt.Sprintf("%d.xlsx", order.ID))
 	if err := f.SaveAs(newName); err != nil {
 		return nil, err
 	} else {
@@ -636,7 +686,7 @@ func ExportOrder(order *models.Order) ([]byte, error) {
 		"--convert-to",
 		"pdf",
 		"--outdir",
-		"./cos/pdf/",
+		tmpDir,
 		newName,
 	)
 	err = cmd.Run()
@@ -644,12 +694,21 @@ func ExportOrder(order *models.Order) ([]byte, error) {
 		return nil, err
 	}
 
-	pdfName := fmt.Sprintf("./cos/pdf/%d.pdf", order.ID)
+	pdfName := filepath.Join(tmpDir, fmt.Sprintf("%d.pdf", order.ID))
 	pdfData, err := os.ReadFile(pdfName)
 	if err != nil {
 		return nil, err
 	}
 
+	err = putStorageFile(fmt.Sprintf("execl/%d.xlsx", order.ID), newName)
+	if err != nil {
+		return nil, err
+	}
+	err = putStorageFile(fmt.Sprintf("pdf/%d.pdf", order.ID), pdfName)
+	if err != nil {
+		return nil, err
+	}
+
 	return pdfData, nil
 }
 
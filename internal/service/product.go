@@ -9,7 +9,7 @@ import (
 	"warehouse_oa/internal/models"
 )
 
-func GetProductList(product *models.Product, pn, pSize int) (interface{}, error) {
+func GetProductList(product *models.Product, customerId, pn, pSize int) (interface{}, error) {
 	db := global.Db.Model(&models.Product{})
 
 	if product.ID != 0 {
@@ -22,7 +22,30 @@ func GetProductList(product *models.Product, pn, pSize int) (interface{}, error)
 
 	db = db.Preload("ProductContent.Finished")
 
-	return Pagination(db, []models.Product{}, pn, pSize)
+	result, err := Pagination(db, []*models.Product{}, pn, pSize)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := result["data"].([]*models.Product)
+
+	// 标准成本以及建议售价 客户毛利率只查询一次 同一成品的成本只计算一次
+	customerRate, err := getCustomerMarginRate(customerId)
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[int]float64)
+	for _, v := range data {
+		marginRate := v.MarginRate
+		if customerRate > 0 {
+			marginRate = customerRate
+		}
+		err = setProductCost(v, marginRate, costs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
 }
 
 func GetProductById(id int) (*models.Product, error) {
@@ -53,6 +76,10 @@ func GetProductByName(name string) (*models.Product, error) {
 
 // SaveProduct 创建产品
 func SaveProduct(product *models.Product) (*models.Product, error) {
+	err := checkMarginRate(product.MarginRate)
+	if err != nil {
+		return nil, err
+	}
 	total, err := IfProductByName(product.Name, product.Specification)
 	if err != nil {
 		return nil, err
@@ -89,7 +116,10 @@ func SaveProduct(product *models.Product) (*models.Product, error) {
 
 // UpdateProduct 修改产品
 func UpdateProduct(product *models.Product) (*models.Product, error) {
-	var err error
+	err := checkMarginRate(product.MarginRate)
+	if err != nil {
+		return nil, err
+	}
 
 	if product.ProductContent == nil || len(product.ProductContent) == 0 {
 		return nil, errors.New("产品列表不能为空")
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"warehouse_oa/internal/models"
+)
+
+// GetProductPrice 获取产品标准成本以及建议售价 客户有目标毛利率时优先使用客户的
+func GetProductPrice(productId, customerId int) (map[string]interface{}, error) {
+	product, err := GetProductById(productId)
+	if err != nil {
+		return nil, err
+	}
+	marginRate, err := getMarginRate(product, customerId)
+	if err != nil {
+		return nil, err
+	}
+	err = setProductCost(product, marginRate, map[int]float64{})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"productId":    product.ID,
+		"cost":         product.Cost,
+		"marginRate":   marginRate,
+		"suggestPrice": product.SuggestPrice,
+	}, nil
+}
+
+// getProductCost 产品标准成本 成品用量×成品配方成本(配料以及半成品逐级汇总)
+// costs 缓存已计算的成品成本 批量计算时同一成品只计算一次
+func getProductCost(product *models.Product, costs map[int]float64) (float64, error) {
+	var cost float64
+	for _, content := range product.ProductContent {
+		unitCost, ok := costs[content.FinishedId]
+		if !ok {
+			var err error
+			unitCost, err = getFinishedCost(content.FinishedId, map[int]bool{})
+			if err != nil {
+				return 0, err
+			}
+			costs[content.FinishedId] = unitCost
+		}
+		cost += unitCost * content.Quantity
+	}
+
+	return math.Round(cost*100) / 100, nil
+}
+
+// setProductCost 设置产品标准成本以及建议售价
+func setProductCost(product *models.Product, marginRate float64, costs map[int]float64) error {
+	cost, err := getProductCost(product, costs)
+	if err != nil {
+		return err
+	}
+	product.Cost = cost
+	product.SuggestPrice = getSuggestPrice(cost, marginRate)
+
+	return nil
+}
+
+// getMarginRate 客户目标毛利率 客户没有设置时使用产品目标毛利率
+func getMarginRate(product *models.Product, customerId int) (float64, error) {
+	customerRate, err := getCustomerMarginRate(customerId)
+	if err != nil {
+		return 0, err
+	}
+	if customerRate > 0 {
+		return customerRate, nil
+	}
+
+	return product.MarginRate, nil
+}
+
+// getCustomerMarginRate 客户目标毛利率 没有指定客户时为0
+func getCustomerMarginRate(customerId int) (float64, error) {
+	if customerId <= 0 {
+		return 0, nil
+	}
+	customer, err := GetCustomerById(customerId)
+	if err != nil {
+		return 0, err
+	}
+
+	return customer.MarginRate, nil
+}
+
+// getSuggestPrice 建议售价 = 成本 / (1 - 毛利率)
+func getSuggestPrice(cost, marginRate float64) float64 {
+	if marginRate <= 0 || marginRate >= 100 {
+		return cost
+	}
+
+	return math.Round(cost/(1-marginRate/100)*100) / 100
+}
+
+func checkMarginRate(marginRate float64) error {
+	if marginRate < 0 || marginRate >= 100 {
+		return errors.New("目标毛利率需在0到100之间")
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"math"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetDisassemblyList 产品拆解记录
+func GetDisassemblyList(productId, inventoryId, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.ProductDisassembly{})
+	if productId > 0 {
+		db = db.Where("product_id = ?", productId)
+	}
+	if inventoryId > 0 {
+		db = db.Where("inventory_id = ?", inventoryId)
+	}
+	db = db.Preload("Product").Preload("Lines.Finished").Order("add_time desc")
+
+	return Pagination(db, []models.ProductDisassembly{}, pn, pSize)
+}
+
+// DisassembleProduct 拆解指定产品库存批次 按组装时消耗的成品批次返还成品库存
+func DisassembleProduct(disassembly *models.ProductDisassembly) (*models.ProductDisassembly, error) {
+	if disassembly.Amount <= 0 {
+		return nil, errors.New("拆解数量错误")
+	}
+	inventory, err := GetProductInventoryById(disassembly.InventoryId)
+	if err != nil {
+		return nil, err
+	}
+	if inventory.Amount < disassembly.Amount {
+		return nil, errors.New("产品库存不足")
+	}
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	falseValue := false
+	err = tx.Model(&models.ProductConsume{}).Create(&models.ProductConsume{
+		BaseModel: models.BaseModel{
+			Operator: disassembly.Operator,
+		},
+		ProductId:        inventory.ProductId,
+		StockNum:         0 - float64(disassembly.Amount),
+		OperationType:    &falseValue,
+		OperationDetails: "拆解产品",
+		LocationId:       inventory.LocationId,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	inventory.Operator = disassembly.Operator
+	disassembly, err = disassembleInventory(tx, inventory, disassembly.Amount)
+
+	return disassembly, err
+}
+
+// disassembleInventory 扣除产品库存批次并返还成品 每个成品出库记录最多返还其出库数量
+func disassembleInventory(db *gorm.DB, inventory *models.ProductInventory,
+	amount int) (*models.ProductDisassembly, error) {
+
+	if inventory.Amount < amount {
+		return nil, errors.New("产品库存不足")
+	}
+	productName := ""
+	if inventory.Product != nil {
+		productName = inventory.Product.Name
+	}
+
+	disassembly := &models.ProductDisassembly{
+		BaseModel: models.BaseModel{
+			Operator: inventory.Operator,
+		},
+		InventoryId: inventory.ID,
+		ProductId:   inventory.ProductId,
+		Amount:      amount,
+		LocationId:  inventory.LocationId,
+	}
+	err := db.Model(&models.ProductDisassembly{}).Create(disassembly).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ic := range inventory.InventoryContent {
+		lots, err := getDisassemblyLots(db, inventory, ic.FinishedId)
+		if err != nil {
+			return nil, err
+		}
+		parts, err := planDisassembly(lots, ic.FinishedId, ic.Quantity*float64(amount))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts {
+			trueValue := true
+			restore := &models.FinishedConsume{
+				BaseModel: models.BaseModel{
+					Operator: inventory.Operator,
+				},
+				FinishedId:       ic.FinishedId,
+				ProductId:        inventory.ProductId,
+				InventoryId:      &inventory.ID,
+				StockNum:         part.Amount,
+				OperationType:    &trueValue,
+				OperationDetails: fmt.Sprintf("产品【%s】拆解返还", productName),
+				LocationId:       inventory.LocationId,
+			}
+			_, err = SaveFinishedConsume(db, restore)
+			if err != nil {
+				return nil, err
+			}
+
+			line := &models.ProductDisassemblyLine{
+				DisassemblyId:   disassembly.ID,
+				ConsumeId:       part.ConsumeId,
+				FinishedId:      ic.FinishedId,
+				Amount:          part.Amount,
+				ReturnConsumeId: restore.ID,
+			}
+			err = db.Model(&models.ProductDisassemblyLine{}).Create(line).Error
+			if err != nil {
+				return nil, err
+			}
+			disassembly.Lines = append(disassembly.Lines, *line)
+
+			err = restoreFinishedStock(db, ic.FinishedId, inventory.LocationId, part.Amount, inventory.Operator)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	inventory.Amount -= amount
+	err = db.Select("amount").Updates(inventory).Error
+
+	return disassembly, err
+}
+
+// disassemblyLot 可返还的成品出库记录 Consumed 为出库数量 Returned 为已拆解返还数量
+type disassemblyLot struct {
+	ConsumeId int
+	Consumed  float64
+	Returned  float64
+}
+
+// disassemblyPart 拆解返还计划 ConsumeId 为 0 表示按批次组成返还 没有对应的出库记录
+type disassemblyPart struct {
+	ConsumeId int
+	Amount    float64
+}
+
+// planDisassembly 按出库记录顺序分配返还数量 每条记录最多返还出库数量减去已返还数量
+// 没有出库记录时(调拨前的历史批次) 按批次组成返还
+func planDisassembly(lots []disassemblyLot, finishedId int, need float64) ([]disassemblyPart, error) {
+	parts := make([]disassemblyPart, 0)
+	if need <= 1e-6 {
+		return parts, nil
+	}
+	if len(lots) == 0 {
+		return append(parts, disassemblyPart{Amount: need}), nil
+	}
+
+	for _, lot := range lots {
+		if need <= 1e-6 {
+			break
+		}
+		available := lot.Consumed - lot.Returned
+		if available <= 1e-6 {
+			continue
+		}
+		num := math.Min(available, need)
+		parts = append(parts, disassemblyPart{ConsumeId: lot.ConsumeId, Amount: num})
+		need -= num
+	}
+	if need > 1e-6 {
+		return nil, errors.New(fmt.Sprintf("id: %d 成品可返还数量不足", finishedId))
+	}
+
+	return parts, nil
+}
+
+// getDisassemblyLots 产品批次组装时的成品出库记录以及已返还数量
+// 调拨生成的批次使用最初组装的批次(SourceId)的出库记录
+func getDisassemblyLots(db *gorm.DB, inventory *models.ProductInventory,
+	finishedId int) ([]disassemblyLot, error) {
+
+	sourceId := inventory.ID
+	if inventory.SourceId != nil {
+		sourceId = *inventory.SourceId
+	}
+
+	consumeList := make([]models.FinishedConsume, 0)
+	err := db.Model(&models.FinishedConsume{}).
+		Where("inventory_id = ? and finished_id = ? and stock_num < 0", sourceId, finishedId).
+		Order("add_time asc, id asc").Find(&consumeList).Error
+	if err != nil || len(consumeList) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int, 0)
+	for _, c := range consumeList {
+		ids = append(ids, c.ID)
+	}
+	var returnedList []struct {
+		ConsumeId int
+		Amount    float64
+	}
+	err = db.Model(&models.ProductDisassemblyLine{}).
+		Select("consume_id, SUM(amount) as amount").
+		Where("consume_id in ?", ids).Group("consume_id").Scan(&returnedList).Error
+	if err != nil {
+		return nil, err
+	}
+	returnedMap := make(map[int]float64)
+	for _, r := range returnedList {
+		returnedMap[r.ConsumeId] = r.Amount
+	}
+
+	lots := make([]disassemblyLot, 0)
+	for _, c := range consumeList {
+		lots = append(lots, disassemblyLot{
+			ConsumeId: c.ID,
+			Consumed:  -c.StockNum,
+			Returned:  returnedMap[c.ID],
+		})
+	}
+
+	return lots, nil
+}
+
+// restoreFinishedStock 返还成品库存到指定库位
+func restoreFinishedStock(db *gorm.DB, finishedId, locationId int, amount float64, operator string) error {
+	stock := new(models.FinishedStock)
+	err := db.Model(&models.FinishedStock{}).
+		Where("finished_id = ?", finishedId).
+		Where("location_id = ?", locationId).
+		Find(&stock).Error
+	if err != nil {
+		return err
+	}
+	if stock.ID != 0 {
+		stock.Amount += amount
+		return db.Select("amount").Updates(&stock).Error
+	}
+
+	_, err = SaveFinishedStock(db, &models.FinishedStock{
+		BaseModel: models.BaseModel{
+			Operator: operator,
+		},
+		FinishedId: finishedId,
+		Amount:     amount,
+		LocationId: locationId,
+	})
+
+	return err
+}
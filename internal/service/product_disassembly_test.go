@@ -0,0 +1,86 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlanDisassembly(t *testing.T) {
+	tests := []struct {
+		name    string
+		lots    []disassemblyLot
+		need    float64
+		want    []disassemblyPart
+		wantErr bool
+	}{
+		{
+			name: "多个批次按顺序返还",
+			lots: []disassemblyLot{
+				{ConsumeId: 1, Consumed: 3},
+				{ConsumeId: 2, Consumed: 5},
+			},
+			need: 6,
+			want: []disassemblyPart{{ConsumeId: 1, Amount: 3}, {ConsumeId: 2, Amount: 3}},
+		},
+		{
+			name: "部分返还",
+			lots: []disassemblyLot{{ConsumeId: 1, Consumed: 10}},
+			need: 4,
+			want: []disassemblyPart{{ConsumeId: 1, Amount: 4}},
+		},
+		{
+			name: "重复返还跳过已返还完的批次",
+			lots: []disassemblyLot{
+				{ConsumeId: 1, Consumed: 3, Returned: 3},
+				{ConsumeId: 2, Consumed: 5, Returned: 2},
+			},
+			need: 3,
+			want: []disassemblyPart{{ConsumeId: 2, Amount: 3}},
+		},
+		{
+			name: "重复返还超过出库数量",
+			lots: []disassemblyLot{
+				{ConsumeId: 1, Consumed: 3, Returned: 2},
+				{ConsumeId: 2, Consumed: 5, Returned: 5},
+			},
+			need:    2,
+			wantErr: true,
+		},
+		{
+			name: "没有出库记录按批次组成返还",
+			lots: nil,
+			need: 2.5,
+			want: []disassemblyPart{{ConsumeId: 0, Amount: 2.5}},
+		},
+		{
+			name: "返还数量为0",
+			lots: []disassemblyLot{{ConsumeId: 1, Consumed: 3}},
+			need: 0,
+			want: []disassemblyPart{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planDisassembly(tt.lots, 1, tt.need)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("应返回错误 实际 %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("返还明细 %+v 期望 %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].ConsumeId != tt.want[i].ConsumeId ||
+					math.Abs(got[i].Amount-tt.want[i].Amount) > 1e-6 {
+					t.Errorf("第 %d 条 %+v 期望 %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"sort"
 	"strings"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
@@ -22,6 +23,9 @@ func GetProductInventoryList(inventory *models.ProductInventory, pn, pSize int)
 		slice := strings.Split(inventory.ProductIdList, ";")
 		db = db.Where("product_id in ?", slice)
 	}
+	if inventory.LocationId > 0 {
+		db = db.Where("location_id = ?", inventory.LocationId)
+	}
 
 	var total int64
 	if err := db.Count(&total).Error; err != nil {
@@ -46,7 +50,7 @@ func GetProductInventoryList(inventory *models.ProductInventory, pn, pSize int)
 
 // GetProductConsumeOutList 获取产品出入库列表
 func GetProductConsumeOutList(ids, stockUnit, begTime, endTime string,
-	inOrOut, pn, pSize int) (interface{}, error) {
+	inOrOut, pn, pSize, locationId int) (interface{}, error) {
 	db := global.Db.Model(&models.ProductConsume{})
 	totalDb := global.Db.Model(&models.ProductConsume{})
 	db.Preload("Product")
@@ -68,6 +72,10 @@ func GetProductConsumeOutList(ids, stockUnit, begTime, endTime string,
 		db = db.Where("stock_num < 0")
 		totalDb = totalDb.Where("stock_num < 0")
 	}
+	if locationId > 0 {
+		db = db.Where("location_id = ?", locationId)
+		totalDb = totalDb.Where("location_id = ?", locationId)
+	}
 	var total int64
 	if err := totalDb.Count(&total).Error; err != nil {
 		return nil, err
@@ -91,7 +99,7 @@ func GetProductConsumeOutList(ids, stockUnit, begTime, endTime string,
 
 // GetInventorySum 获取产品出入库列表
 func GetInventorySum(ids, stockUnit, begTime, endTime string,
-	inOrOut int) (interface{}, error) {
+	inOrOut, locationId int) (interface{}, error) {
 
 	enterDb := global.Db.Model(&models.ProductConsume{})
 	outDb := global.Db.Model(&models.ProductConsume{})
@@ -105,6 +113,10 @@ func GetInventorySum(ids, stockUnit, begTime, endTime string,
 		enterDb = enterDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 		outDb = outDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 	}
+	if locationId > 0 {
+		enterDb = enterDb.Where("location_id = ?", locationId)
+		outDb = outDb.Where("location_id = ?", locationId)
+	}
 
 	var enterNum, outNum float64
 	err := enterDb.Where("stock_num >= 0").Select("IFNULL(SUM(stock_num), 0) AS stock_num").First(&enterNum).Error
@@ -186,6 +198,10 @@ func SaveProductInventory(data *models.ProductInventory) error {
 	if err != nil {
 		return err
 	}
+	err = CheckLocation(data.LocationId)
+	if err != nil {
+		return err
+	}
 
 	db := global.Db
 	tx := db.Begin()
@@ -213,9 +229,17 @@ func SaveProductInventory(data *models.ProductInventory) error {
 
 	// 消耗成品
 	for _, u := range product.ProductContent {
-		err = DeductFinishedStockByProduct(tx, product, &models.FinishedStock{
+		err = DeductFinishedStockByConsume(tx, models.FinishedConsume{
+			BaseModel: models.BaseModel{
+				Operator: product.Operator,
+			},
+			ProductId:        product.ID,
+			InventoryId:      &data.ID,
+			OperationDetails: fmt.Sprintf("产品【%s】使用", product.Name),
+		}, &models.FinishedStock{
 			FinishedId: u.FinishedId,
 			Amount:     u.Quantity * float64(data.Amount),
+			LocationId: data.LocationId,
 		})
 		if err != nil {
 			return err
@@ -232,6 +256,7 @@ func SaveProductInventory(data *models.ProductInventory) error {
 		StockNum:         float64(data.Amount),
 		OperationType:    &trueValue,
 		OperationDetails: "添加产品",
+		LocationId:       data.LocationId,
 	}).Error
 
 	return err
@@ -254,33 +279,22 @@ func UpdateProductInventory(inventory *models.ProductInventory) error {
 		}
 	}()
 
-	falseValue := false
-	err = tx.Model(&models.ProductConsume{}).Create(&models.ProductConsume{
-		BaseModel: models.BaseModel{
-			Operator: inventory.Operator,
-		},
-		OrderId:          nil,
-		ProductId:        inventory.ProductId,
-		StockNum:         0 - float64(inventory.Amount),
-		OperationType:    &falseValue,
-		OperationDetails: "扣除产品",
-	}).Error
-	if err != nil {
-		return err
-	}
-
 	amount := inventory.Amount
+	drawn := make(map[int]int)
 	for {
 		if amount <= 0 {
 			break
 		}
 
 		var data *models.ProductInventory
-		err = tx.Model(&models.ProductInventory{}).
+		inventoryDb := tx.Model(&models.ProductInventory{}).
 			Preload("Product").
 			Preload("InventoryContent").
-			Where("product_id = ? and amount > 0", inventory.ProductId).
-			Order("add_time asc").First(&data).Error
+			Where("product_id = ? and amount > 0", inventory.ProductId)
+		if inventory.LocationId > 0 {
+			inventoryDb = inventoryDb.Where("location_id = ?", inventory.LocationId)
+		}
+		err = inventoryDb.Order("add_time asc").First(&data).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("库存不足")
 		}
@@ -288,30 +302,29 @@ func UpdateProductInventory(inventory *models.ProductInventory) error {
 			return err
 		}
 
+		num := amount
+		if data.Amount < num {
+			num = data.Amount
+		}
 		data.Operator = inventory.Operator
-		err = ReturningInventory(tx, data, amount)
+		_, err = disassembleInventory(tx, data, num)
 		if err != nil {
 			return err
 		}
-
-		if data.Amount > amount {
-			data.Amount -= amount
-			amount = 0
-			err = tx.Select("amount").Updates(&data).Error
-			if err != nil {
-				return err
-			}
-		} else {
-			// 删除库存
-			amount -= data.Amount
-			data.Amount = 0
-			err = tx.Select("amount").Updates(&data).Error
-			if err != nil {
-				return err
-			}
-		}
+		drawn[data.LocationId] += num
+		amount -= num
 	}
 
+	falseValue := false
+	err = saveProductConsumeByLocation(tx, models.ProductConsume{
+		BaseModel: models.BaseModel{
+			Operator: inventory.Operator,
+		},
+		ProductId:        inventory.ProductId,
+		OperationType:    &falseValue,
+		OperationDetails: "扣除产品",
+	}, drawn)
+
 	return err
 }
 
@@ -337,17 +350,18 @@ func CheckProductInventoryExists(productId int) (bool, *models.ProductInventory,
 	return count > 0, data, nil
 }
 
-// DeductProductStock 扣除产品库存
-func DeductProductStock(db *gorm.DB, productId, amount int) (int, error) {
+// DeductProductStock 扣除产品库存 返回各库位实际扣除的数量以及不足的数量
+func DeductProductStock(db *gorm.DB, productId, amount, locationId int) (map[int]int, int, error) {
+	drawn := make(map[int]int)
 	// 根据订单产品名查询产品
 	product := &models.Product{}
 	err := db.Model(&models.Product{}).Where(
 		"id = ?", productId).First(&product).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return amount, nil
+		return drawn, amount, nil
 	}
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
 
 	logrus.Infoln("11111111111111111111111111111111111")
@@ -356,28 +370,52 @@ func DeductProductStock(db *gorm.DB, productId, amount int) (int, error) {
 	// 根据产品ID查询产品库存
 	for amount > 0 {
 		inventory := &models.ProductInventory{}
-		err = db.Model(&models.ProductInventory{}).Where("product_id = ?", product.ID).First(&inventory).Error
+		inventoryDb := db.Model(&models.ProductInventory{}).
+			Where("product_id = ? and amount > 0", product.ID)
+		if locationId > 0 {
+			inventoryDb = inventoryDb.Where("location_id = ?", locationId)
+		}
+		err = inventoryDb.Order("add_time asc").First(&inventory).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return amount, nil
+			return drawn, amount, nil
 		}
 		if err != nil {
-			return 0, err
+			return nil, 0, err
 		}
 
 		// 扣除库存
+		num := amount
+		if inventory.Amount < num {
+			num = inventory.Amount
+		}
+		inventory.Amount -= num
+		err = db.Select("amount").Updates(&inventory).Error
+		if err != nil {
+			return nil, 0, err
+		}
+		drawn[inventory.LocationId] += num
+		amount -= num
+	}
+	return drawn, amount, nil
+}
 
-		if inventory.Amount-amount > 0 {
-			inventory.Amount -= amount
-			err = db.Select("amount").Updates(&inventory).Error
-			if err != nil {
-				return 0, err
-			}
-			amount = 0
-		} else {
-			amount -= inventory.Amount
-			inventory.Amount = 0
-			err = db.Select("amount").Updates(&inventory).Error
+// saveProductConsumeByLocation 按实际扣除的库位写入产品出库记录 每个库位一条
+func saveProductConsumeByLocation(db *gorm.DB, consume models.ProductConsume, drawn map[int]int) error {
+	locationIds := make([]int, 0, len(drawn))
+	for id := range drawn {
+		locationIds = append(locationIds, id)
+	}
+	sort.Ints(locationIds)
+
+	for _, id := range locationIds {
+		record := consume
+		record.StockNum = 0 - float64(drawn[id])
+		record.LocationId = id
+		err := db.Model(&models.ProductConsume{}).Create(&record).Error
+		if err != nil {
+			return err
 		}
 	}
-	return amount, nil
+
+	return nil
 }
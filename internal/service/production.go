@@ -2,8 +2,10 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"math"
 	"time"
 	"warehouse_oa/internal/global"
 	"warehouse_oa/internal/models"
@@ -15,6 +17,7 @@ func GetProductionList(production *models.FinishedProduction,
 
 	db := global.Db.Model(&models.FinishedProduction{})
 	db.Preload("Finished")
+	db = db.Preload("UserList")
 
 	if production.FinishedId > 0 {
 		db = db.Where("finished_id = ?", production.FinishedId)
@@ -66,7 +69,7 @@ func GetProductionList(production *models.FinishedProduction,
 	}, err
 }
 
-func GetFinishedSum(id, status int, begTime, endTime string) (interface{}, error) {
+func GetFinishedSum(id, status, locationId int, begTime, endTime string) (interface{}, error) {
 	enterDb := global.Db.Model(&models.FinishedConsume{})
 	outDb := global.Db.Model(&models.FinishedConsume{})
 	enterDb = enterDb.Where("finished_id = ?", id)
@@ -76,6 +79,10 @@ func GetFinishedSum(id, status int, begTime, endTime string) (interface{}, error
 		enterDb = enterDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 		outDb = outDb.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
 	}
+	if locationId > 0 {
+		enterDb = enterDb.Where("location_id = ?", locationId)
+		outDb = outDb.Where("location_id = ?", locationId)
+	}
 
 	var enterNum, outNum float64
 	err := enterDb.Where("stock_num >= 0").Select("IFNULL(SUM(stock_num), 0) AS stock_num").First(&enterNum).Error
@@ -125,6 +132,9 @@ func GetFinishedConsumeList(production *models.FinishedProduction,
 	if inOrOut == 2 {
 		db = db.Where("stock_num < 0")
 	}
+	if production.LocationId > 0 {
+		db = db.Where("location_id = ?", production.LocationId)
+	}
 
 	return Pagination(db, []models.FinishedConsume{}, pn, pSize)
 }
@@ -177,9 +187,25 @@ func SaveProduction(production *models.FinishedProduction) (*models.FinishedProd
 	production.Finished = finished
 	production.Status = 1
 	production.FinishTime = nil
+
+	// 记录报工使用的配方版本
+	bom, err := GetEffectiveBom(finished.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	production.BomId = bom.ID
+	if production.PlanStartTime != nil || production.PlanEndTime != nil {
+		err = checkProductionPlan(production)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if production.FinishHour > 0 {
 		et := time.Now().Add(time.Duration(production.FinishHour) * time.Hour)
 		production.EstimatedTime = &et
+	} else if production.PlanEndTime != nil && production.PlanEndTime.After(time.Now()) {
+		// 按计划结束时间作为预计完成时间
+		production.EstimatedTime = production.PlanEndTime
 	} else {
 		// 4=已超时
 		production.Status = 4
@@ -203,6 +229,13 @@ func SaveProduction(production *models.FinishedProduction) (*models.FinishedProd
 		return nil, err
 	}
 
+	// 超负荷只提示不影响报工
+	warnings, e := getScheduleWarnings(production)
+	if e != nil {
+		logrus.Infoln("排产检查错误: ", e.Error())
+	}
+	production.Warnings = warnings
+
 	return production, err
 }
 
@@ -227,6 +260,9 @@ func VoidProduction(id int, username string) error {
 	if production.Status == 2 || production.Status == 3 {
 		return errors.New("已完工或以作废，无法修改")
 	}
+	if production.ActualAmount > 0 {
+		return errors.New("报工已有完工数量，请结案")
+	}
 
 	db := global.Db
 	tx := db.Begin()
@@ -270,21 +306,78 @@ func VoidProduction(id int, username string) error {
 	return tx.Updates(&production).Error
 }
 
-// FinishProduction 完成报工
-func FinishProduction(id, amount int, username string) error {
-	if id == 0 {
-		return errors.New("id is 0")
+// FinishProduction 完成报工 登记最后一次完工数量并结案
+func FinishProduction(id, amount, locationId int, usage []models.ProductionUsage, username string) error {
+	production, err := getOpenProduction(id)
+	if err != nil {
+		return err
+	}
+	err = CheckLocation(locationId)
+	if err != nil {
+		return err
 	}
 
-	production, err := GetProductionById(id)
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	production.Operator = username
+	if amount > 0 {
+		err = completeProduction(tx, production, amount, locationId, usage)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = closeProduction(tx, production)
+
+	return err
+}
+
+// CompleteProduction 报工部分完工 每次完工增加成品库存 报工保持生产中
+func CompleteProduction(id, amount, locationId int, usage []models.ProductionUsage, username string) error {
+	if amount <= 0 {
+		return errors.New("完工数量错误")
+	}
+	production, err := getOpenProduction(id)
 	if err != nil {
 		return err
 	}
-	if production == nil {
-		return errors.New("报工单不存在")
+	err = CheckLocation(locationId)
+	if err != nil {
+		return err
 	}
-	if production.Status == 2 || production.Status == 3 {
-		return errors.New("已完工或以作废，无法修改")
+
+	db := global.Db
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	production.Operator = username
+	err = completeProduction(tx, production, amount, locationId, usage)
+
+	return err
+}
+
+// CloseProduction 报工结案 计算良品率以及配料差异
+func CloseProduction(id int, username string) error {
+	production, err := getOpenProduction(id)
+	if err != nil {
+		return err
+	}
+	if production.ActualAmount == 0 {
+		return errors.New("报工没有完工数量，请作废报工")
 	}
 
 	db := global.Db
@@ -298,25 +391,206 @@ func FinishProduction(id, amount int, username string) error {
 	}()
 
 	production.Operator = username
+	err = closeProduction(tx, production)
+
+	return err
+}
+
+// GetCompletionList 报工完工记录
+func GetCompletionList(productionId int) ([]models.FinishedCompletion, error) {
+	data := make([]models.FinishedCompletion, 0)
+	err := global.Db.Model(&models.FinishedCompletion{}).
+		Where("production_id = ?", productionId).
+		Order("complete_time").Find(&data).Error
+
+	return data, err
+}
+
+// GetProductionVariance 报工配料差异
+func GetProductionVariance(productionId int) ([]models.ProductionVariance, error) {
+	data := make([]models.ProductionVariance, 0)
+	err := global.Db.Model(&models.ProductionVariance{}).
+		Where("production_id = ?", productionId).
+		Preload("Ingredient").Find(&data).Error
+
+	return data, err
+}
+
+// InitProductionVariance 启动时为没有差异记录的历史已完工报工补充计算配料差异
+func InitProductionVariance() error {
+	productionList := make([]*models.FinishedProduction, 0)
+	err := global.Db.Model(&models.FinishedProduction{}).
+		Where("status = ?", 2).
+		Where("id not in (?)", global.Db.Model(&models.ProductionVariance{}).Select("production_id")).
+		Find(&productionList).Error
+	if err != nil {
+		return err
+	}
+	// 配方已不存在等无法计算的报工跳过 不影响启动
+	for _, production := range productionList {
+		err = saveProductionVariance(global.Db, production)
+		if err != nil {
+			logrus.Warnf("报工【%d】配料差异计算失败: %s", production.ID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func getOpenProduction(id int) (*models.FinishedProduction, error) {
+	if id == 0 {
+		return nil, errors.New("id is 0")
+	}
+	production, err := GetProductionById(id)
+	if err != nil {
+		return nil, err
+	}
+	if production == nil {
+		return nil, errors.New("报工单不存在")
+	}
+	if production.Status == 2 || production.Status == 3 {
+		return nil, errors.New("已完工或以作废，无法修改")
+	}
+
+	return production, nil
+}
+
+// completeProduction 登记一次完工 消耗配料和半成品 增加成品库存和出入库记录
+func completeProduction(db *gorm.DB, production *models.FinishedProduction,
+	amount, locationId int, usage []models.ProductionUsage) error {
+	err := db.Model(&models.FinishedCompletion{}).Create(&models.FinishedCompletion{
+		BaseModel: models.BaseModel{
+			Operator: production.Operator,
+		},
+		ProductionId: production.ID,
+		Amount:       amount,
+		LocationId:   locationId,
+		CompleteTime: time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// 本次完工数量
+	drop := *production
+	drop.ActualAmount = amount
+	drop.LocationId = locationId
+
+	// 消耗配料库存
+	err = deductIngredient(db, &drop, usage)
+	if err != nil {
+		return err
+	}
+
+	// 消耗半成品库存
+	err = deductSubMaterial(db, &drop)
+	if err != nil {
+		return err
+	}
+
+	// 添加成品库存
+	err = SaveStockByProduction(db, &drop)
+	if err != nil {
+		return err
+	}
+
+	// 添加成品出入库信息
+	err = SaveConsumeByProduction(db, &drop)
+	if err != nil {
+		return err
+	}
+
+	production.ActualAmount += amount
+	production.LocationId = locationId
+
+	return db.Select("ActualAmount", "LocationId", "Operator").Updates(production).Error
+}
+
+// closeProduction 结案
+func closeProduction(db *gorm.DB, production *models.FinishedProduction) error {
 	production.Status = 2
-	production.ActualAmount = amount
-	production.Ratio = (float64(production.ActualAmount) / float64(production.ExpectAmount)) * float64(100)
+	if production.ExpectAmount > 0 {
+		production.Ratio = (float64(production.ActualAmount) / float64(production.ExpectAmount)) * float64(100)
+	}
 	ft := time.Now()
 	production.FinishTime = &ft
 
-	// 添加成品库存
-	err = SaveStockByProduction(tx, production)
+	err := saveProductionVariance(db, production)
 	if err != nil {
 		return err
 	}
 
-	// 添加成品出入库信息
-	err = SaveConsumeByProduction(tx, production)
+	return db.Select("Status", "Ratio", "FinishTime", "Operator").Updates(production).Error
+}
+
+// saveProductionVariance 按配方标准用量与实际消耗计算配料差异
+func saveProductionVariance(db *gorm.DB, production *models.FinishedProduction) error {
+	bom, err := getProductionBom(production)
 	if err != nil {
 		return err
 	}
 
-	return tx.Updates(&production).Error
+	varianceMap := make(map[string]*models.ProductionVariance)
+	varianceList := make([]*models.ProductionVariance, 0)
+	getVariance := func(ingredientId, stockUnit int) *models.ProductionVariance {
+		key := fmt.Sprintf("%d_%d", ingredientId, stockUnit)
+		v, ok := varianceMap[key]
+		if !ok {
+			v = &models.ProductionVariance{
+				BaseModel: models.BaseModel{
+					Operator: production.Operator,
+				},
+				ProductionId: production.ID,
+				IngredientId: ingredientId,
+				StockUnit:    stockUnit,
+			}
+			varianceMap[key] = v
+			varianceList = append(varianceList, v)
+		}
+		return v
+	}
+
+	for _, m := range bom.Material {
+		v := getVariance(m.IngredientId, m.StockUnit)
+		v.StandardNum += m.Quantity * float64(production.ActualAmount)
+	}
+
+	var consumeList []struct {
+		IngredientId int
+		StockUnit    int
+		StockNum     float64
+	}
+	err = db.Model(&models.IngredientConsume{}).
+		Select("ingredient_id, stock_unit, SUM(stock_num) as stock_num").
+		Where("production_id = ?", production.ID).
+		Group("ingredient_id, stock_unit").Scan(&consumeList).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range consumeList {
+		v := getVariance(c.IngredientId, c.StockUnit)
+		v.ActualNum += -c.StockNum
+	}
+
+	err = db.Where("production_id = ?", production.ID).Delete(&models.ProductionVariance{}).Error
+	if err != nil {
+		return err
+	}
+	for _, v := range varianceList {
+		v.VarianceNum = v.ActualNum - v.StandardNum
+		v.UnitPrice, err = getLatestUnitPrice(v.IngredientId, v.StockUnit)
+		if err != nil {
+			return err
+		}
+		v.VarianceCost = v.VarianceNum * v.UnitPrice
+
+		err = db.Model(&models.ProductionVariance{}).Create(v).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func GetProductionByFinishedId(finishedId int) ([]models.FinishedProduction, int64, error) {
@@ -337,3 +611,116 @@ func GetProductionByFinishedId(finishedId int) ([]models.FinishedProduction, int
 
 	return data, total, err
 }
+
+// deductSubMaterial 按报工使用的配方版本扣除半成品库存
+func deductSubMaterial(db *gorm.DB, production *models.FinishedProduction) error {
+	var bom *models.FinishedBom
+	var err error
+	if production.BomId > 0 {
+		bom, err = GetBomById(production.BomId)
+	} else {
+		bom, err = GetEffectiveBom(production.FinishedId, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range bom.SubMaterial {
+		err = DeductFinishedStockByConsume(db, models.FinishedConsume{
+			BaseModel: models.BaseModel{
+				Operator: production.Operator,
+			},
+			ProductionId:     &production.ID,
+			OperationDetails: fmt.Sprintf("报工【%d】生产使用", production.ID),
+		}, &models.FinishedStock{
+			FinishedId: sub.SubFinishedId,
+			Amount:     sub.Quantity * float64(production.ActualAmount),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getProductionBom 报工使用的配方版本 历史报工没有记录时按当前生效版本
+func getProductionBom(production *models.FinishedProduction) (*models.FinishedBom, error) {
+	if production.BomId > 0 {
+		return GetBomById(production.BomId)
+	}
+
+	return GetEffectiveBom(production.FinishedId, time.Now())
+}
+
+// deductIngredient 扣除本次完工的配料库存 并写入关联报工的配料出库记录
+// 没有登记实际用量时按配方标准用量扣除 优先扣除完工库位的库存
+func deductIngredient(db *gorm.DB, production *models.FinishedProduction, usage []models.ProductionUsage) error {
+	if len(usage) == 0 {
+		bom, err := getProductionBom(production)
+		if err != nil {
+			return err
+		}
+		for _, m := range bom.Material {
+			usage = append(usage, models.ProductionUsage{
+				IngredientId: m.IngredientId,
+				StockUnit:    m.StockUnit,
+				StockNum:     m.Quantity * float64(production.ActualAmount),
+			})
+		}
+	}
+
+	falseValue := false
+	for _, u := range usage {
+		if u.StockNum < 0 {
+			return errors.New("配料用量错误")
+		}
+		ingredient, err := GetIngredientsById(u.IngredientId)
+		if err != nil {
+			return err
+		}
+
+		amount := u.StockNum
+		for amount > 0 {
+			stock := &models.IngredientStock{}
+			err = db.Model(&models.IngredientStock{}).
+				Where("ingredient_id = ? and stock_unit = ? and stock_num > 0", u.IngredientId, u.StockUnit).
+				Order(fmt.Sprintf("location_id = %d desc, add_time asc", production.LocationId)).
+				First(&stock).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("配料【%s】库存不足", ingredient.Name)
+			}
+			if err != nil {
+				return err
+			}
+
+			num := math.Min(stock.StockNum, amount)
+			stock.StockNum -= num
+			amount -= num
+			err = db.Select("stock_num").Updates(&stock).Error
+			if err != nil {
+				return err
+			}
+
+			_, err = SaveConsume(db, &models.IngredientConsume{
+				BaseModel: models.BaseModel{
+					Operator: production.Operator,
+				},
+				FinishedId:       &production.FinishedId,
+				IngredientId:     stock.IngredientId,
+				ProductionId:     &production.ID,
+				StockNum:         0 - num,
+				StockUnit:        u.StockUnit,
+				OperationType:    &falseValue,
+				OperationDetails: fmt.Sprintf("报工【%d】生产使用", production.ID),
+				IsPackage:        stock.IsPackage,
+				LocationId:       stock.LocationId,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
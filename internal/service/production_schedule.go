@@ -0,0 +1,240 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// ScheduleProduction 排产 设置计划时间和生产人员
+func ScheduleProduction(production *models.FinishedProduction) (*models.FinishedProduction, error) {
+	if production.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	data, err := GetProductionById(production.ID)
+	if err != nil {
+		return nil, err
+	}
+	if data.Status != 1 && data.Status != 4 {
+		return nil, errors.New("已完工或以作废，无法排产")
+	}
+
+	data.PlanStartTime = production.PlanStartTime
+	data.PlanEndTime = production.PlanEndTime
+	data.UserList = production.UserList
+	data.Operator = production.Operator
+	err = checkProductionPlan(data)
+	if err != nil {
+		return nil, err
+	}
+	data.EstimatedTime = data.PlanEndTime
+	if data.PlanEndTime.After(time.Now()) {
+		data.Status = 1
+	} else {
+		data.Status = 4
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	err = tx.Select("PlanStartTime", "PlanEndTime", "EstimatedTime",
+		"Status", "Operator").Updates(data).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(data).Association("UserList").Replace(data.UserList)
+	if err != nil {
+		return nil, err
+	}
+
+	// 超负荷只提示不影响排产
+	warnings, e := getScheduleWarnings(data)
+	if e != nil {
+		logrus.Infoln("排产检查错误: ", e.Error())
+	}
+	data.Warnings = warnings
+
+	return data, nil
+}
+
+// GetProductionCalendar 排产日历 按天返回计划中的报工以及成品负荷
+func GetProductionCalendar(begTime, endTime string, finishedId, userId int) ([]*models.ProductionCalendarDay, error) {
+	beg, err := time.ParseInLocation("2006-01-02", begTime, time.Local)
+	if err != nil {
+		return nil, errors.New("开始日期错误")
+	}
+	end, err := time.ParseInLocation("2006-01-02", endTime, time.Local)
+	if err != nil {
+		return nil, errors.New("结束日期错误")
+	}
+	if end.Before(beg) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+	if end.Sub(beg) > 92*24*time.Hour {
+		return nil, errors.New("查询范围不能超过三个月")
+	}
+
+	db := global.Db.Model(&models.FinishedProduction{})
+	db = db.Where("status != ?", 3)
+	db = db.Where("plan_start_time < ? and plan_end_time >= ?", end.AddDate(0, 0, 1), beg)
+	if finishedId > 0 {
+		db = db.Where("finished_id = ?", finishedId)
+	}
+	if userId > 0 {
+		db = db.Where("id in (select finished_production_id from tb_production_user where user_id = ?)", userId)
+	}
+
+	productionList := make([]*models.FinishedProduction, 0)
+	err = db.Preload("Finished").Preload("UserList").Order("plan_start_time").
+		Find(&productionList).Error
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]*models.ProductionCalendarDay, 0)
+	for day := beg; !day.After(end); day = day.AddDate(0, 0, 1) {
+		calendarDay := &models.ProductionCalendarDay{
+			Date:        day.Format("2006-01-02"),
+			Productions: make([]*models.FinishedProduction, 0),
+			Load:        make([]*models.FinishedLoad, 0),
+		}
+		loadMap := make(map[int]*models.FinishedLoad)
+		for _, p := range productionList {
+			planned := getPlannedByDay(p, day)
+			if planned < 0 {
+				continue
+			}
+			calendarDay.Productions = append(calendarDay.Productions, p)
+
+			load, ok := loadMap[p.FinishedId]
+			if !ok {
+				load = &models.FinishedLoad{FinishedId: p.FinishedId}
+				if p.Finished != nil {
+					load.FinishedName = p.Finished.Name
+					load.DailyCapacity = p.Finished.DailyCapacity
+				}
+				loadMap[p.FinishedId] = load
+				calendarDay.Load = append(calendarDay.Load, load)
+			}
+			load.Planned += planned
+			load.Overbooked = load.DailyCapacity > 0 && load.Planned > float64(load.DailyCapacity)
+		}
+		data = append(data, calendarDay)
+	}
+
+	return data, nil
+}
+
+// checkProductionPlan 校验计划时间和生产人员
+func checkProductionPlan(production *models.FinishedProduction) error {
+	if production.PlanStartTime == nil || production.PlanEndTime == nil {
+		return errors.New("计划开始时间和结束时间不能为空")
+	}
+	if production.PlanEndTime.Before(*production.PlanStartTime) {
+		return errors.New("计划结束时间不能早于开始时间")
+	}
+	for _, v := range production.UserList {
+		_, err := GetUserById(v.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getScheduleWarnings 检查成品日产能以及生产人员时间冲突 只提示不拦截
+func getScheduleWarnings(production *models.FinishedProduction) ([]string, error) {
+	warnings := make([]string, 0)
+	if production.PlanStartTime == nil || production.PlanEndTime == nil {
+		return warnings, nil
+	}
+
+	finished, err := GetFinishedById(production.FinishedId)
+	if err != nil {
+		return nil, err
+	}
+
+	if finished.DailyCapacity > 0 {
+		otherList := make([]*models.FinishedProduction, 0)
+		err = global.Db.Model(&models.FinishedProduction{}).
+			Where("finished_id = ? and id != ? and status in ?",
+				production.FinishedId, production.ID, []int{1, 4}).
+			Where("plan_start_time <= ? and plan_end_time >= ?",
+				production.PlanEndTime, production.PlanStartTime).
+			Find(&otherList).Error
+		if err != nil {
+			return nil, err
+		}
+		otherList = append(otherList, production)
+
+		start := truncateDay(*production.PlanStartTime)
+		for day := start; !day.After(*production.PlanEndTime); day = day.AddDate(0, 0, 1) {
+			var planned float64
+			for _, p := range otherList {
+				if v := getPlannedByDay(p, day); v > 0 {
+					planned += v
+				}
+			}
+			if planned > float64(finished.DailyCapacity) {
+				warnings = append(warnings, fmt.Sprintf("%s 成品【%s】计划产量%.0f超过每日产能%d",
+					day.Format("2006-01-02"), finished.Name, planned, finished.DailyCapacity))
+			}
+		}
+	}
+
+	for _, u := range production.UserList {
+		user, err := GetUserById(u.ID)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int
+		err = global.Db.Model(&models.FinishedProduction{}).
+			Where("id != ? and status in ?", production.ID, []int{1, 4}).
+			Where("plan_start_time <= ? and plan_end_time >= ?",
+				production.PlanEndTime, production.PlanStartTime).
+			Where("id in (select finished_production_id from tb_production_user where user_id = ?)", u.ID).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			sort.Ints(ids)
+			warnings = append(warnings, fmt.Sprintf("生产人员【%s】在计划时间内已安排报工单%v",
+				user.Nickname, ids))
+		}
+	}
+
+	return warnings, nil
+}
+
+// getPlannedByDay 报工在某天的计划产量 按计划天数平均分摊 不在计划内返回-1
+func getPlannedByDay(production *models.FinishedProduction, day time.Time) float64 {
+	if production.PlanStartTime == nil || production.PlanEndTime == nil {
+		return -1
+	}
+	start := truncateDay(*production.PlanStartTime)
+	end := truncateDay(*production.PlanEndTime)
+	day = truncateDay(day)
+	if day.Before(start) || day.After(end) {
+		return -1
+	}
+
+	days := int(end.Sub(start).Hours()/24) + 1
+
+	return float64(production.ExpectAmount) / float64(days)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
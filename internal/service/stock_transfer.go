@@ -0,0 +1,1068 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"time"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/utils"
+)
+
+// GetTransferList 调拨单列表
+func GetTransferList(transfer *models.StockTransfer, begTime, endTime string, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.StockTransfer{})
+
+	if transfer.TransferNumber != "" {
+		db = db.Where("transfer_number = ?", transfer.TransferNumber)
+	}
+	if transfer.Status > 0 {
+		db = db.Where("status = ?", transfer.Status)
+	}
+	if transfer.FromLocationId > 0 {
+		db = db.Where("from_location_id = ?", transfer.FromLocationId)
+	}
+	if transfer.ToLocationId > 0 {
+		db = db.Where("to_location_id = ?", transfer.ToLocationId)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(add_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+	db = db.Preload("FromLocation.Warehouse")
+	db = db.Preload("ToLocation.Warehouse")
+	db = db.Preload("Lines")
+
+	return Pagination(db, []models.StockTransfer{}, pn, pSize)
+}
+
+// GetTransferById 根据ID查询调拨单
+func GetTransferById(id int) (*models.StockTransfer, error) {
+	return getTransfer(global.Db, id)
+}
+
+// getTransfer 查询调拨单以及明细 db 可以是事务
+func getTransfer(db *gorm.DB, id int) (*models.StockTransfer, error) {
+	db = db.Model(&models.StockTransfer{})
+	db = db.Preload("FromLocation.Warehouse")
+	db = db.Preload("ToLocation.Warehouse")
+	db = db.Preload("Lines.Lots")
+
+	data := &models.StockTransfer{}
+	err := db.Where("id = ?", id).First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("调拨单不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range data.Lines {
+		line.ItemName, err = getTransferItemName(line)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// SaveTransfer 新建调拨单（草稿）
+func SaveTransfer(transfer *models.StockTransfer) (*models.StockTransfer, error) {
+	err := checkTransfer(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Format("20060102")
+	var total int64
+	err = global.Db.Model(&models.StockTransfer{}).Where(
+		"add_time >= ?", time.Now().Format("2006-01-02")).Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.TransferNumber = fmt.Sprintf("DB%s%d", today, total+10001)
+	transfer.Status = 1
+	transfer.ShipTime = nil
+	transfer.ReceiveTime = nil
+	for _, line := range transfer.Lines {
+		line.ID = 0
+		line.ReceivedAmount = 0
+		line.UnitCost = 0
+		line.Lots = nil
+	}
+
+	err = global.Db.Model(&models.StockTransfer{}).Create(&transfer).Error
+
+	return transfer, err
+}
+
+// UpdateTransfer 修改调拨单 只有草稿可以修改
+func UpdateTransfer(transfer *models.StockTransfer) (*models.StockTransfer, error) {
+	if transfer.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	oldData, err := GetTransferById(transfer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if oldData.Status != 1 {
+		return nil, errors.New("调拨单已发出，无法修改")
+	}
+	err = checkTransfer(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.TransferNumber = oldData.TransferNumber
+	transfer.Status = oldData.Status
+	for _, line := range transfer.Lines {
+		line.ID = 0
+		line.TransferId = transfer.ID
+		line.ReceivedAmount = 0
+		line.UnitCost = 0
+		line.Lots = nil
+	}
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 删除关联
+	err = tx.Where("transfer_id = ?", transfer.ID).Delete(&models.StockTransferLine{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Updates(&transfer).Error
+
+	return transfer, err
+}
+
+// VoidTransfer 作废调拨单 只有草稿可以作废
+func VoidTransfer(id int, username string) error {
+	if id == 0 {
+		return errors.New("id is 0")
+	}
+	data, err := GetTransferById(id)
+	if err != nil {
+		return err
+	}
+	if data.Status != 1 {
+		return errors.New("调拨单已发出，无法作废")
+	}
+
+	result := global.Db.Model(&models.StockTransfer{}).Where("id = ? and status = 1", id).
+		Updates(map[string]interface{}{
+			"status":   5,
+			"operator": username,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("调拨单已发出，无法作废")
+	}
+
+	return nil
+}
+
+// ShipTransfer 调拨发货 扣除调出库位库存 状态改为在途
+func ShipTransfer(id int, username string) error {
+	if id == 0 {
+		return errors.New("id is 0")
+	}
+	transfer, err := GetTransferById(id)
+	if err != nil {
+		return err
+	}
+	if transfer.Status != 1 {
+		return errors.New("调拨单状态错误，无法发货")
+	}
+	transfer.Operator = username
+	now := time.Now()
+	transfer.ShipTime = &now
+	transfer.Status = 2
+
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 先按状态更新调拨单 同一调拨单同时发货时只有一次扣减库存
+	result := tx.Model(&models.StockTransfer{}).Where("id = ? and status = 1", id).
+		Updates(map[string]interface{}{
+			"status":    transfer.Status,
+			"ship_time": transfer.ShipTime,
+			"operator":  transfer.Operator,
+		})
+	err = result.Error
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		err = errors.New("调拨单状态错误，无法发货")
+		return err
+	}
+	// 按更新后的调拨单读取明细
+	transfer, err = getTransfer(tx, id)
+	if err != nil {
+		return err
+	}
+	transfer.Operator = username
+
+	details := fmt.Sprintf("调拨单【%s】调出", transfer.TransferNumber)
+	for _, line := range transfer.Lines {
+		switch line.ItemType {
+		case 1:
+			err = transferOutIngredient(tx, transfer, line, details)
+		case 2:
+			err = transferOutFinished(tx, transfer, line, details)
+		case 3:
+			err = transferOutProduct(tx, transfer, line, details)
+		default:
+			err = errors.New("调拨物料类型错误")
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.StockTransferLine{}).Where("id = ?", line.ID).
+			Update("unit_cost", line.UnitCost).Error
+		if err != nil {
+			return err
+		}
+		for _, lot := range line.Lots {
+			lot.LineId = line.ID
+		}
+		err = tx.Model(&models.StockTransferLot{}).Create(&line.Lots).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// ReceiveTransfer 调拨收货 支持部分收货
+func ReceiveTransfer(receive *models.ReceiveTransfer, username string) error {
+	var err error
+	tx := global.Db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 锁定调拨单 同时收货时后执行的等待前一个提交后按最新的已收数量校验
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.StockTransfer{}).
+		Select("id").Where("id = ?", receive.ID).First(&models.StockTransfer{}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errors.New("调拨单不存在")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	var transfer *models.StockTransfer
+	transfer, err = getTransfer(tx, receive.ID)
+	if err != nil {
+		return err
+	}
+	if transfer.Status != 2 && transfer.Status != 4 {
+		err = errors.New("调拨单状态错误，无法收货")
+		return err
+	}
+	transfer.Operator = username
+
+	// 本次收货数量
+	receiveMap := make(map[int]float64)
+	if len(receive.Lines) == 0 {
+		for _, line := range transfer.Lines {
+			receiveMap[line.ID] = line.Amount - line.ReceivedAmount
+		}
+	} else {
+		for _, r := range receive.Lines {
+			receiveMap[r.LineId] += r.Amount
+		}
+	}
+
+	details := fmt.Sprintf("调拨单【%s】调入", transfer.TransferNumber)
+	finish := true
+	for _, line := range transfer.Lines {
+		amount := receiveMap[line.ID]
+		delete(receiveMap, line.ID)
+		if amount < 0 || line.ReceivedAmount+amount > line.Amount {
+			err = errors.New(fmt.Sprintf("【%s】收货数量错误", line.ItemName))
+			return err
+		}
+
+		if amount > 0 {
+			switch line.ItemType {
+			case 1:
+				err = transferInIngredient(tx, transfer, line, amount, details)
+			case 2:
+				err = transferInFinished(tx, transfer, line, amount, details)
+			case 3:
+				err = transferInProduct(tx, transfer, line, amount, details)
+			default:
+				err = errors.New("调拨物料类型错误")
+			}
+			if err != nil {
+				return err
+			}
+
+			line.ReceivedAmount += amount
+			err = tx.Model(&models.StockTransferLine{}).Where("id = ?", line.ID).
+				Update("received_amount", line.ReceivedAmount).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if line.ReceivedAmount < line.Amount {
+			finish = false
+		}
+	}
+	if len(receiveMap) > 0 {
+		err = errors.New("收货明细不属于该调拨单")
+		return err
+	}
+
+	now := time.Now()
+	transfer.ReceiveTime = &now
+	if finish {
+		transfer.Status = 3
+	} else {
+		transfer.Status = 4
+	}
+
+	err = tx.Select("status", "receive_time", "operator").Updates(&transfer).Error
+
+	return err
+}
+
+// ExportTransfer 导出调拨单
+func ExportTransfer(id int) (*excelize.File, error) {
+	transfer, err := GetTransferById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := []string{
+		"调拨单号",
+		"调出库位",
+		"调入库位",
+		"物料类型",
+		"物料名称",
+		"调拨数量",
+		"已收数量",
+		"单位成本（元）",
+		"发货时间",
+		"收货时间",
+		"状态",
+	}
+
+	var shipTime, receiveTime string
+	if transfer.ShipTime != nil {
+		shipTime = transfer.ShipTime.Format("2006-01-02 15:04:05")
+	}
+	if transfer.ReceiveTime != nil {
+		receiveTime = transfer.ReceiveTime.Format("2006-01-02 15:04:05")
+	}
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, line := range transfer.Lines {
+		valueList = append(valueList, map[string]interface{}{
+			"调拨单号":    transfer.TransferNumber,
+			"调出库位":    returnLocationName(transfer.FromLocation),
+			"调入库位":    returnLocationName(transfer.ToLocation),
+			"物料类型":    returnItemType(line.ItemType),
+			"物料名称":    line.ItemName,
+			"调拨数量":    fmt.Sprintf("%.2f%s", line.Amount, returnUnit(line.StockUnit)),
+			"已收数量":    fmt.Sprintf("%.2f%s", line.ReceivedAmount, returnUnit(line.StockUnit)),
+			"单位成本（元）": fmt.Sprintf("%.2f", line.UnitCost),
+			"发货时间":    shipTime,
+			"收货时间":    receiveTime,
+			"状态":      returnTransferStatus(transfer.Status),
+		})
+	}
+
+	return utils.ExportExcel(keyList, valueList, []string{"F", "G"})
+}
+
+// checkTransfer 校验调拨单
+func checkTransfer(transfer *models.StockTransfer) error {
+	if transfer.FromLocationId == 0 || transfer.ToLocationId == 0 {
+		return errors.New("调出和调入库位不能为空")
+	}
+	if transfer.FromLocationId == transfer.ToLocationId {
+		return errors.New("调出和调入库位不能相同")
+	}
+	if _, err := GetLocationById(transfer.FromLocationId); err != nil {
+		return err
+	}
+	if _, err := GetLocationById(transfer.ToLocationId); err != nil {
+		return err
+	}
+	if len(transfer.Lines) == 0 {
+		return errors.New("调拨明细不能为空")
+	}
+	transfer.FromLocation = nil
+	transfer.ToLocation = nil
+
+	for _, line := range transfer.Lines {
+		if line.Amount <= 0 {
+			return errors.New("调拨数量错误")
+		}
+		if line.ItemType == 3 && line.Amount != float64(int(line.Amount)) {
+			return errors.New("产品调拨数量必须为整数")
+		}
+		if line.ItemType == 1 && line.StockUnit == 0 {
+			return errors.New("配料单位错误")
+		}
+		if _, err := getTransferItemName(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTransferItemName 查询调拨物料名称
+func getTransferItemName(line *models.StockTransferLine) (string, error) {
+	switch line.ItemType {
+	case 1:
+		ingredient, err := GetIngredientsById(line.ItemId)
+		if err != nil {
+			return "", err
+		}
+		return ingredient.Name, nil
+	case 2:
+		finished, err := GetFinishedById(line.ItemId)
+		if err != nil {
+			return "", err
+		}
+		return finished.Name, nil
+	case 3:
+		product, err := GetProductById(line.ItemId)
+		if err != nil {
+			return "", err
+		}
+		return product.Name, nil
+	}
+
+	return "", errors.New("调拨物料类型错误")
+}
+
+// ingredientLot 调出库位现存的配料入库批次 InBoundId 为空表示没有入库批次的历史库存
+type ingredientLot struct {
+	InBoundId *int
+	Amount    float64
+	UnitCost  float64
+}
+
+// ingredientInflow 库位的配料入库流水
+type ingredientInflow struct {
+	InBoundId int
+	StockNum  float64
+	UnitPrice float64
+}
+
+// transferOutIngredient 扣除调出库位配料库存 按实际扣减的入库批次记录数量和单价
+func transferOutIngredient(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, details string) error {
+
+	lots, err := getIngredientLots(db, line.ItemId, line.StockUnit, transfer.FromLocationId)
+	if err != nil {
+		return err
+	}
+	line.Lots, err = allocateIngredientLots(lots, line.Amount, line.InBoundId)
+	if err != nil {
+		return errors.New(fmt.Sprintf("【%s】%s", line.ItemName, err.Error()))
+	}
+	line.UnitCost = getTransferUnitCost(line.Lots)
+
+	var isPackage int
+	amount := line.Amount
+	for amount > 0 {
+		stock := &models.IngredientStock{}
+		err = db.Model(&models.IngredientStock{}).
+			Where("ingredient_id = ? and stock_unit = ?", line.ItemId, line.StockUnit).
+			Where("location_id = ? and stock_num > 0", transfer.FromLocationId).
+			Order("add_time asc").First(&stock).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(fmt.Sprintf("【%s】调出库位库存不足", line.ItemName))
+		}
+		if err != nil {
+			return err
+		}
+
+		num := amount
+		if stock.StockNum < num {
+			num = stock.StockNum
+		}
+		stock.StockNum -= num
+		amount -= num
+		isPackage = stock.IsPackage
+		err = db.Select("stock_num").Updates(&stock).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// 每个入库批次一条出库记录
+	falseValue := false
+	for _, lot := range line.Lots {
+		_, err = SaveConsume(db, &models.IngredientConsume{
+			BaseModel: models.BaseModel{
+				Operator: transfer.Operator,
+			},
+			IngredientId:     &line.ItemId,
+			InBoundId:        lot.InBoundId,
+			StockNum:         0 - lot.Amount,
+			StockUnit:        line.StockUnit,
+			OperationType:    &falseValue,
+			OperationDetails: details,
+			IsPackage:        isPackage,
+			LocationId:       transfer.FromLocationId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transferInIngredient 增加调入库位配料库存 按调出时的入库批次入库
+func transferInIngredient(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, amount float64, details string) error {
+
+	parts, err := receiveTransferLots(db, line, amount)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		var isPackage int
+		if part.Lot.InBoundId != nil {
+			inBound, err := GetInBoundById(*part.Lot.InBoundId)
+			if err != nil {
+				return err
+			}
+			isPackage = inBound.IsPackage
+		}
+
+		err = SaveStockByInBound(db, &models.IngredientInBound{
+			BaseModel: models.BaseModel{
+				Operator: transfer.Operator,
+			},
+			IngredientId: &line.ItemId,
+			StockNum:     part.Amount,
+			StockUnit:    line.StockUnit,
+			IsPackage:    isPackage,
+			LocationId:   transfer.ToLocationId,
+		})
+		if err != nil {
+			return err
+		}
+
+		trueValue := true
+		_, err = SaveConsume(db, &models.IngredientConsume{
+			BaseModel: models.BaseModel{
+				Operator: transfer.Operator,
+			},
+			IngredientId:     &line.ItemId,
+			InBoundId:        part.Lot.InBoundId,
+			StockNum:         part.Amount,
+			StockUnit:        line.StockUnit,
+			OperationType:    &trueValue,
+			OperationDetails: details,
+			IsPackage:        isPackage,
+			LocationId:       transfer.ToLocationId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getIngredientLots 调出库位现存的配料批次 按先进先出排列
+// 出库记录没有全部关联入库批次 现存库存视为由最近入库的批次组成
+func getIngredientLots(db *gorm.DB, ingredientId, stockUnit, locationId int) ([]ingredientLot, error) {
+	var stockNum float64
+	err := db.Model(&models.IngredientStock{}).Select("COALESCE(SUM(stock_num), 0)").
+		Where("ingredient_id = ? and stock_unit = ? and location_id = ?",
+			ingredientId, stockUnit, locationId).Scan(&stockNum).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var inflows []ingredientInflow
+	err = db.Model(&models.IngredientConsume{}).
+		Select("tb_ingredient_consume.in_bound_id, tb_ingredient_consume.stock_num, "+
+			"tb_ingredient_in_bound.unit_price").
+		Joins("JOIN tb_ingredient_in_bound ON tb_ingredient_in_bound.id = tb_ingredient_consume.in_bound_id").
+		Where("tb_ingredient_consume.ingredient_id = ? and tb_ingredient_consume.stock_unit = ?",
+			ingredientId, stockUnit).
+		Where("tb_ingredient_consume.location_id = ? and tb_ingredient_consume.operation_type = ?",
+			locationId, true).
+		Where("tb_ingredient_consume.stock_num > 0").
+		Order("tb_ingredient_consume.add_time desc, tb_ingredient_consume.id desc").
+		Scan(&inflows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lots := buildIngredientLots(stockNum, inflows)
+	for i := range lots {
+		if lots[i].InBoundId == nil {
+			// 没有入库批次的历史库存按最近入库单价
+			lots[i].UnitCost, err = getLatestUnitPrice(ingredientId, stockUnit)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return lots, nil
+}
+
+// buildIngredientLots 按入库流水(新的在前)还原现存库存的批次 返回先进先出的批次
+// 入库流水不足以覆盖库存的部分为没有入库批次的历史库存 排在最前
+func buildIngredientLots(stockNum float64, inflows []ingredientInflow) []ingredientLot {
+	lots := make([]ingredientLot, 0)
+	index := make(map[int]int)
+	remain := stockNum
+	for _, inflow := range inflows {
+		if remain <= 1e-6 {
+			break
+		}
+		num := math.Min(inflow.StockNum, remain)
+		remain -= num
+		if i, ok := index[inflow.InBoundId]; ok {
+			lots[i].Amount += num
+			continue
+		}
+		inBoundId := inflow.InBoundId
+		index[inBoundId] = len(lots)
+		lots = append(lots, ingredientLot{
+			InBoundId: &inBoundId,
+			Amount:    num,
+			UnitCost:  inflow.UnitPrice,
+		})
+	}
+	if remain > 1e-6 {
+		lots = append(lots, ingredientLot{Amount: remain})
+	}
+
+	// 改为先进先出
+	for i, j := 0, len(lots)-1; i < j; i, j = i+1, j-1 {
+		lots[i], lots[j] = lots[j], lots[i]
+	}
+
+	return lots
+}
+
+// allocateIngredientLots 按先进先出从批次中分配调拨数量 指定入库批次时只从该批次分配
+func allocateIngredientLots(lots []ingredientLot, amount float64, inBoundId *int) ([]*models.StockTransferLot, error) {
+	if inBoundId != nil {
+		selected := make([]ingredientLot, 0)
+		for _, lot := range lots {
+			if lot.InBoundId != nil && *lot.InBoundId == *inBoundId {
+				selected = append(selected, lot)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, errors.New(fmt.Sprintf("入库批次【%d】在调出库位没有库存", *inBoundId))
+		}
+		lots = selected
+	}
+
+	result := make([]*models.StockTransferLot, 0)
+	for _, lot := range lots {
+		if amount <= 1e-6 {
+			break
+		}
+		num := math.Min(lot.Amount, amount)
+		result = append(result, &models.StockTransferLot{
+			InBoundId: lot.InBoundId,
+			Amount:    num,
+			UnitCost:  lot.UnitCost,
+		})
+		amount -= num
+	}
+	if amount > 1e-6 {
+		if inBoundId != nil {
+			return nil, errors.New(fmt.Sprintf("入库批次【%d】在调出库位库存不足", *inBoundId))
+		}
+		return nil, errors.New("调出库位库存不足")
+	}
+
+	return result, nil
+}
+
+// getTransferUnitCost 批次加权平均单位成本
+func getTransferUnitCost(lots []*models.StockTransferLot) float64 {
+	var amount, cost float64
+	for _, lot := range lots {
+		amount += lot.Amount
+		cost += lot.Amount * lot.UnitCost
+	}
+	if amount == 0 {
+		return 0
+	}
+
+	return cost / amount
+}
+
+// transferLotPart 本次收货对应的批次和数量
+type transferLotPart struct {
+	Lot    *models.StockTransferLot
+	Amount float64
+}
+
+// receiveTransferLots 按批次顺序分配本次收货数量 并更新批次已收数量
+// 没有批次明细的历史调拨单按调拨明细作为一个批次
+func receiveTransferLots(db *gorm.DB, line *models.StockTransferLine, amount float64) ([]transferLotPart, error) {
+	lots := line.Lots
+	if len(lots) == 0 {
+		lots = []*models.StockTransferLot{{
+			InBoundId:      line.InBoundId,
+			Amount:         line.Amount,
+			ReceivedAmount: line.ReceivedAmount,
+			UnitCost:       line.UnitCost,
+		}}
+	}
+
+	parts := make([]transferLotPart, 0)
+	for _, lot := range lots {
+		if amount <= 1e-6 {
+			break
+		}
+		num := math.Min(lot.Amount-lot.ReceivedAmount, amount)
+		if num <= 1e-6 {
+			continue
+		}
+		lot.ReceivedAmount += num
+		amount -= num
+		parts = append(parts, transferLotPart{Lot: lot, Amount: num})
+
+		if lot.ID != 0 {
+			err := db.Model(&models.StockTransferLot{}).Where("id = ?", lot.ID).
+				Update("received_amount", lot.ReceivedAmount).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if amount > 1e-6 {
+		return nil, errors.New(fmt.Sprintf("【%s】收货数量错误", line.ItemName))
+	}
+
+	return parts, nil
+}
+
+// transferOutFinished 扣除调出库位成品库存 按配方成本记录单位成本
+func transferOutFinished(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, details string) error {
+
+	unitCost, err := getFinishedCost(line.ItemId, map[int]bool{})
+	if err != nil {
+		return err
+	}
+	line.UnitCost = unitCost
+	line.Lots = []*models.StockTransferLot{{
+		Amount:   line.Amount,
+		UnitCost: unitCost,
+	}}
+
+	amount := line.Amount
+	for amount > 0 {
+		stock := &models.FinishedStock{}
+		err := db.Model(&models.FinishedStock{}).
+			Where("finished_id = ? and location_id = ? and amount > 0",
+				line.ItemId, transfer.FromLocationId).
+			Order("add_time asc").First(&stock).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(fmt.Sprintf("【%s】调出库位库存不足", line.ItemName))
+		}
+		if err != nil {
+			return err
+		}
+
+		num := amount
+		if stock.Amount < num {
+			num = stock.Amount
+		}
+		stock.Amount -= num
+		amount -= num
+		err = db.Select("amount").Updates(&stock).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	falseValue := false
+	_, err = SaveFinishedConsume(db, &models.FinishedConsume{
+		BaseModel: models.BaseModel{
+			Operator: transfer.Operator,
+		},
+		FinishedId:       line.ItemId,
+		StockNum:         0 - line.Amount,
+		OperationType:    &falseValue,
+		OperationDetails: details,
+		LocationId:       transfer.FromLocationId,
+	})
+
+	return err
+}
+
+// transferInFinished 增加调入库位成品库存
+func transferInFinished(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, amount float64, details string) error {
+
+	_, err := receiveTransferLots(db, line, amount)
+	if err != nil {
+		return err
+	}
+
+	stock := new(models.FinishedStock)
+	err = db.Model(&models.FinishedStock{}).
+		Where("finished_id = ? and location_id = ?", line.ItemId, transfer.ToLocationId).
+		Find(&stock).Error
+	if err != nil {
+		return err
+	}
+
+	if stock.ID != 0 {
+		stock.Amount += amount
+		err = db.Select("amount").Updates(&stock).Error
+	} else {
+		_, err = SaveFinishedStock(db, &models.FinishedStock{
+			BaseModel: models.BaseModel{
+				Operator: transfer.Operator,
+			},
+			FinishedId: line.ItemId,
+			Amount:     amount,
+			LocationId: transfer.ToLocationId,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	trueValue := true
+	_, err = SaveFinishedConsume(db, &models.FinishedConsume{
+		BaseModel: models.BaseModel{
+			Operator: transfer.Operator,
+		},
+		FinishedId:       line.ItemId,
+		StockNum:         amount,
+		OperationType:    &trueValue,
+		OperationDetails: details,
+		LocationId:       transfer.ToLocationId,
+	})
+
+	return err
+}
+
+// transferOutProduct 扣除调出库位产品库存 按实际扣减的产品库存批次记录数量和成本
+func transferOutProduct(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, details string) error {
+
+	line.Lots = make([]*models.StockTransferLot, 0)
+	amount := int(line.Amount)
+	for amount > 0 {
+		inventory := &models.ProductInventory{}
+		err := db.Model(&models.ProductInventory{}).Preload("InventoryContent").
+			Where("product_id = ? and location_id = ? and amount > 0",
+				line.ItemId, transfer.FromLocationId).
+			Order("add_time asc").First(&inventory).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(fmt.Sprintf("【%s】调出库位库存不足", line.ItemName))
+		}
+		if err != nil {
+			return err
+		}
+
+		unitCost, err := getInventoryCost(inventory)
+		if err != nil {
+			return err
+		}
+		num := amount
+		if inventory.Amount < num {
+			num = inventory.Amount
+		}
+		inventoryId := inventory.ID
+		line.Lots = append(line.Lots, &models.StockTransferLot{
+			InventoryId: &inventoryId,
+			Amount:      float64(num),
+			UnitCost:    unitCost,
+		})
+
+		inventory.Amount -= num
+		amount -= num
+		err = db.Select("amount").Updates(&inventory).Error
+		if err != nil {
+			return err
+		}
+	}
+	line.UnitCost = getTransferUnitCost(line.Lots)
+
+	falseValue := false
+	return db.Model(&models.ProductConsume{}).Create(&models.ProductConsume{
+		BaseModel: models.BaseModel{
+			Operator: transfer.Operator,
+		},
+		ProductId:        line.ItemId,
+		StockNum:         0 - line.Amount,
+		OperationType:    &falseValue,
+		OperationDetails: details,
+		LocationId:       transfer.FromLocationId,
+	}).Error
+}
+
+// transferInProduct 在调入库位按调出批次新建产品库存批次 沿用调出批次的成品用量
+func transferInProduct(db *gorm.DB, transfer *models.StockTransfer,
+	line *models.StockTransferLine, amount float64, details string) error {
+
+	if amount != float64(int(amount)) {
+		return errors.New("产品收货数量必须为整数")
+	}
+	parts, err := receiveTransferLots(db, line, amount)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		if part.Amount != float64(int(part.Amount)) {
+			return errors.New("产品收货数量必须为整数")
+		}
+		inventory := &models.ProductInventory{
+			BaseModel: models.BaseModel{
+				Operator: transfer.Operator,
+			},
+			ProductId:        line.ItemId,
+			Amount:           int(part.Amount),
+			LocationId:       transfer.ToLocationId,
+			InventoryContent: make([]models.InventoryContent, 0),
+		}
+
+		if part.Lot.InventoryId != nil {
+			source, err := GetProductInventoryById(*part.Lot.InventoryId)
+			if err != nil {
+				return err
+			}
+			inventory.SourceId = &source.ID
+			if source.SourceId != nil {
+				inventory.SourceId = source.SourceId
+			}
+			for _, content := range source.InventoryContent {
+				inventory.InventoryContent = append(inventory.InventoryContent, models.InventoryContent{
+					FinishedId: content.FinishedId,
+					Quantity:   content.Quantity,
+				})
+			}
+		} else {
+			// 没有批次明细的历史调拨单按产品当前用量
+			product, err := GetProductById(line.ItemId)
+			if err != nil {
+				return err
+			}
+			for _, content := range product.ProductContent {
+				inventory.InventoryContent = append(inventory.InventoryContent, models.InventoryContent{
+					FinishedId: content.FinishedId,
+					Quantity:   content.Quantity,
+				})
+			}
+		}
+
+		err = db.Model(&models.ProductInventory{}).Create(inventory).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	trueValue := true
+	return db.Model(&models.ProductConsume{}).Create(&models.ProductConsume{
+		BaseModel: models.BaseModel{
+			Operator: transfer.Operator,
+		},
+		ProductId:        line.ItemId,
+		StockNum:         amount,
+		OperationType:    &trueValue,
+		OperationDetails: details,
+		LocationId:       transfer.ToLocationId,
+	}).Error
+}
+
+// getInventoryCost 产品库存批次单位成本 按批次的成品用量和成品配方成本汇总
+func getInventoryCost(inventory *models.ProductInventory) (float64, error) {
+	var cost float64
+	for _, content := range inventory.InventoryContent {
+		price, err := getFinishedCost(content.FinishedId, map[int]bool{})
+		if err != nil {
+			return 0, err
+		}
+		cost += price * content.Quantity
+	}
+
+	return cost, nil
+}
+
+func returnLocationName(location *models.Location) string {
+	if location == nil {
+		return ""
+	}
+	if location.Warehouse == nil {
+		return location.Name
+	}
+	return fmt.Sprintf("%s-%s", location.Warehouse.Name, location.Name)
+}
+
+func returnItemType(i int) string {
+	switch i {
+	case 1:
+		return "配料"
+	case 2:
+		return "成品"
+	case 3:
+		return "产品"
+	}
+	return ""
+}
+
+func returnTransferStatus(i int) string {
+	switch i {
+	case 1:
+		return "草稿"
+	case 2:
+		return "在途"
+	case 3:
+		return "已收货"
+	case 4:
+		return "部分收货"
+	case 5:
+		return "作废"
+	}
+	return ""
+}
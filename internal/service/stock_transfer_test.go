@@ -0,0 +1,114 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBuildIngredientLots(t *testing.T) {
+	// 入库流水 新的在前
+	inflows := []ingredientInflow{
+		{InBoundId: 3, StockNum: 10, UnitPrice: 3},
+		{InBoundId: 2, StockNum: 5, UnitPrice: 2},
+		{InBoundId: 3, StockNum: 4, UnitPrice: 3},
+		{InBoundId: 1, StockNum: 20, UnitPrice: 1},
+	}
+
+	tests := []struct {
+		name     string
+		stockNum float64
+		want     []ingredientLot
+	}{
+		{
+			name:     "库存只剩最近的批次",
+			stockNum: 8,
+			want:     []ingredientLot{{InBoundId: intPtr(3), Amount: 8, UnitCost: 3}},
+		},
+		{
+			name:     "跨多个批次 先进先出",
+			stockNum: 18,
+			want: []ingredientLot{
+				{InBoundId: intPtr(2), Amount: 5, UnitCost: 2},
+				{InBoundId: intPtr(3), Amount: 13, UnitCost: 3},
+			},
+		},
+		{
+			name:     "入库流水不足的部分没有批次",
+			stockNum: 45,
+			want: []ingredientLot{
+				{Amount: 6},
+				{InBoundId: intPtr(1), Amount: 20, UnitCost: 1},
+				{InBoundId: intPtr(2), Amount: 5, UnitCost: 2},
+				{InBoundId: intPtr(3), Amount: 14, UnitCost: 3},
+			},
+		},
+		{
+			name:     "没有库存",
+			stockNum: 0,
+			want:     []ingredientLot{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildIngredientLots(tt.stockNum, inflows)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lots, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !sameIntPtr(got[i].InBoundId, tt.want[i].InBoundId) ||
+					math.Abs(got[i].Amount-tt.want[i].Amount) > 1e-6 ||
+					got[i].UnitCost != tt.want[i].UnitCost {
+					t.Errorf("lot %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateIngredientLots(t *testing.T) {
+	lots := []ingredientLot{
+		{InBoundId: intPtr(1), Amount: 4, UnitCost: 1},
+		{InBoundId: intPtr(2), Amount: 6, UnitCost: 2},
+	}
+
+	got, err := allocateIngredientLots(lots, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Amount != 4 || got[1].Amount != 3 || *got[1].InBoundId != 2 {
+		t.Fatalf("unexpected allocation: %+v %+v", got[0], got[1])
+	}
+	if cost := getTransferUnitCost(got); math.Abs(cost-10.0/7) > 1e-9 {
+		t.Errorf("unit cost = %v", cost)
+	}
+
+	got, err = allocateIngredientLots(lots, 5, intPtr(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || *got[0].InBoundId != 2 || got[0].UnitCost != 2 {
+		t.Fatalf("unexpected allocation: %+v", got[0])
+	}
+
+	if _, err = allocateIngredientLots(lots, 7, intPtr(2)); err == nil {
+		t.Error("指定批次库存不足时应返回错误")
+	}
+	if _, err = allocateIngredientLots(lots, 1, intPtr(9)); err == nil {
+		t.Error("指定批次不在调出库位时应返回错误")
+	}
+	if _, err = allocateIngredientLots(lots, 11, nil); err == nil {
+		t.Error("库存不足时应返回错误")
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func sameIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
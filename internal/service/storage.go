@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/storage"
+)
+
+// storageDirs 存储中的文件目录 images 图库 execl 送货单 pdf 送货单 import 导入错误明细
+var storageDirs = []string{"images", "execl", "pdf", "import"}
+
+// GetFileUrl 获取文件的签名地址 有效期内可直接下载
+func GetFileUrl(key string) (string, error) {
+	key, err := storage.CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if !containsString(storageDirs, strings.SplitN(key, "/", 2)[0]) {
+		return "", errors.New("文件路径错误")
+	}
+
+	exists, err := storage.Default.Exists(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", storage.ErrNotExist
+	}
+
+	return storage.Default.SignedURL(key, storage.UrlExpire)
+}
+
+// GetSignedLocalFile 校验本地存储的签名地址 返回文件路径
+func GetSignedLocalFile(key, expires, sign string) (string, error) {
+	local, ok := storage.Default.(*storage.Local)
+	if !ok {
+		return "", errors.New("当前不是本地存储")
+	}
+	err := local.Verify(key, expires, sign)
+	if err != nil {
+		return "", err
+	}
+
+	return local.Path(key)
+}
+
+// MigrateStorage 将本地目录中的文件迁移到当前存储 仅管理员可操作
+func MigrateStorage(ctx context.Context, userId int) (map[string]int, error) {
+	err := checkAdmin(userId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := storage.Default.(*storage.Local); ok {
+		return nil, errors.New("当前为本地存储，无需迁移")
+	}
+
+	localDir := global.ServerConfig.StorageInfo.LocalDir
+	data := map[string]int{
+		"migrated": 0,
+		"skipped":  0,
+	}
+	for _, dir := range storageDirs {
+		migrated, skipped, err := storage.Migrate(ctx, filepath.Join(localDir, dir), dir, storage.Default)
+		data["migrated"] += migrated
+		data["skipped"] += skipped
+		if err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+// putStorageFile 将本地文件保存到文件存储
+func putStorageFile(key, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return storage.Default.Put(context.Background(), key, f, storage.ContentType(key))
+}
+
+// galleryImageKey 图库图片在存储中的路径
+func galleryImageKey(url string) string {
+	return "images/" + url
+}
@@ -227,3 +227,16 @@ func getAdmin(userId int) (bool, error) {
 
 	return false, nil
 }
+
+// checkAdmin 仅管理员可以操作
+func checkAdmin(userId int) error {
+	b, err := getAdmin(userId)
+	if err != nil {
+		return err
+	}
+	if !b {
+		return errors.New("没有权限")
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+)
+
+// GetWarehouseList 获取仓库列表
+func GetWarehouseList(warehouse *models.Warehouse, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.Warehouse{})
+
+	if warehouse.Name != "" {
+		db = db.Where("name LIKE ?", "%"+warehouse.Name+"%")
+	}
+	db = db.Preload("Location")
+
+	return Pagination(db, []models.Warehouse{}, pn, pSize)
+}
+
+// GetWarehouseById 根据ID获取仓库
+func GetWarehouseById(id int) (*models.Warehouse, error) {
+	db := global.Db.Model(&models.Warehouse{})
+
+	data := &models.Warehouse{}
+	err := db.Where("id = ?", id).Preload("Location").First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("仓库不存在")
+	}
+
+	return data, err
+}
+
+// SaveWarehouse 新增仓库
+func SaveWarehouse(warehouse *models.Warehouse) (*models.Warehouse, error) {
+	var count int64
+	err := global.Db.Model(&models.Warehouse{}).Where("name = ?",
+		warehouse.Name).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("仓库名称已存在")
+	}
+
+	// 库位通过库位接口维护
+	warehouse.Location = nil
+	err = global.Db.Model(&models.Warehouse{}).Create(warehouse).Error
+
+	return warehouse, err
+}
+
+// UpdateWarehouse 修改仓库
+func UpdateWarehouse(warehouse *models.Warehouse) (*models.Warehouse, error) {
+	if warehouse.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	_, err := GetWarehouseById(warehouse.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	warehouse.Location = nil
+
+	return warehouse, global.Db.Updates(&warehouse).Error
+}
+
+// DelWarehouse 删除仓库
+func DelWarehouse(id int) error {
+	if id == 0 {
+		return errors.New("id is 0")
+	}
+
+	data, err := GetWarehouseById(id)
+	if err != nil {
+		return err
+	}
+	if len(data.Location) > 0 {
+		return errors.New("仓库下存在库位，无法删除")
+	}
+
+	return global.Db.Delete(&data).Error
+}
+
+// GetLocationList 获取库位列表
+func GetLocationList(location *models.Location, pn, pSize int) (interface{}, error) {
+	db := global.Db.Model(&models.Location{})
+
+	if location.WarehouseId != 0 {
+		db = db.Where("warehouse_id = ?", location.WarehouseId)
+	}
+	if location.Name != "" {
+		db = db.Where("name LIKE ?", "%"+location.Name+"%")
+	}
+	db = db.Preload("Warehouse")
+
+	return Pagination(db, []models.Location{}, pn, pSize)
+}
+
+// GetLocationById 根据ID获取库位
+func GetLocationById(id int) (*models.Location, error) {
+	db := global.Db.Model(&models.Location{})
+
+	data := &models.Location{}
+	err := db.Where("id = ?", id).Preload("Warehouse").First(&data).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("库位不存在")
+	}
+
+	return data, err
+}
+
+// CheckLocation 校验库位 0 表示未指定库位
+func CheckLocation(id int) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := GetLocationById(id)
+
+	return err
+}
+
+// SaveLocation 新增库位
+func SaveLocation(location *models.Location) (*models.Location, error) {
+	_, err := GetWarehouseById(location.WarehouseId)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err = global.Db.Model(&models.Location{}).
+		Where("warehouse_id = ? and name = ?", location.WarehouseId, location.Name).
+		Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("库位名称已存在")
+	}
+
+	location.Warehouse = nil
+	err = global.Db.Model(&models.Location{}).Create(location).Error
+
+	return location, err
+}
+
+// UpdateLocation 修改库位
+func UpdateLocation(location *models.Location) (*models.Location, error) {
+	if location.ID == 0 {
+		return nil, errors.New("id is 0")
+	}
+	_, err := GetLocationById(location.ID)
+	if err != nil {
+		return nil, err
+	}
+	if location.WarehouseId != 0 {
+		_, err = GetWarehouseById(location.WarehouseId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	location.Warehouse = nil
+
+	return location, global.Db.Updates(&location).Error
+}
+
+// DelLocation 删除库位
+func DelLocation(id int) error {
+	if id == 0 {
+		return errors.New("id is 0")
+	}
+
+	data, err := GetLocationById(id)
+	if err != nil {
+		return err
+	}
+
+	// 库位上还有库存时不允许删除
+	stockList := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.IngredientStock{}, "stock_num"},
+		{&models.FinishedStock{}, "amount"},
+		{&models.ProductInventory{}, "amount"},
+	}
+	for _, s := range stockList {
+		var total int64
+		err = global.Db.Model(s.model).
+			Where("location_id = ? and "+s.column+" > 0", id).
+			Count(&total).Error
+		if err != nil {
+			return err
+		}
+		if total > 0 {
+			return errors.New("库位存在库存，无法删除")
+		}
+	}
+
+	return global.Db.Delete(&data).Error
+}
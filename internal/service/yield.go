@@ -0,0 +1,231 @@
+package service
+
+import (
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"sort"
+	"warehouse_oa/internal/global"
+	"warehouse_oa/internal/models"
+	"warehouse_oa/utils"
+)
+
+// GetYieldReport 成品良品率及配料差异分析 统计已完工报工
+func GetYieldReport(finishedId int, begTime, endTime string) (map[string]interface{}, error) {
+	db := global.Db.Model(&models.FinishedProduction{}).Where("status = ?", 2)
+	if finishedId > 0 {
+		db = db.Where("finished_id = ?", finishedId)
+	}
+	if begTime != "" && endTime != "" {
+		db = db.Where("DATE_FORMAT(finish_time, '%Y-%m-%d') BETWEEN ? AND ?", begTime, endTime)
+	}
+
+	productionList := make([]*models.FinishedProduction, 0)
+	err := db.Preload("Finished").Preload("UserList").
+		Order("finish_time").Find(&productionList).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 配料差异在结案时计算 这里只读取
+	productionIds := make([]int, 0)
+	for _, production := range productionList {
+		productionIds = append(productionIds, production.ID)
+	}
+	allVariance := make([]models.ProductionVariance, 0)
+	if len(productionIds) > 0 {
+		err = global.Db.Model(&models.ProductionVariance{}).
+			Where("production_id in ?", productionIds).
+			Preload("Ingredient").Find(&allVariance).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	varianceMap := make(map[int][]models.ProductionVariance)
+	for _, v := range allVariance {
+		varianceMap[v.ProductionId] = append(varianceMap[v.ProductionId], v)
+	}
+
+	finishedMap := make(map[int]*models.YieldFinished)
+	ingredientMap := make(map[string]*models.YieldIngredient)
+	operatorMap := make(map[string]*models.YieldOperator)
+	finishedList := make([]*models.YieldFinished, 0)
+	ingredientList := make([]*models.YieldIngredient, 0)
+	operatorList := make([]*models.YieldOperator, 0)
+
+	for _, production := range productionList {
+		varianceList := varianceMap[production.ID]
+
+		finishedName := ""
+		if production.Finished != nil {
+			finishedName = production.Finished.Name
+		}
+		f, ok := finishedMap[production.FinishedId]
+		if !ok {
+			f = &models.YieldFinished{
+				FinishedId:   production.FinishedId,
+				FinishedName: finishedName,
+			}
+			finishedMap[production.FinishedId] = f
+			finishedList = append(finishedList, f)
+		}
+		f.ProductionCount++
+		f.ExpectAmount += production.ExpectAmount
+		f.ActualAmount += production.ActualAmount
+
+		var varianceCost float64
+		for _, v := range varianceList {
+			f.StandardCost += v.StandardNum * v.UnitPrice
+			f.ActualCost += v.ActualNum * v.UnitPrice
+			varianceCost += v.VarianceCost
+
+			key := fmt.Sprintf("%d_%d_%d", production.FinishedId, v.IngredientId, v.StockUnit)
+			i, ok := ingredientMap[key]
+			if !ok {
+				i = &models.YieldIngredient{
+					FinishedId:   production.FinishedId,
+					FinishedName: finishedName,
+					IngredientId: v.IngredientId,
+					StockUnit:    v.StockUnit,
+				}
+				if v.Ingredient != nil {
+					i.IngredientName = v.Ingredient.Name
+				}
+				ingredientMap[key] = i
+				ingredientList = append(ingredientList, i)
+			}
+			i.StandardNum += v.StandardNum
+			i.ActualNum += v.ActualNum
+			i.VarianceNum += v.VarianceNum
+			i.VarianceCost += v.VarianceCost
+		}
+		f.VarianceCost += varianceCost
+
+		// 报工没有生产人员时按未分配统计 多人生产时差异金额平均分摊
+		month := ""
+		if production.FinishTime != nil {
+			month = production.FinishTime.Format("2006-01")
+		}
+		userList := production.UserList
+		if len(userList) == 0 {
+			userList = []models.User{{Nickname: "未分配"}}
+		}
+		for _, user := range userList {
+			key := fmt.Sprintf("%d_%s", user.ID, month)
+			o, ok := operatorMap[key]
+			if !ok {
+				o = &models.YieldOperator{
+					UserId:   user.ID,
+					UserName: user.Nickname,
+					Month:    month,
+				}
+				if o.UserName == "" {
+					o.UserName = user.Username
+				}
+				operatorMap[key] = o
+				operatorList = append(operatorList, o)
+			}
+			o.ProductionCount++
+			o.ExpectAmount += production.ExpectAmount
+			o.ActualAmount += production.ActualAmount
+			o.VarianceCost += varianceCost / float64(len(userList))
+		}
+	}
+
+	for _, f := range finishedList {
+		f.Ratio = getRatio(f.ActualAmount, f.ExpectAmount)
+	}
+	for _, i := range ingredientList {
+		if i.StandardNum != 0 {
+			i.VarianceRate = i.VarianceNum / i.StandardNum * 100
+		}
+	}
+	for _, o := range operatorList {
+		o.Ratio = getRatio(o.ActualAmount, o.ExpectAmount)
+	}
+	sort.SliceStable(operatorList, func(i, j int) bool {
+		if operatorList[i].UserId != operatorList[j].UserId {
+			return operatorList[i].UserId < operatorList[j].UserId
+		}
+		return operatorList[i].Month < operatorList[j].Month
+	})
+
+	return map[string]interface{}{
+		"finished":   finishedList,
+		"ingredient": ingredientList,
+		"operator":   operatorList,
+	}, nil
+}
+
+// ExportYieldReport 导出良品率及配料差异
+func ExportYieldReport(finishedId int, begTime, endTime string) (*excelize.File, error) {
+	data, err := GetYieldReport(finishedId, begTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := []string{
+		"类型",
+		"成品/生产人员",
+		"配料/月份",
+		"报工次数",
+		"预计数量",
+		"实际数量",
+		"良品率(%)",
+		"标准用量",
+		"实际用量",
+		"差异数量",
+		"差异率(%)",
+		"标准成本（元）",
+		"实际成本（元）",
+		"差异金额（元）",
+	}
+
+	valueList := make([]map[string]interface{}, 0)
+	for _, f := range data["finished"].([]*models.YieldFinished) {
+		valueList = append(valueList, map[string]interface{}{
+			"类型":      "成品",
+			"成品/生产人员": f.FinishedName,
+			"报工次数":    f.ProductionCount,
+			"预计数量":    f.ExpectAmount,
+			"实际数量":    f.ActualAmount,
+			"良品率(%)":  fmt.Sprintf("%.2f", f.Ratio),
+			"标准成本（元）": fmt.Sprintf("%.2f", f.StandardCost),
+			"实际成本（元）": fmt.Sprintf("%.2f", f.ActualCost),
+			"差异金额（元）": fmt.Sprintf("%.2f", f.VarianceCost),
+		})
+	}
+	for _, i := range data["ingredient"].([]*models.YieldIngredient) {
+		unit := returnUnit(i.StockUnit)
+		valueList = append(valueList, map[string]interface{}{
+			"类型":      "配料差异",
+			"成品/生产人员": i.FinishedName,
+			"配料/月份":   i.IngredientName,
+			"标准用量":    fmt.Sprintf("%.2f%s", i.StandardNum, unit),
+			"实际用量":    fmt.Sprintf("%.2f%s", i.ActualNum, unit),
+			"差异数量":    fmt.Sprintf("%.2f%s", i.VarianceNum, unit),
+			"差异率(%)":  fmt.Sprintf("%.2f", i.VarianceRate),
+			"差异金额（元）": fmt.Sprintf("%.2f", i.VarianceCost),
+		})
+	}
+	for _, o := range data["operator"].([]*models.YieldOperator) {
+		valueList = append(valueList, map[string]interface{}{
+			"类型":      "生产人员",
+			"成品/生产人员": o.UserName,
+			"配料/月份":   o.Month,
+			"报工次数":    o.ProductionCount,
+			"预计数量":    o.ExpectAmount,
+			"实际数量":    o.ActualAmount,
+			"良品率(%)":  fmt.Sprintf("%.2f", o.Ratio),
+			"差异金额（元）": fmt.Sprintf("%.2f", o.VarianceCost),
+		})
+	}
+
+	return utils.ExportExcel(keyList, valueList, []string{"J", "N"})
+}
+
+func getRatio(actual, expect int) float64 {
+	if expect == 0 {
+		return 0
+	}
+	return float64(actual) / float64(expect) * 100
+}
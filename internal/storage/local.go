@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local 本地磁盘存储 签名地址由 /files 接口校验后返回文件
+type Local struct {
+	dir       string
+	publicUrl string
+	signKey   []byte
+}
+
+// NewLocal 创建本地存储 没有配置签名密钥时随机生成 重启后之前的签名地址失效
+func NewLocal(dir, publicUrl, signKey string) (*Local, error) {
+	if dir == "" {
+		dir = "./cos"
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	key := []byte(signKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Local{
+		dir:       dir,
+		publicUrl: strings.TrimRight(publicUrl, "/"),
+		signKey:   key,
+	}, nil
+}
+
+// Dir 本地存储目录
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// Path 文件在本地的路径
+func (l *Local) Path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+
+	// 先写临时文件 避免写入中途失败留下不完整的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	dir, err := l.Path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]Object, 0)
+	if _, err = os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return objects, nil
+	}
+
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 跳过目录和写入中的临时文件
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{
+			Key:     filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+
+		return nil
+	})
+
+	return objects, err
+}
+
+// URL 公开访问地址 images 目录通过静态路由访问
+func (l *Local) URL(key string) string {
+	key, _ = CleanKey(key)
+
+	return fmt.Sprintf("%s/%s", l.publicUrl, escapeKey(key))
+}
+
+// SignedURL 签名地址 通过 /files 接口访问
+func (l *Local) SignedURL(key string, expire time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(expire).Unix()
+
+	return fmt.Sprintf("%s/files/%s?expires=%d&sign=%s",
+		l.publicUrl, escapeKey(key), expires, l.sign(key, expires)), nil
+}
+
+// Verify 校验签名地址
+func (l *Local) Verify(key, expires, sign string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	e, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > e {
+		return errors.New("地址已过期")
+	}
+	if !hmac.Equal([]byte(sign), []byte(l.sign(key, e))) {
+		return errors.New("签名错误")
+	}
+
+	return nil
+}
+
+func (l *Local) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signKey)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d", key, expires)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// escapeKey 地址中的 key 按路径逐段编码
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3 S3 兼容存储 使用 AWS Signature V4 签名 路径方式访问存储桶
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	publicUrl string
+	client    *http.Client
+}
+
+// NewS3 创建 S3 兼容存储 没有配置访问地址时使用 endpoint/bucket
+func NewS3(cfg global.S3Config, publicUrl string) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3 endpoint 和 bucket 不能为空")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3 endpoint 错误: %s", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3{
+		endpoint:  endpoint,
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		publicUrl: strings.TrimRight(publicUrl, "/"),
+		client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkS3Response(resp)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	if err = checkS3Response(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	return checkS3Response(resp)
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err = checkS3Response(resp); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// List 使用 ListObjectsV2 分页列出 prefix 下的文件
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	prefix, err := CleanKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]Object, 0)
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix+"/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u := s.bucketURL()
+		u.RawQuery = canonicalS3Query(query)
+
+		resp, err := s.send(ctx, http.MethodGet, u, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+		}
+		err = checkS3Response(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{
+				Key:     c.Key,
+				Size:    c.Size,
+				ModTime: c.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL 公开访问地址 存储桶需允许公开读取
+func (s *S3) URL(key string) string {
+	key, _ = CleanKey(key)
+	if s.publicUrl != "" {
+		return fmt.Sprintf("%s/%s", s.publicUrl, escapeKey(key))
+	}
+
+	return s.objectURL(key).String()
+}
+
+// SignedURL 预签名下载地址 最长 7 天
+func (s *S3) SignedURL(key string, expire time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	seconds := int64(expire / time.Second)
+	if seconds <= 0 || seconds > 7*24*3600 {
+		return "", errors.New("签名地址有效期错误")
+	}
+
+	return s.presign(s.objectURL(key), time.Now().UTC(), seconds), nil
+}
+
+// presign 生成文件地址 u 在 now 时刻签名 seconds 秒内有效的下载地址
+func (s *S3) presign(u *url.URL, now time.Time, seconds int64) string {
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(amzDate)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.FormatInt(seconds, 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := canonicalS3Query(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+	signature := s.signature(amzDate, canonicalRequest)
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+
+	return u.String()
+}
+
+// do 发送签名后的请求
+func (s *S3) do(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(ctx, method, s.objectURL(key), body, header)
+}
+
+// send 签名并发送请求 u.RawQuery 需已按 canonicalS3Query 编码
+func (s *S3) send(ctx context.Context, method string, u *url.URL, body []byte,
+	header http.Header) (*http.Response, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, u, body, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign 按 now 时刻为请求设置签名头 签名 host x-amz-content-sha256 x-amz-date
+func (s *S3) sign(req *http.Request, u *url.URL, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + u.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, s.scope(amzDate), signedHeaders, s.signature(amzDate, canonicalRequest)))
+}
+
+// objectURL 文件地址 endpoint/bucket/key
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawPath = strings.TrimRight(s.endpoint.EscapedPath(), "/") + "/" +
+		s3Escape(s.bucket, false) + "/" + s3Escape(key, false)
+	u.RawQuery = ""
+
+	return &u
+}
+
+// bucketURL 存储桶地址 endpoint/bucket
+func (s *S3) bucketURL() *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket
+	u.RawPath = strings.TrimRight(s.endpoint.EscapedPath(), "/") + "/" + s3Escape(s.bucket, false)
+	u.RawQuery = ""
+
+	return &u
+}
+
+func (s *S3) scope(amzDate string) string {
+	return fmt.Sprintf("%s/%s/s3/aws4_request", amzDate[:8], s.region)
+}
+
+func (s *S3) signature(amzDate, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		s.scope(amzDate),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), amzDate[:8])
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// checkS3Response 非 2xx 响应返回错误
+func checkS3Response(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return fmt.Errorf("S3 请求失败: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// canonicalS3Query 按参数名排序并编码
+func canonicalS3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape 按 AWS 要求编码 只保留 A-Z a-z 0-9 - _ . ~
+func s3Escape(s string, encodeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+
+	return buf.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"warehouse_oa/internal/global"
+)
+
+// Storage 文件存储 key 为相对路径 如 images/xxx.png
+type Storage interface {
+	// Put 保存文件 已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get 读取文件 不存在时返回 ErrNotExist
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL 公开访问地址
+	URL(key string) string
+	// SignedURL 有效期内可访问的签名地址
+	SignedURL(key string, expire time.Duration) (string, error)
+	// List 列出 prefix 下的所有文件
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Object 存储中的文件
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+var (
+	ErrNotExist = errors.New("文件不存在")
+
+	// Default 默认存储 由 Init 根据配置创建
+	Default Storage
+	// UrlExpire 签名地址默认有效期
+	UrlExpire = time.Hour
+)
+
+// Init 根据配置创建默认存储
+func Init(cfg global.StorageConfig) error {
+	if cfg.UrlExpire > 0 {
+		UrlExpire = time.Duration(cfg.UrlExpire) * time.Second
+	}
+
+	var err error
+	switch cfg.Type {
+	case "", "local":
+		Default, err = NewLocal(cfg.LocalDir, cfg.PublicUrl, cfg.SignKey)
+	case "s3":
+		Default, err = NewS3(cfg.S3, cfg.PublicUrl)
+	default:
+		err = fmt.Errorf("存储类型 %s 不存在", cfg.Type)
+	}
+
+	return err
+}
+
+// CleanKey 校验并规范 key 不允许跳出存储目录
+func CleanKey(key string) (string, error) {
+	key = path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || key == "." {
+		return "", errors.New("文件路径错误")
+	}
+
+	return key, nil
+}
+
+// ContentType 根据扩展名获取文件类型
+func ContentType(key string) string {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return contentType
+}
+
+// Migrate 将本地目录中的文件迁移到目标存储的 prefix 下 已存在的文件跳过 返回迁移和跳过的数量
+func Migrate(ctx context.Context, dir, prefix string, dst Storage) (int, int, error) {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+
+	var migrated, skipped int
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		key, err := CleanKey(prefix + "/" + filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		exists, err := dst.Exists(ctx, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			skipped++
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err = dst.Put(ctx, key, f, ContentType(key)); err != nil {
+			return fmt.Errorf("%s 迁移失败: %w", key, err)
+		}
+		migrated++
+
+		return nil
+	})
+
+	return migrated, skipped, err
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"warehouse_oa/internal/global"
+)
+
+// testStorage 各存储实现需满足的行为 readSigned 读取签名地址的内容
+func testStorage(t *testing.T, s Storage, readSigned func(signed string) ([]byte, error)) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	key := prefix + "/a/文件 1.txt"
+	content := []byte("hello storage")
+
+	exists, err := s.Exists(ctx, key)
+	if err != nil || exists {
+		t.Fatalf("Exists 新文件 = %v, %v", exists, err)
+	}
+	if _, err = s.Get(ctx, key); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("Get 不存在的文件应返回 ErrNotExist 实际 %v", err)
+	}
+
+	if err = s.Put(ctx, key, bytes.NewReader([]byte("old")), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	// 已存在时覆盖
+	if err = s.Put(ctx, key, bytes.NewReader(content), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put(ctx, prefix+"/b.txt", strings.NewReader("b"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Delete(ctx, prefix+"/b.txt")
+
+	exists, err = s.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("Exists = %v, %v", exists, err)
+	}
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]int64)
+	for _, o := range objects {
+		keys[o.Key] = o.Size
+	}
+	if len(keys) != 2 || keys[key] != int64(len(content)) || keys[prefix+"/b.txt"] != 1 {
+		t.Fatalf("List = %+v", objects)
+	}
+
+	signed, err := s.SignedURL(key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = readSigned(signed)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("签名地址内容 = %q, %v", got, err)
+	}
+
+	if err = s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	// 删除不存在的文件不报错
+	if err = s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = s.Exists(ctx, key)
+	if err != nil || exists {
+		t.Fatalf("删除后 Exists = %v, %v", exists, err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l, err := NewLocal(t.TempDir(), "http://localhost", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按 /files 接口的方式校验签名后读取
+	testStorage(t, l, func(signed string) ([]byte, error) {
+		u, err := url.Parse(signed)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.TrimPrefix(u.Path, "/files/")
+		err = l.Verify(key, u.Query().Get("expires"), u.Query().Get("sign"))
+		if err != nil {
+			return nil, err
+		}
+		r, err := l.Get(context.Background(), key)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return io.ReadAll(r)
+	})
+}
+
+// TestS3 设置 S3_ENDPOINT 时对 MinIO 等 S3 兼容存储执行
+// S3_BUCKET 需已创建 默认 test 访问密钥为 S3_ACCESS_KEY S3_SECRET_KEY
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("未设置 S3_ENDPOINT")
+	}
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		bucket = "test"
+	}
+	s, err := NewS3(global.S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, s, func(signed string) ([]byte, error) {
+		resp, err := http.Get(signed)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if err = checkS3Response(resp); err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(resp.Body)
+	})
+}
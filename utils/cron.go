@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 定时任务执行计划 支持 "分 时 日 月 周" 五段表达式以及 "@every 时长"
+type CronSchedule struct {
+	every  time.Duration
+	minute map[int]bool
+	hour   map[int]bool
+	dom    map[int]bool
+	month  map[int]bool
+	dow    map[int]bool
+	// 日和周都不是 * 时满足任意一个即可
+	domStar bool
+	dowStar bool
+}
+
+// ParseCron 解析定时任务表达式
+func ParseCron(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, err
+		}
+		if d < time.Second {
+			return nil, errors.New("执行间隔不能小于1秒")
+		}
+		return &CronSchedule{every: d}, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("定时表达式错误: %s", spec)
+	}
+
+	var err error
+	s := &CronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// 周日可以写作 0 或 7
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+
+	return s, nil
+}
+
+// Next 获取指定时间之后的下一次执行时间
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// 最多查找五年 表达式无法满足时返回零值
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom[t.Day()]
+	dow := s.dow[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// parseCronField 解析单个字段 支持 * , - /
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("定时表达式错误: %s", field)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		beg, end := min, max
+		if part != "*" {
+			if i := strings.Index(part, "-"); i >= 0 {
+				var err1, err2 error
+				beg, err1 = strconv.Atoi(part[:i])
+				end, err2 = strconv.Atoi(part[i+1:])
+				if err1 != nil || err2 != nil {
+					return nil, fmt.Errorf("定时表达式错误: %s", field)
+				}
+			} else {
+				n, err := strconv.Atoi(part)
+				if err != nil {
+					return nil, fmt.Errorf("定时表达式错误: %s", field)
+				}
+				beg = n
+				if step == 1 {
+					end = n
+				}
+			}
+		}
+		if beg < min || end > max || beg > end {
+			return nil, fmt.Errorf("定时表达式超出范围: %s", field)
+		}
+
+		for v := beg; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
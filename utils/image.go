@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	ImageMaxSize   = 10 << 20   // 单张图片最大 10M
+	ImageMaxPixels = 40_000_000 // 单张图片最大像素数 防止解压炸弹
+)
+
+// imageTypes 允许上传的图片类型
+var imageTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// SniffImage 根据文件内容判断图片类型 不信任扩展名
+func SniffImage(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("图片为空")
+	}
+	if len(data) > ImageMaxSize {
+		return "", fmt.Errorf("图片不能超过 %dM", ImageMaxSize>>20)
+	}
+	mimeType := http.DetectContentType(data)
+	if _, ok := imageTypes[mimeType]; !ok {
+		return "", fmt.Errorf("不支持的文件类型: %s", mimeType)
+	}
+
+	return mimeType, nil
+}
+
+// DecodeImage 解码图片 按 EXIF 方向旋转 返回图片和格式
+func DecodeImage(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("图片解析失败: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > ImageMaxPixels {
+		return nil, "", errors.New("图片尺寸过大")
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("图片解析失败: %w", err)
+	}
+	if format == "jpeg" {
+		img = OrientImage(img, ExifOrientation(data))
+	}
+
+	return img, format, nil
+}
+
+// ResizeImage 等比缩放到最长边不超过 maxSide 不放大
+func ResizeImage(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	if w >= h {
+		h = h * maxSide / w
+		w = maxSide
+	} else {
+		w = w * maxSide / h
+		h = maxSide
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+
+	return dst
+}
+
+// EncodeImage 编码图片 jpeg 以外的格式统一编码为 png 重新编码会去掉 EXIF 等元数据
+func EncodeImage(img image.Image, format string) ([]byte, string, error) {
+	buf := new(bytes.Buffer)
+	if format == "jpeg" {
+		err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), ".jpg", err
+	}
+	err := png.Encode(buf, img)
+
+	return buf.Bytes(), ".png", err
+}
+
+// OrientImage 按 EXIF 方向(1-8)旋转或翻转图片
+func OrientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 5-8 需要交换宽高
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// ExifOrientation 读取 JPEG 中 EXIF 的方向 没有时返回 1
+func ExifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// 图像数据开始 后面没有 EXIF
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+
+	return 1
+}
+
+// tiffOrientation 在 TIFF 第一个 IFD 中查找方向标签 0x0112
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
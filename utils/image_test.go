@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJpeg 生成 w*h 的 JPEG 图片
+func testJpeg(t *testing.T, w, h int) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// testTiff 生成只有一个方向标签的 TIFF 数据
+func testTiff(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	return tiff
+}
+
+// withSegment 在 SOI 之后插入一个段
+func withSegment(data []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+
+	return append(out, data[2:]...)
+}
+
+func withExif(data []byte, tiff []byte) []byte {
+	return withSegment(data, 0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func TestExifOrientation(t *testing.T) {
+	plain := testJpeg(t, 4, 2)
+
+	type exifCase struct {
+		name string
+		data []byte
+		want int
+	}
+	tests := []exifCase{
+		{name: "没有 EXIF", data: plain, want: 1},
+		{name: "不是 JPEG", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "数据过短", data: []byte{0xFF, 0xD8}, want: 1},
+		{name: "非 EXIF 的 APP1", data: withSegment(plain, 0xE1, []byte("http://ns.adobe.com/")), want: 1},
+		{name: "EXIF 前有其他段", data: withExif(withSegment(plain, 0xE0, []byte("JFIF\x00")),
+			testTiff(binary.BigEndian, 6)), want: 6},
+		{name: "方向值超出范围", data: withExif(plain, testTiff(binary.BigEndian, 9)), want: 1},
+		{name: "字节序错误", data: withExif(plain, append([]byte("XX"), testTiff(binary.BigEndian, 6)[2:]...)), want: 1},
+		{name: "TIFF 头不完整", data: withExif(plain, []byte("MM\x00")), want: 1},
+		{name: "IFD 偏移越界", data: func() []byte {
+			tiff := testTiff(binary.BigEndian, 6)
+			binary.BigEndian.PutUint32(tiff[4:], 1000)
+			return withExif(plain, tiff)
+		}(), want: 1},
+		{name: "IFD 条目不完整", data: withExif(plain, testTiff(binary.BigEndian, 6)[:16]), want: 1},
+		{name: "段长度超出数据", data: func() []byte {
+			data := withExif(plain, testTiff(binary.BigEndian, 6))
+			return data[:20]
+		}(), want: 1},
+		{name: "段长度小于2", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0x00, 0x00}, want: 1},
+		{name: "段标记错误", data: []byte{0xFF, 0xD8, 0x00, 0xE1, 0x00, 0x04, 0x00, 0x00}, want: 1},
+		{name: "图像数据之后的 EXIF 忽略", data: append([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02},
+			withExif(plain, testTiff(binary.BigEndian, 6))[2:]...), want: 1},
+	}
+	for orientation := 1; orientation <= 8; orientation++ {
+		tests = append(tests,
+			exifCase{name: fmt.Sprintf("大端方向%d", orientation), data: withExif(plain, testTiff(binary.BigEndian, orientation)), want: orientation},
+			exifCase{name: fmt.Sprintf("小端方向%d", orientation), data: withExif(plain, testTiff(binary.LittleEndian, orientation)), want: orientation},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExifOrientation(tt.data); got != tt.want {
+				t.Errorf("ExifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 3*2 图片 左上角标记为红色
+	red := color.RGBA{R: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, red)
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		x, y        int // 左上角像素旋转后的位置
+	}{
+		{orientation: 0, width: 3, height: 2, x: 0, y: 0},
+		{orientation: 1, width: 3, height: 2, x: 0, y: 0},
+		{orientation: 2, width: 3, height: 2, x: 2, y: 0},
+		{orientation: 3, width: 3, height: 2, x: 2, y: 1},
+		{orientation: 4, width: 3, height: 2, x: 0, y: 1},
+		{orientation: 5, width: 2, height: 3, x: 0, y: 0},
+		{orientation: 6, width: 2, height: 3, x: 1, y: 0},
+		{orientation: 7, width: 2, height: 3, x: 1, y: 2},
+		{orientation: 8, width: 2, height: 3, x: 0, y: 2},
+		{orientation: 9, width: 3, height: 2, x: 0, y: 0},
+	}
+	for _, tt := range tests {
+		dst := OrientImage(src, tt.orientation)
+		b := dst.Bounds()
+		if b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d",
+				tt.orientation, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+		if got := color.RGBAModel.Convert(dst.At(b.Min.X+tt.x, b.Min.Y+tt.y)); got != red {
+			t.Errorf("orientation %d: pixel (%d,%d) = %v, want red", tt.orientation, tt.x, tt.y, got)
+		}
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxSide       int
+		wantW, wantH  int
+	}{
+		{name: "横图按宽缩放", width: 400, height: 200, maxSide: 100, wantW: 100, wantH: 50},
+		{name: "竖图按高缩放", width: 200, height: 400, maxSide: 100, wantW: 50, wantH: 100},
+		{name: "小图不放大", width: 50, height: 30, maxSide: 100, wantW: 50, wantH: 30},
+		{name: "等于最长边不缩放", width: 100, height: 100, maxSide: 100, wantW: 100, wantH: 100},
+		{name: "极窄图片至少1像素", width: 1000, height: 2, maxSide: 100, wantW: 100, wantH: 1},
+		{name: "极高图片至少1像素", width: 2, height: 1000, maxSide: 100, wantW: 1, wantH: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := ResizeImage(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), tt.maxSide)
+			b := img.Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("ResizeImage() = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestDecodeImageOrientation(t *testing.T) {
+	data := withExif(testJpeg(t, 4, 2), testTiff(binary.BigEndian, 6))
+
+	img, format, err := DecodeImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Errorf("format = %s, want jpeg", format)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Errorf("size = %dx%d, want 2x4", b.Dx(), b.Dy())
+	}
+}
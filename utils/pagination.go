@@ -43,3 +43,14 @@ func DefaultQueryBool(c *gin.Context, key string, defaultValue bool) bool {
 
 	return value
 }
+
+func DefaultQueryFloat(c *gin.Context, key string, defaultValue float64) float64 {
+	valueStr := c.DefaultQuery(key, "")
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
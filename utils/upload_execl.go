@@ -1,12 +1,42 @@
 package utils
 
 import (
-	"github.com/sirupsen/logrus"
+	"bytes"
+	"encoding/csv"
+	"fmt"
 	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
 	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
-func UploadXlsx(file *multipart.FileHeader) ([]map[string]string, error) {
+// XlsxRow 导入文件的数据行 Row 为文件中的行号
+type XlsxRow struct {
+	Row  int
+	Data map[string]string
+}
+
+// UploadXlsx 读取上传的 xlsx 或 csv 文件 在前10行查找包含全部 headers 的行作为表头 返回表头之后的非空行
+// alias 为表头别名到标准列名的映射
+func UploadXlsx(file *multipart.FileHeader, headers []string, alias map[string]string) ([]XlsxRow, error) {
+	var rows [][]string
+	var err error
+	if strings.EqualFold(filepath.Ext(file.Filename), ".csv") {
+		rows, err = readCsv(file)
+	} else {
+		rows, err = readXlsx(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseRows(rows, headers, alias)
+}
+
+func readXlsx(file *multipart.FileHeader) ([][]string, error) {
 	fileContent, err := file.Open()
 	if err != nil {
 		return nil, err
@@ -23,24 +53,117 @@ func UploadXlsx(file *multipart.FileHeader) ([]map[string]string, error) {
 		_ = ff.Close()
 	}(f)
 
-	// 获取指定工作表的所有行
+	// 默认读取 Sheet1 不存在时读取第一个工作表
 	sheetName := "Sheet1"
-	rows, err := f.GetRows(sheetName)
+	if idx, _ := f.GetSheetIndex(sheetName); idx < 0 {
+		sheetList := f.GetSheetList()
+		if len(sheetList) == 0 {
+			return nil, fmt.Errorf("文件没有工作表")
+		}
+		sheetName = sheetList[0]
+	}
+
+	return f.GetRows(sheetName)
+}
+
+// readCsv 读取 csv 文件 平台导出的 GBK 编码自动转换为 UTF-8
+func readCsv(file *multipart.FileHeader) ([][]string, error) {
+	fileContent, err := file.Open()
 	if err != nil {
 		return nil, err
 	}
+	defer func(fileContent multipart.File) {
+		_ = fileContent.Close()
+	}(fileContent)
 
-	dataList := make([]map[string]string, 0)
-	// 遍历行数据并打印
-	for _, row := range rows {
-		m := make(map[string]string)
-		for j, cell := range row {
-			m[rows[0][j]] = cell
+	content, err := io.ReadAll(fileContent)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) {
+		content, err = simplifiedchinese.GB18030.NewDecoder().Bytes(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	return r.ReadAll()
+}
+
+// ParseRows 查找表头并将数据行转换为 标准列名->单元格 的映射
+func ParseRows(rows [][]string, headers []string, alias map[string]string) ([]XlsxRow, error) {
+	headerName := func(cell string) string {
+		cell = strings.TrimSpace(cell)
+		if name, ok := alias[cell]; ok {
+			return name
+		}
+		return cell
+	}
+
+	headerIndex := -1
+	for i := 0; i < len(rows) && i < 10; i++ {
+		cells := make(map[string]bool)
+		for _, cell := range rows[i] {
+			cells[headerName(cell)] = true
+		}
+		found := true
+		for _, h := range headers {
+			if !cells[h] {
+				found = false
+				break
+			}
+		}
+		if found {
+			headerIndex = i
+			break
 		}
-		dataList = append(dataList, m)
+	}
+	if headerIndex < 0 {
+		return nil, fmt.Errorf("未找到表头，表头需要包含: %s", strings.Join(headers, "、"))
 	}
 
-	logrus.Infoln(dataList)
+	// 多个表头对应同一列名时 优先使用与标准列名完全相同的表头 否则使用第一个非空的别名
+	header := make([]string, 0)
+	exact := make(map[string]bool)
+	for _, cell := range rows[headerIndex] {
+		name := headerName(cell)
+		header = append(header, name)
+		if strings.TrimSpace(cell) == name {
+			exact[name] = true
+		}
+	}
+	dataList := make([]XlsxRow, 0)
+	for i := headerIndex + 1; i < len(rows); i++ {
+		m := make(map[string]string)
+		empty := true
+		for j, cell := range rows[i] {
+			if j >= len(header) {
+				break
+			}
+			cell = strings.TrimSpace(cell)
+			if cell != "" {
+				empty = false
+			}
+			if exact[header[j]] && strings.TrimSpace(rows[headerIndex][j]) != header[j] {
+				continue
+			}
+			if m[header[j]] == "" {
+				m[header[j]] = cell
+			}
+		}
+		if empty {
+			continue
+		}
+		dataList = append(dataList, XlsxRow{
+			Row:  i + 1,
+			Data: m,
+		})
+	}
 
-	return nil, nil
+	return dataList, nil
 }
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"mime/multipart"
+	"reflect"
+	"testing"
+)
+
+func TestParseRows(t *testing.T) {
+	headers := []string{"订单号", "数量"}
+	alias := map[string]string{
+		"订单编号": "订单号",
+		"主订单号": "订单号",
+		"件数":   "数量",
+	}
+
+	tests := []struct {
+		name    string
+		rows    [][]string
+		want    []XlsxRow
+		wantErr bool
+	}{
+		{
+			name: "表头在第一行",
+			rows: [][]string{
+				{"订单号", "数量"},
+				{"A001", "2"},
+			},
+			want: []XlsxRow{{Row: 2, Data: map[string]string{"订单号": "A001", "数量": "2"}}},
+		},
+		{
+			name: "跳过表头之前的说明行和空行",
+			rows: [][]string{
+				{"店铺账单导出"},
+				{"导出时间", "2024-01-01"},
+				{" 订单编号 ", "件数", "备注"},
+				{"A001", "2", "加急"},
+				{"", " ", ""},
+				{" A002 ", "3"},
+			},
+			want: []XlsxRow{
+				{Row: 4, Data: map[string]string{"订单号": "A001", "数量": "2", "备注": "加急"}},
+				{Row: 6, Data: map[string]string{"订单号": "A002", "数量": "3"}},
+			},
+		},
+		{
+			name: "表头在第10行",
+			rows: append(make([][]string, 9), []string{"订单号", "数量"}, []string{"A001", "1"}),
+			want: []XlsxRow{{Row: 11, Data: map[string]string{"订单号": "A001", "数量": "1"}}},
+		},
+		{
+			name:    "表头在第10行之后",
+			rows:    append(make([][]string, 10), []string{"订单号", "数量"}, []string{"A001", "1"}),
+			wantErr: true,
+		},
+		{
+			name:    "缺少表头",
+			rows:    [][]string{{"订单号"}, {"A001"}},
+			wantErr: true,
+		},
+		{
+			name: "标准表头优先于别名",
+			rows: [][]string{
+				{"订单编号", "订单号", "数量"},
+				{"B001", "A001", "1"},
+				{"B002", "", "1"},
+			},
+			want: []XlsxRow{
+				{Row: 2, Data: map[string]string{"订单号": "A001", "数量": "1"}},
+				{Row: 3, Data: map[string]string{"订单号": "", "数量": "1"}},
+			},
+		},
+		{
+			name: "多个别名使用第一个非空的值",
+			rows: [][]string{
+				{"主订单号", "订单编号", "数量"},
+				{"", "B001", "1"},
+				{"C001", "B002", "1"},
+			},
+			want: []XlsxRow{
+				{Row: 2, Data: map[string]string{"订单号": "B001", "数量": "1"}},
+				{Row: 3, Data: map[string]string{"订单号": "C001", "数量": "1"}},
+			},
+		},
+		{
+			name: "超出表头的单元格忽略",
+			rows: [][]string{
+				{"订单号", "数量"},
+				{"A001", "1", "多余"},
+			},
+			want: []XlsxRow{{Row: 2, Data: map[string]string{"订单号": "A001", "数量": "1"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRows(tt.rows, headers, alias)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testFileHeader 生成上传文件
+func testFileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = form.RemoveAll()
+	})
+
+	return form.File["file"][0]
+}
+
+func TestUploadCsv(t *testing.T) {
+	text := "平台账单\n订单编号,件数,备注\nA001,2,\"加急,到付\"\n"
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []XlsxRow{{Row: 3, Data: map[string]string{"订单号": "A001", "数量": "2", "备注": "加急,到付"}}}
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "UTF-8", content: []byte(text)},
+		{name: "UTF-8 BOM", content: append([]byte("\xef\xbb\xbf"), text...)},
+		{name: "GBK", content: gbk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := testFileHeader(t, "bill.CSV", tt.content)
+			got, err := UploadXlsx(file, []string{"订单号", "数量"},
+				map[string]string{"订单编号": "订单号", "件数": "数量"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("UploadXlsx() = %v, want %v", got, want)
+			}
+		})
+	}
+}